@@ -1,15 +1,18 @@
 package client
 
 import (
+	"context"
 	"bytes"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"explo/src/config"
+	"explo/src/metrics"
 	"explo/src/models"
 	"explo/src/util"
 )
@@ -17,12 +20,12 @@ import (
 // uploadPlaylistArtwork POSTs raw image bytes to a music app's artwork endpoint.
 // Plex, Jellyfin, and Emby all accept the same format — POST + Content-Type: image/jpeg + raw body.
 // The only per-client difference is the URL path, which each caller builds before invoking.
-func uploadPlaylistArtwork(hc *util.HttpClient, endpoint, localPath string, headers map[string]string) error {
+func uploadPlaylistArtwork(ctx context.Context, hc *util.HttpClient, endpoint, localPath string, headers map[string]string) error {
 	data, err := os.ReadFile(localPath)
 	if err != nil {
 		return fmt.Errorf("read artwork: %w", err)
 	}
-	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("build request: %w", err)
 	}
@@ -47,6 +50,34 @@ func uploadPlaylistArtwork(hc *util.HttpClient, endpoint, localPath string, head
 	return nil
 }
 
+// Match ranks used by SearchSongs to pick between search results, higher is more reliable
+const (
+	matchNone  = iota
+	matchFuzzy // title/artist/album or file path
+	matchISRC
+	matchMBID
+)
+
+// isrcMatch reports if one of the track's ISRCs is among the ISRCs tagged on a library item
+func isrcMatch(track *models.Track, itemISRCs ...string) bool {
+	for _, want := range track.ISRCs {
+		want = normalizeISRC(want)
+		if want == "" {
+			continue
+		}
+		for _, have := range itemISRCs {
+			if normalizeISRC(have) == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func normalizeISRC(isrc string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(isrc), "-", ""))
+}
+
 // Client manages interactions with the selected music system
 type Client struct {
 	System string
@@ -55,27 +86,59 @@ type Client struct {
 }
 
 type APIClient interface {
-	GetLibrary() error
-	GetAuth() error
-	AddHeader() error
-	AddLibrary() error
-	SearchSongs([]*models.Track) error
-	RefreshLibrary() error
-	CheckRefreshState() bool
-	CreatePlaylist([]*models.Track) error
-	SearchPlaylist() error
-	UpdatePlaylist() error
-	DeletePlaylist() error
+	GetLibrary(ctx context.Context) error
+	GetAuth(ctx context.Context) error
+	AddHeader(ctx context.Context) error
+	AddLibrary(ctx context.Context) error
+	SearchSongs(ctx context.Context, tracks []*models.Track) error
+	RefreshLibrary(ctx context.Context) error
+	CheckRefreshState(ctx context.Context) bool
+	CreatePlaylist(ctx context.Context, tracks []*models.Track) error
+	SearchPlaylist(ctx context.Context) error
+	UpdatePlaylist(ctx context.Context) error
+	DeletePlaylist(ctx context.Context) error
 }
 
 // ArtworkUploader is an optional capability for clients that support setting
 // playlist artwork. Use a type assertion: if u, ok := c.API.(client.ArtworkUploader); ok {...}.
 type ArtworkUploader interface {
-	SetPlaylistArtwork(localPath string) error
+	SetPlaylistArtwork(ctx context.Context, localPath string) error
+}
+
+// PlaylistIdentifier is an optional capability for clients that can report the
+// server-side ID of the playlist they created.
+type PlaylistIdentifier interface {
+	PlaylistID() string
+}
+
+// Ratings of library tracks, the values are ListenBrainz feedback scores
+const (
+	RatingHate = -1
+	RatingNone = 0
+	RatingLove = 1
+)
+
+// RatingReader is an optional capability for clients that can read the user's favourites
+// and ratings. GetRatings maps each library ID to a Rating*, IDs that no longer exist are left out.
+type RatingReader interface {
+	GetRatings(ctx context.Context, ids []string) (map[string]int, error)
+}
+
+// Play is a play of a library track by the user
+type Play struct {
+	ID       string
+	PlayedAt time.Time
+}
+
+// PlayReader is an optional capability for clients that can read the user's play history.
+// GetPlays returns plays of the given library IDs since the given time, systems that only
+// keep the last play of a track return at most one play per ID.
+type PlayReader interface {
+	GetPlays(ctx context.Context, ids []string, since time.Time) ([]Play, error)
 }
 
 // NewClient initializes a client and sets up authentication
-func NewClient(cfg *config.Config) (*Client, error) {
+func NewClient(ctx context.Context, cfg *config.Config) (*Client, error) {
 	c := &Client{
 		System: cfg.System,
 		Cfg:    &cfg.ClientCfg,
@@ -84,6 +147,12 @@ func NewClient(cfg *config.Config) (*Client, error) {
 	httpClient := util.NewHttp(util.HttpClientConfig{
 		Timeout: cfg.ClientCfg.HTTPTimeout,
 	})
+	httpClient.Observe = func(d time.Duration, err error) {
+		metrics.APIRequests.WithLabelValues(cfg.System).Observe(d.Seconds())
+		if err != nil {
+			metrics.APIRequestErrors.WithLabelValues(cfg.System).Inc()
+		}
+	}
 
 	switch c.System {
 
@@ -106,7 +175,7 @@ func NewClient(cfg *config.Config) (*Client, error) {
 		return nil, fmt.Errorf("unknown system: %s. Use a supported system (emby, jellyfin, mpd, plex, or subsonic)", c.System)
 	}
 
-	if err := c.systemSetup(); err != nil { // Run setup automatically
+	if err := c.systemSetup(ctx); err != nil { // Run setup automatically
 		return nil, fmt.Errorf("setup failed: %w", err)
 	}
 
@@ -115,22 +184,22 @@ func NewClient(cfg *config.Config) (*Client, error) {
 
 // TriggerRefresh Runs a trigger to refresh the users app music library
 // Useful for one-shot operations
-func TriggerRefresh(cfg *config.Config) error {
-	c, err := NewClient(cfg)
+func TriggerRefresh(ctx context.Context, cfg *config.Config) error {
+	c, err := NewClient(ctx, cfg)
 	if err != nil {
 		return fmt.Errorf("client setup: %w", err)
 	}
-	return c.API.RefreshLibrary()
+	return c.API.RefreshLibrary(ctx)
 }
 
 // systemSetup checks needed credentials and initializes the selected system
-func (c *Client) systemSetup() error {
+func (c *Client) systemSetup(ctx context.Context) error {
 	switch c.System {
 	case "subsonic":
 		if c.Cfg.Creds.User == "" || c.Cfg.Creds.Password == "" {
 			return fmt.Errorf("Subsonic USER and PASSWORD are required")
 		}
-		return c.API.GetAuth()
+		return c.API.GetAuth(ctx)
 
 	case "jellyfin":
 		if c.Cfg.Creds.APIKey == "" && c.Cfg.AdminCreds.APIKey == "" {
@@ -139,10 +208,10 @@ func (c *Client) systemSetup() error {
 		if c.Cfg.Creds.User == "" {
 			slog.Warn("It is recommended to set SYSTEM_USERNAME for Jellyfin")
 		}
-		if err := c.API.AddHeader(); err != nil {
+		if err := c.API.AddHeader(ctx); err != nil {
 			return err
 		}
-		return c.API.GetLibrary()
+		return c.API.GetLibrary(ctx)
 
 	case "mpd":
 		if c.Cfg.PlaylistDir == "" {
@@ -154,75 +223,103 @@ func (c *Client) systemSetup() error {
 		if (c.Cfg.Creds.User == "" || c.Cfg.Creds.Password == "") && c.Cfg.Creds.APIKey == "" {
 			return fmt.Errorf("Plex USER/PASSWORD or API_KEY is required")
 		}
-		if err := c.API.AddHeader(); err != nil {
+		if err := c.API.AddHeader(ctx); err != nil {
 			return err
 		}
 
 		if c.Cfg.Creds.APIKey == "" {
-			if err := c.API.GetAuth(); err != nil {
+			if err := c.API.GetAuth(ctx); err != nil {
 				return err
 			}
 		}
 
-		if err := c.API.AddHeader(); err != nil {
+		if err := c.API.AddHeader(ctx); err != nil {
 			return err
 		}
-		return c.API.GetLibrary()
+		return c.API.GetLibrary(ctx)
 
 	case "emby":
 		if c.Cfg.Creds.APIKey == "" {
 			return fmt.Errorf("Emby API_KEY is required")
 		}
-		if err := c.API.AddHeader(); err != nil {
+		if err := c.API.AddHeader(ctx); err != nil {
 			return err
 		}
-		return c.API.GetLibrary()
+		return c.API.GetLibrary(ctx)
 
 	default:
 		return fmt.Errorf("unknown system: %s. Use a supported system (emby, jellyfin, mpd, plex, or subsonic)", c.System)
 	}
 }
 
-func (c *Client) CheckTracks(tracks []*models.Track) error {
-	if err := c.API.SearchSongs(tracks); err != nil {
+func (c *Client) CheckTracks(ctx context.Context, tracks []*models.Track) error {
+	if err := c.API.SearchSongs(ctx, tracks); err != nil {
 		return fmt.Errorf("SearchSongs failed: %s", err.Error())
 	}
 	return nil
 }
 
-func (c *Client) CreatePlaylist(tracks []*models.Track) error {
+func (c *Client) CreatePlaylist(ctx context.Context, tracks []*models.Track) error {
 	if c.System == "" {
 		return fmt.Errorf("could not get music system")
 	}
 
-	if err := c.API.RefreshLibrary(); err != nil {
+	if err := c.API.RefreshLibrary(ctx); err != nil {
 		return fmt.Errorf("[%s] failed to schedule a library scan: %s", c.System, err.Error())
 	}
 	slog.Info("Refreshing library...", "system", c.System)
-	if !c.API.CheckRefreshState() {
+	if !c.API.CheckRefreshState(ctx) {
 		slog.Debug("could not check library refresh state, either the client doesn't support it or threw an error")
 		slog.Debug("falling back on SLEEP env variable")
-		time.Sleep(time.Duration(c.Cfg.Sleep) * time.Minute)
+		if err := util.Sleep(ctx, time.Duration(c.Cfg.Sleep)*time.Minute); err != nil {
+			return err
+		}
 	}
 
-	if err := c.API.SearchSongs(tracks); err != nil { // search newly added songs
+	if err := c.API.SearchSongs(ctx, tracks); err != nil { // search newly added songs
 		slog.Warn("SearchSongs failed", "context", err)
 	}
-	if err := c.API.CreatePlaylist(tracks); err != nil {
+	if err := c.API.CreatePlaylist(ctx, tracks); err != nil {
 		return fmt.Errorf("[%s] failed to create playlist: %s", c.System, err.Error())
 	}
 
-	if err := c.API.UpdatePlaylist(); err != nil {
+	if err := c.API.UpdatePlaylist(ctx); err != nil {
 		return fmt.Errorf("[%s] failed to update playlist: %s", c.System, err.Error())
 	}
 	return nil
 }
 
-func (c *Client) DeletePlaylist() error {
-	if err := c.API.SearchPlaylist(); err != nil {
+// PlaylistID returns the music system's ID for the run's playlist, empty if unknown
+func (c *Client) PlaylistID() string {
+	if p, ok := c.API.(PlaylistIdentifier); ok {
+		return p.PlaylistID()
+	}
+	return ""
+}
+
+// GetRatings returns the user's ratings of library tracks, if the music system supports them
+func (c *Client) GetRatings(ctx context.Context, ids []string) (map[string]int, error) {
+	r, ok := c.API.(RatingReader)
+	if !ok {
+		return nil, fmt.Errorf("reading ratings isn't supported for %s", c.System)
+	}
+	return r.GetRatings(ctx, ids)
+}
+
+// GetPlays returns the user's plays of library tracks, if the music system exposes them
+func (c *Client) GetPlays(ctx context.Context, ids []string, since time.Time) ([]Play, error) {
+	r, ok := c.API.(PlayReader)
+	if !ok {
+		return nil, fmt.Errorf("reading play history isn't supported for %s", c.System)
+	}
+	return r.GetPlays(ctx, ids, since)
+}
+
+func (c *Client) DeletePlaylist(ctx context.Context) error {
+	if err := c.API.SearchPlaylist(ctx); err != nil {
 		return fmt.Errorf("SearchPlaylist failed: %v", err)
 	}
-	if err := c.API.DeletePlaylist(); err != nil {
+	if err := c.API.DeletePlaylist(ctx); err != nil {
 		return fmt.Errorf("[%s] failed to delete playlist: %s", c.System, err.Error())
 	}
 	return nil
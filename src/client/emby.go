@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"bytes"
 	"fmt"
 	"log/slog"
@@ -23,6 +24,7 @@ type EmbyPaths []struct {
 
 type EmbyProviderIds struct {
 	MusicBrainzTrack        string `json:"MusicBrainzTrack"`
+	ISRC                    string `json:"ISRC"`
 }
 
 type EmbyItemSearch struct {
@@ -56,7 +58,7 @@ func NewEmby(cfg config.ClientConfig, httpClient *util.HttpClient) *Emby {
 	HttpClient: httpClient}
 }
 
-func (c *Emby) AddHeader() error {
+func (c *Emby) AddHeader(ctx context.Context) error {
 	if c.Cfg.Creds.Headers == nil {
 		c.Cfg.Creds.Headers = make(map[string]string)
 		c.Cfg.Creds.Headers["X-Emby-Client"] = c.Cfg.ClientID
@@ -69,14 +71,14 @@ func (c *Emby) AddHeader() error {
 	return fmt.Errorf("API_KEY not set")
 }
 
-func (c *Emby) GetAuth() error {
+func (c *Emby) GetAuth(ctx context.Context) error {
 	return nil
 }
 
-func (c *Emby) GetLibrary() error {
+func (c *Emby) GetLibrary(ctx context.Context) error {
 	reqParam := "/emby/Library/VirtualFolders"
 
-	body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers)
 	if err != nil {
 		return err
 	}
@@ -95,7 +97,7 @@ func (c *Emby) GetLibrary() error {
 	return fmt.Errorf("failed to find library named %s", c.Cfg.LibraryName)
 }
 
-func (c *Emby) AddLibrary() error {
+func (c *Emby) AddLibrary(ctx context.Context) error {
 	reqParam := "/emby/Library/VirtualFolders"
 
 	payload := fmt.Appendf(nil, `{
@@ -110,30 +112,30 @@ func (c *Emby) AddLibrary() error {
 		}
 	  }`, c.Cfg.LibraryName, c.Cfg.DownloadDir)
 
-	if _, err := c.HttpClient.MakeRequest("POST", c.Cfg.URL+reqParam, bytes.NewReader(payload), c.Cfg.Creds.Headers); err != nil {
+	if _, err := c.HttpClient.MakeRequest(ctx, "POST", c.Cfg.URL+reqParam, bytes.NewReader(payload), c.Cfg.Creds.Headers); err != nil {
 		return fmt.Errorf("failed to add library to Emby using the download path, please define a library name using LIBRARY_NAME in .env: %s", err.Error())
 	}
 	return nil
 }
 
-func (c *Emby) RefreshLibrary() error {
+func (c *Emby) RefreshLibrary(ctx context.Context) error {
 	reqParam := fmt.Sprintf("/emby/Items/%s/Refresh?Recursive=True&MetadataRefreshMode=FullRefresh", c.LibraryID)
 
-	if _, err := c.HttpClient.MakeRequest("POST", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers); err != nil {
+	if _, err := c.HttpClient.MakeRequest(ctx, "POST", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *Emby) CheckRefreshState() bool {
+func (c *Emby) CheckRefreshState(ctx context.Context) bool {
 	return false
 }
 
-func (c *Emby) SearchSongs(tracks []*models.Track) error {
+func (c *Emby) SearchSongs(ctx context.Context, tracks []*models.Track) error {
 	for _, track := range tracks {
 		reqParam := fmt.Sprintf("/emby/Items?IncludeMediaTypes=Audio&SearchTerm=%s&Recursive=true&Fields=Path,ProviderIDs", url.QueryEscape(util.CleanSearchTitle(track.CleanTitle)))
 
-		body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers)
+		body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers)
 		if err != nil {
 			return err
 		}
@@ -144,6 +146,7 @@ func (c *Emby) SearchSongs(tracks []*models.Track) error {
 		}
 
 		normalizedCleanTitle := util.NormalizeTitle(track.CleanTitle)
+		bestRank := matchNone
 		for _, item := range results.Items {
 
 			normalizedItemTitle := util.NormalizeTitle(item.Name)
@@ -153,15 +156,22 @@ func (c *Emby) SearchSongs(tracks []*models.Track) error {
 			artistMatch := strings.EqualFold(item.AlbumArtist, track.MainArtist) || (len(item.Artists) > 0 && strings.EqualFold(item.Artists[0], track.MainArtist))
 			pathMatch := util.ContainsFold(item.Path,track.File)
 
-			if musicBrainzMatch || (titleMatch && artistMatch)  {
-				track.ID = item.ID
-				track.Present = true
-				break
+			rank := matchNone
+			switch {
+			case musicBrainzMatch:
+				rank = matchMBID
+			case isrcMatch(track, item.ProviderIds.ISRC):
+				rank = matchISRC
+			case titleMatch && artistMatch, track.File != "" && artistMatch && pathMatch:
+				rank = matchFuzzy
 			}
 
-			if track.File != "" && artistMatch && pathMatch {
+			if rank > bestRank {
+				bestRank = rank
 				track.ID = item.ID
 				track.Present = true
+			}
+			if rank == matchMBID {
 				break
 			}
 		}
@@ -173,10 +183,10 @@ func (c *Emby) SearchSongs(tracks []*models.Track) error {
 	return nil
 }
 
-func (c *Emby) SearchPlaylist() error {
+func (c *Emby) SearchPlaylist(ctx context.Context) error {
 	params := fmt.Sprintf("/emby/Items?SearchTerm=%s&Recursive=true&IncludeItemTypes=Playlist", url.QueryEscape(c.Cfg.PlaylistName))
 
-	body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers)
 	if err != nil {
 		return err
 	}
@@ -194,13 +204,13 @@ func (c *Emby) SearchPlaylist() error {
 	}
 }
 
-func (c *Emby) CreatePlaylist(tracks []*models.Track) error {
+func (c *Emby) CreatePlaylist(ctx context.Context, tracks []*models.Track) error {
 	songIDs := formatEmbySongs(tracks)
 
 	reqParam := fmt.Sprintf("/emby/Playlists?Name=%s&Ids=%s&MediaType=Music", url.QueryEscape(c.Cfg.PlaylistName), songIDs)
 
 
-	body, err := c.HttpClient.MakeRequest("POST", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "POST", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers)
 	if err != nil {
 		return err
 	}
@@ -212,8 +222,10 @@ func (c *Emby) CreatePlaylist(tracks []*models.Track) error {
 	return nil
 }
 
-func (c *Emby) UpdatePlaylist() error {
-	time.Sleep(5 * time.Second) // small buffer between playlist creation and updating, Emby doesn't update playlist otherwise
+func (c *Emby) UpdatePlaylist(ctx context.Context) error {
+	if err := util.Sleep(ctx, 5*time.Second); err != nil { // small buffer between playlist creation and updating, Emby doesn't update playlist otherwise
+		return err
+	}
 	reqParam := fmt.Sprintf("/emby/Items/%s", c.Cfg.PlaylistID)
 
 	payload := fmt.Appendf(nil, `
@@ -224,27 +236,27 @@ func (c *Emby) UpdatePlaylist() error {
 		"ProviderIds": {}
 		}`, c.Cfg.PlaylistID, c.Cfg.PlaylistName, c.Cfg.PlaylistDescr) // the additional field has to be added, otherwise Emby returns code 500
 
-	if _, err := c.HttpClient.MakeRequest("POST", c.Cfg.URL+reqParam, bytes.NewBuffer(payload), c.Cfg.Creds.Headers); err != nil {
+	if _, err := c.HttpClient.MakeRequest(ctx, "POST", c.Cfg.URL+reqParam, bytes.NewBuffer(payload), c.Cfg.Creds.Headers); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *Emby) DeletePlaylist() error { // Doesn't currently work due to a bug in Emby
+func (c *Emby) DeletePlaylist(ctx context.Context) error { // Doesn't currently work due to a bug in Emby
 	/* reqParam := fmt.Sprintf("/emby/Items/Delete?Ids=%s", c.Cfg.PlaylistID)
 
-	if _, err := util.MakeRequest("POST", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers); err != nil {
+	if _, err := util.MakeRequest(ctx, "POST", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers); err != nil {
 		return err
 	} */
 	return nil
 }
 
 // SetPlaylistArtwork uploads a JPEG as the playlist's primary image.
-func (c *Emby) SetPlaylistArtwork(localPath string) error {
+func (c *Emby) SetPlaylistArtwork(ctx context.Context, localPath string) error {
 	if c.Cfg.PlaylistID == "" {
 		return fmt.Errorf("emby: no PlaylistID set")
 	}
-	return uploadPlaylistArtwork(c.HttpClient, c.Cfg.URL+"/emby/Items/"+c.Cfg.PlaylistID+"/Images/Primary", localPath, c.Cfg.Creds.Headers)
+	return uploadPlaylistArtwork(ctx, c.HttpClient, c.Cfg.URL+"/emby/Items/"+c.Cfg.PlaylistID+"/Images/Primary", localPath, c.Cfg.Creds.Headers)
 }
 
 func formatEmbySongs(tracks []*models.Track) string {
@@ -257,4 +269,9 @@ func formatEmbySongs(tracks []*models.Track) string {
 	songs := strings.Join(songIDs, ",")
 
 	return songs
-}
\ No newline at end of file
+}
+
+// PlaylistID returns the ID of the playlist found or created during this run
+func (c *Emby) PlaylistID() string {
+	return c.Cfg.PlaylistID
+}
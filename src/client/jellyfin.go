@@ -1,11 +1,14 @@
 package client
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"slices"
 	"strings"
+	"time"
 	"log/slog"
 
 	"explo/src/config"
@@ -42,6 +45,7 @@ type Audios struct {
 
 type ProviderIds struct {
 	MusicBrainzTrack        string `json:"MusicBrainzTrack"`
+	ISRC                    string `json:"ISRC"`
 }
 
 type Items struct {
@@ -56,6 +60,19 @@ type Items struct {
 
 }
 
+type JFUserItems struct {
+	Items []JFUserItem `json:"Items"`
+}
+
+type JFUserItem struct {
+	ID       string `json:"Id"`
+	UserData struct {
+		IsFavorite     bool      `json:"IsFavorite"`
+		Likes          *bool     `json:"Likes"` // thumbs up/down, unset if not rated
+		LastPlayedDate time.Time `json:"LastPlayedDate"`
+	} `json:"UserData"`
+}
+
 type JFPlaylist struct {
 	ID string `json:"Id"`
 }
@@ -76,7 +93,7 @@ func NewJellyfin(cfg config.ClientConfig, httpClient *util.HttpClient) *Jellyfin
 		HttpClient: httpClient}
 }
 
-func (c *Jellyfin) AddHeader() error {
+func (c *Jellyfin) AddHeader(ctx context.Context) error {
 	if c.Cfg.Creds.Headers == nil {
 		c.Cfg.Creds.Headers = make(map[string]string)
 	}
@@ -92,14 +109,14 @@ func (c *Jellyfin) AddHeader() error {
 	return nil
 }
 
-func (c *Jellyfin) GetAuth() error {
+func (c *Jellyfin) GetAuth(ctx context.Context) error {
 	return nil
 }
 
-func (c *Jellyfin) GetLibrary() error {
+func (c *Jellyfin) GetLibrary(ctx context.Context) error {
 	reqParam := "/Library/VirtualFolders"
 
-	body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers)
 	if err != nil {
 		return err
 	}
@@ -118,7 +135,7 @@ func (c *Jellyfin) GetLibrary() error {
 	return fmt.Errorf("failed to find library named %s", c.Cfg.LibraryName)
 }
 
-func (c *Jellyfin) AddLibrary() error {
+func (c *Jellyfin) AddLibrary(ctx context.Context) error {
 	cleanPath := url.PathEscape(c.Cfg.DownloadDir)
 	reqParam := fmt.Sprintf("/Library/VirtualFolders?name=%s&paths=%s&collectionType=music&refreshLibrary=true", c.Cfg.LibraryName, cleanPath)
 	payload := []byte(`{
@@ -129,30 +146,30 @@ func (c *Jellyfin) AddLibrary() error {
 		}
 	  }`)
 
-	if _, err := c.HttpClient.MakeRequest("POST", c.Cfg.URL+reqParam, bytes.NewReader(payload), c.Cfg.Creds.Headers); err != nil {
+	if _, err := c.HttpClient.MakeRequest(ctx, "POST", c.Cfg.URL+reqParam, bytes.NewReader(payload), c.Cfg.Creds.Headers); err != nil {
 		return fmt.Errorf("failed to add library to Jellyfin using the download path, please define a library name using LIBRARY_NAME in .env: %s", err.Error())
 	}
 	return nil
 }
 
-func (c *Jellyfin) RefreshLibrary() error {
+func (c *Jellyfin) RefreshLibrary(ctx context.Context) error {
 	reqParam := fmt.Sprintf("/Items/%s/Refresh?metadataRefreshMode=FullRefresh&Recursive=true", c.LibraryID)
 
-	if _, err := c.HttpClient.MakeRequest("POST", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers); err != nil {
+	if _, err := c.HttpClient.MakeRequest(ctx, "POST", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *Jellyfin) CheckRefreshState() bool {
+func (c *Jellyfin) CheckRefreshState(ctx context.Context) bool {
 	return false
 }
 
-func (c *Jellyfin) SearchSongs(tracks []*models.Track) error {
+func (c *Jellyfin) SearchSongs(ctx context.Context, tracks []*models.Track) error {
 	for _, track := range tracks {
 		reqParam := fmt.Sprintf("/Items?IncludeMediaTypes=Audio&SearchTerm=%s&Recursive=true&Fields=Path,ProviderIDs", url.QueryEscape(util.CleanSearchTitle(track.CleanTitle)))
 
-		body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers)
+		body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers)
 		if err != nil {
 			return err
 		}
@@ -162,6 +179,7 @@ func (c *Jellyfin) SearchSongs(tracks []*models.Track) error {
 			return err
 		}
 		normalizedCleanTitle := util.NormalizeTitle(track.CleanTitle)
+		bestRank := matchNone
 		for _, item := range results.Items {
 
 			normalizedItemTitle := util.NormalizeTitle(item.Name)
@@ -170,16 +188,23 @@ func (c *Jellyfin) SearchSongs(tracks []*models.Track) error {
 			titleMatch := normalizedItemTitle == normalizedCleanTitle
 			artistMatch := strings.EqualFold(item.AlbumArtist, track.MainArtist) || (len(item.Artists) > 0 && strings.EqualFold(item.Artists[0], track.MainArtist))
 			pathMatch := util.ContainsFold(item.Path,track.File)
-			
-			if musicBrainzMatch || (titleMatch && artistMatch) {
-				track.ID = item.ID
-				track.Present = true
-				break
+
+			rank := matchNone
+			switch {
+			case musicBrainzMatch:
+				rank = matchMBID
+			case isrcMatch(track, item.ProviderIds.ISRC):
+				rank = matchISRC
+			case titleMatch && artistMatch, track.File != "" && artistMatch && pathMatch:
+				rank = matchFuzzy
 			}
 
-			if track.File != "" && artistMatch && pathMatch {
+			if rank > bestRank {
+				bestRank = rank
 				track.ID = item.ID
 				track.Present = true
+			}
+			if rank == matchMBID {
 				break
 			}
 		}
@@ -191,9 +216,9 @@ func (c *Jellyfin) SearchSongs(tracks []*models.Track) error {
 	return nil
 }
 
-func (c *Jellyfin) SearchPlaylist() error {
+func (c *Jellyfin) SearchPlaylist(ctx context.Context) error {
 	queryParams := fmt.Sprintf("/Search/Hints?IncludeItemTypes=Playlist&SearchTerm=%s", url.QueryEscape(c.Cfg.PlaylistName))
-	body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+queryParams, nil, c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+queryParams, nil, c.Cfg.Creds.Headers)
 	if err != nil {
 		return err
 	}
@@ -211,7 +236,7 @@ func (c *Jellyfin) SearchPlaylist() error {
 	}
 }
 
-func (c *Jellyfin) CreatePlaylist(tracks []*models.Track) error {
+func (c *Jellyfin) CreatePlaylist(ctx context.Context, tracks []*models.Track) error {
 
 	songs, err := formatJFSongs(tracks)
 	if err != nil {
@@ -221,7 +246,7 @@ func (c *Jellyfin) CreatePlaylist(tracks []*models.Track) error {
 	isPublic := c.Cfg.PublicPlaylist
 
 	if c.Cfg.Creds.User != "" {
-		userID, err = c.ResolveUserID()
+		userID, err = c.ResolveUserID(ctx)
 		if err != nil {
 			return err
 		}
@@ -241,7 +266,7 @@ func (c *Jellyfin) CreatePlaylist(tracks []*models.Track) error {
 		"IsPublic": %t
 		}`, c.Cfg.PlaylistName, songs, userID, isPublic)
 
-	body, err := c.HttpClient.MakeRequest("POST", c.Cfg.URL+queryParams, bytes.NewReader(payload), c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "POST", c.Cfg.URL+queryParams, bytes.NewReader(payload), c.Cfg.Creds.Headers)
 	if err != nil {
 		return err
 	}
@@ -253,7 +278,7 @@ func (c *Jellyfin) CreatePlaylist(tracks []*models.Track) error {
 	return nil
 }
 
-func (c *Jellyfin) UpdatePlaylist() error {
+func (c *Jellyfin) UpdatePlaylist(ctx context.Context) error {
 	isPublic := c.Cfg.PublicPlaylist
 	if c.Cfg.Creds.User == "" {
 		isPublic = true
@@ -270,27 +295,27 @@ func (c *Jellyfin) UpdatePlaylist() error {
 		"ProviderIds":{}
 		}`, c.Cfg.PlaylistID, c.Cfg.PlaylistName, c.Cfg.PlaylistDescr, isPublic) // the additional fields have to be added, otherwise JF returns code 400
 
-	if _, err := c.HttpClient.MakeRequest("POST", c.Cfg.URL+queryParams, bytes.NewBuffer(payload), c.Cfg.Creds.Headers); err != nil {
+	if _, err := c.HttpClient.MakeRequest(ctx, "POST", c.Cfg.URL+queryParams, bytes.NewBuffer(payload), c.Cfg.Creds.Headers); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *Jellyfin) DeletePlaylist() error {
+func (c *Jellyfin) DeletePlaylist(ctx context.Context) error {
 	queryParams := fmt.Sprintf("/Items/%s", c.Cfg.PlaylistID)
 
-	if _, err := c.HttpClient.MakeRequest("DELETE", c.Cfg.URL+queryParams, nil, c.Cfg.Creds.Headers); err != nil {
+	if _, err := c.HttpClient.MakeRequest(ctx, "DELETE", c.Cfg.URL+queryParams, nil, c.Cfg.Creds.Headers); err != nil {
 		return fmt.Errorf("deleyeJfPlaylist(): %s", err.Error())
 	}
 	return nil
 }
 
 // SetPlaylistArtwork uploads a JPEG as the playlist's primary image.
-func (c *Jellyfin) SetPlaylistArtwork(localPath string) error {
+func (c *Jellyfin) SetPlaylistArtwork(ctx context.Context, localPath string) error {
 	if c.Cfg.PlaylistID == "" {
 		return fmt.Errorf("jellyfin: no PlaylistID set")
 	}
-	return uploadPlaylistArtwork(c.HttpClient, c.Cfg.URL+"/Items/"+c.Cfg.PlaylistID+"/Images/Primary", localPath, c.Cfg.Creds.Headers)
+	return uploadPlaylistArtwork(ctx, c.HttpClient, c.Cfg.URL+"/Items/"+c.Cfg.PlaylistID+"/Images/Primary", localPath, c.Cfg.Creds.Headers)
 }
 
 func formatJFSongs(tracks []*models.Track) ([]byte, error) { // marshal track IDs
@@ -307,8 +332,8 @@ func formatJFSongs(tracks []*models.Track) ([]byte, error) { // marshal track ID
 	return songs, nil
 }
 
-func (c *Jellyfin) ResolveUserID() (string, error) {
-	body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+"/Users", nil, c.Cfg.Creds.Headers)
+func (c *Jellyfin) ResolveUserID(ctx context.Context) (string, error) {
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+"/Users", nil, c.Cfg.Creds.Headers)
 	if err != nil {
 		return "", err
 	}
@@ -325,6 +350,61 @@ func (c *Jellyfin) ResolveUserID() (string, error) {
 	return "", fmt.Errorf("failed to find Jellyfin user %q", c.Cfg.Creds.User)
 }
 
+// GetRatings reads favourites and likes of SYSTEM_USERNAME
+func (c *Jellyfin) GetRatings(ctx context.Context, ids []string) (map[string]int, error) {
+	ratings := make(map[string]int, len(ids))
+	err := c.userItems(ctx, ids, func(item JFUserItem) {
+		data := item.UserData
+		switch {
+		case data.IsFavorite || (data.Likes != nil && *data.Likes):
+			ratings[item.ID] = RatingLove
+		case data.Likes != nil:
+			ratings[item.ID] = RatingHate
+		default:
+			ratings[item.ID] = RatingNone
+		}
+	})
+	return ratings, err
+}
+
+// GetPlays returns the last play of each track by SYSTEM_USERNAME, Jellyfin doesn't keep
+// a play history
+func (c *Jellyfin) GetPlays(ctx context.Context, ids []string, since time.Time) ([]Play, error) {
+	var plays []Play
+	err := c.userItems(ctx, ids, func(item JFUserItem) {
+		if played := item.UserData.LastPlayedDate; played.After(since) {
+			plays = append(plays, Play{ID: item.ID, PlayedAt: played})
+		}
+	})
+	return plays, err
+}
+
+// userItems reads the items with their user data for SYSTEM_USERNAME, 100 items per request
+func (c *Jellyfin) userItems(ctx context.Context, ids []string, fn func(JFUserItem)) error {
+	if c.Cfg.Creds.User == "" {
+		return fmt.Errorf("SYSTEM_USERNAME is required to read user data")
+	}
+	userID, err := c.ResolveUserID(ctx)
+	if err != nil {
+		return err
+	}
+	for batch := range slices.Chunk(ids, 100) {
+		reqParam := fmt.Sprintf("/Users/%s/Items?Ids=%s&EnableUserData=true", userID, strings.Join(batch, ","))
+		body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+reqParam, nil, c.Cfg.Creds.Headers)
+		if err != nil {
+			return err
+		}
+		var items JFUserItems
+		if err = util.ParseResp(body, &items); err != nil {
+			return err
+		}
+		for _, item := range items.Items {
+			fn(item)
+		}
+	}
+	return nil
+}
+
 // Check which API Key variable is used
 func (c *Jellyfin) resolveAPIKey() string {
 	if c.Cfg.AdminCreds.APIKey != "" {
@@ -332,3 +412,8 @@ func (c *Jellyfin) resolveAPIKey() string {
 	}
 	return c.Cfg.Creds.APIKey
 }
+
+// PlaylistID returns the ID of the playlist found or created during this run
+func (c *Jellyfin) PlaylistID() string {
+	return c.Cfg.PlaylistID
+}
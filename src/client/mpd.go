@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -19,23 +20,23 @@ func NewMPD(cfg config.ClientConfig) *MPD {
 	return &MPD{Cfg: cfg}
 }
 
-func (c *MPD) GetLibrary() error {
+func (c *MPD) GetLibrary(ctx context.Context) error {
 	return nil
 }
 
-func (c *MPD) GetAuth() error {
+func (c *MPD) GetAuth(ctx context.Context) error {
 	return nil
 }
 
-func (c *MPD) AddHeader() error {
+func (c *MPD) AddHeader(ctx context.Context) error {
 	return nil
 }
 
-func (c *MPD) AddLibrary() error {
+func (c *MPD) AddLibrary(ctx context.Context) error {
 	return nil
 }
 
-func (c *MPD) SearchSongs(tracks []*models.Track) error {
+func (c *MPD) SearchSongs(ctx context.Context, tracks []*models.Track) error {
 	for i := range tracks {
 		if tracks[i].File == "" {
 			continue
@@ -55,15 +56,15 @@ func (c *MPD) SearchSongs(tracks []*models.Track) error {
 	return nil
 }
 
-func (c *MPD) RefreshLibrary() error {
+func (c *MPD) RefreshLibrary(ctx context.Context) error {
 	return nil
 }
 
-func (c *MPD) CheckRefreshState() bool {
+func (c *MPD) CheckRefreshState(ctx context.Context) bool {
 	return true
 }
 
-func (c *MPD) CreatePlaylist(tracks []*models.Track) error {
+func (c *MPD) CreatePlaylist(ctx context.Context, tracks []*models.Track) error {
 	f, err := os.OpenFile(c.Cfg.PlaylistDir+c.Cfg.PlaylistName+".m3u", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
 	if err != nil {
 		return err
@@ -80,7 +81,7 @@ func (c *MPD) CreatePlaylist(tracks []*models.Track) error {
 	return nil
 }
 
-func (c *MPD) SearchPlaylist() error {
+func (c *MPD) SearchPlaylist(ctx context.Context) error {
 	if _, err := os.Stat(c.Cfg.PlaylistDir+c.Cfg.PlaylistName+".m3u"); errors.Is(err, os.ErrNotExist) {
 		return fmt.Errorf("did not find playlist: %s", c.Cfg.PlaylistName)
 	} else {
@@ -89,11 +90,11 @@ func (c *MPD) SearchPlaylist() error {
 	}
 }
 
-func (c *MPD) UpdatePlaylist() error {
+func (c *MPD) UpdatePlaylist(ctx context.Context) error {
 	return nil
 }
 
-func (c *MPD) DeletePlaylist() error {
+func (c *MPD) DeletePlaylist(ctx context.Context) error {
 	if c.Cfg.PlaylistID != "" {
 		if err := os.Remove(c.Cfg.PlaylistID); err != nil {
 			return fmt.Errorf("failed to delete playlist: %s", err.Error())
@@ -121,4 +122,9 @@ func (c MPD) findTrack(name, path string) (string, error) {
    }
 
    return "", fmt.Errorf("no file found named %s in %s: %s", name, path, err)
-}
\ No newline at end of file
+}
+
+// PlaylistID returns the ID of the playlist found or created during this run
+func (c *MPD) PlaylistID() string {
+	return c.Cfg.PlaylistID
+}
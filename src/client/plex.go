@@ -1,13 +1,17 @@
 package client
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"log/slog"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"explo/src/config"
 	"explo/src/models"
@@ -134,6 +138,33 @@ type PlexPlaylist struct {
 	} `json:"MediaContainer"`
 }
 
+type PlexRating struct {
+	MediaContainer struct {
+		Metadata []struct {
+			UserRating float64 `json:"userRating"` // 0-10, two per star
+		} `json:"Metadata"`
+	} `json:"MediaContainer"`
+}
+
+type PlexHistory struct {
+	MediaContainer struct {
+		Metadata []struct {
+			Key       string `json:"key"`
+			ViewedAt  int64  `json:"viewedAt"`
+			AccountID int64  `json:"accountID"`
+		} `json:"Metadata"`
+	} `json:"MediaContainer"`
+}
+
+type PlexAccounts struct {
+	MediaContainer struct {
+		Account []struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		} `json:"Account"`
+	} `json:"MediaContainer"`
+}
+
 type GUID struct {
 	ID string `json:"id"`
 }
@@ -163,13 +194,13 @@ func (c *Plex) cloneHeaders() map[string]string {
 	return h
 }
 
-func (c *Plex) getSharedServers() ([]PlexSharedUser, error) {
+func (c *Plex) getSharedServers(ctx context.Context) ([]PlexSharedUser, error) {
 	url := fmt.Sprintf(
 		"https://plex.tv/api/servers/%s/shared_servers",
 		c.machineID,
 	)
 
-	body, err := c.HttpClient.MakeRequest("GET", url, nil, c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", url, nil, c.Cfg.Creds.Headers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch shared servers: %w", err)
 	}
@@ -181,8 +212,8 @@ func (c *Plex) getSharedServers() ([]PlexSharedUser, error) {
 
 	return resp.SharedServers, nil
 }
-func (c *Plex) findSharedUser(username string) (*PlexSharedUser, error) {
-	users, err := c.getSharedServers()
+func (c *Plex) findSharedUser(ctx context.Context, username string) (*PlexSharedUser, error) {
+	users, err := c.getSharedServers(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -211,8 +242,8 @@ func (c *Plex) findSharedUser(username string) (*PlexSharedUser, error) {
 	return nil, fmt.Errorf("unable to find shared user: %s", username)
 }
 
-func (c *Plex) SwitchUser(username string) (*Plex, error) {
-	user, err := c.findSharedUser(username)
+func (c *Plex) SwitchUser(ctx context.Context, username string) (*Plex, error) {
+	user, err := c.findSharedUser(ctx, username)
 	if err != nil {
 		return nil, err
 	}
@@ -230,16 +261,16 @@ func (c *Plex) SwitchUser(username string) (*Plex, error) {
 
 	return &newClient, nil
 }
-func (c *Plex) ensureUserClient() (*Plex, error) {
+func (c *Plex) ensureUserClient(ctx context.Context) (*Plex, error) {
 	// If no admin client, assume already user-scoped
 	if c.AdminClient == nil {
 		return c, nil
 	}
 
 	// Switch using admin client (correct source of truth)
-	return c.AdminClient.SwitchUser(c.Cfg.Creds.User)
+	return c.AdminClient.SwitchUser(ctx, c.Cfg.Creds.User)
 }
-func (c *Plex) AddHeader() error {
+func (c *Plex) AddHeader(ctx context.Context) error {
 	if c.Cfg.Creds.Headers == nil {
 		c.Cfg.Creds.Headers = make(map[string]string)
 		c.Cfg.Creds.Headers["X-Plex-Client-Identifier"] = c.Cfg.ClientID
@@ -248,7 +279,7 @@ func (c *Plex) AddHeader() error {
 	}
 	if c.Cfg.Creds.APIKey != "" {
 		c.Cfg.Creds.Headers["X-Plex-Token"] = c.Cfg.Creds.APIKey
-		if err := c.getServer(); err != nil {
+		if err := c.getServer(ctx); err != nil {
 			println(err)
 			return err
 		}
@@ -257,7 +288,7 @@ func (c *Plex) AddHeader() error {
 	return fmt.Errorf("couldn't get API key")
 }
 
-func (c *Plex) GetAuth() error { // Get user token from plex
+func (c *Plex) GetAuth(ctx context.Context) error { // Get user token from plex
 	payload := LoginPayload{
 		Login:    c.Cfg.Creds.User,
 		Password: c.Cfg.Creds.Password,
@@ -267,7 +298,7 @@ func (c *Plex) GetAuth() error { // Get user token from plex
 	if err != nil {
 		return fmt.Errorf("failed to marshal payload: %s", err.Error())
 	}
-	body, err := c.HttpClient.MakeRequest("POST", url, bytes.NewBuffer(payloadBytes), c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "POST", url, bytes.NewBuffer(payloadBytes), c.Cfg.Creds.Headers)
 
 	if err != nil {
 		return fmt.Errorf("%s", err.Error())
@@ -282,7 +313,7 @@ func (c *Plex) GetAuth() error { // Get user token from plex
 	c.Cfg.Creds.Headers["X-Plex-Token"] = auth.AuthToken
 	return nil
 }
-func (c *Plex) GetLibrary() error {
+func (c *Plex) GetLibrary(ctx context.Context) error {
 	if (c.Cfg.AdminCreds.User != "" && c.Cfg.AdminCreds.Password != "")  {
 		adminCfg := c.Cfg
 		adminCfg.Creds = config.Credentials{
@@ -291,14 +322,14 @@ func (c *Plex) GetLibrary() error {
 		}
 
 		c.AdminClient = NewPlex(adminCfg, c.HttpClient)
-		if err := c.AdminClient.AddHeader(); err != nil {
+		if err := c.AdminClient.AddHeader(ctx); err != nil {
 			return err
 		}
-		if err := c.AdminClient.GetAuth(); err != nil {
+		if err := c.AdminClient.GetAuth(ctx); err != nil {
 			return err
 		}
 
-		err := c.AdminClient.getLibraryRequest()
+		err := c.AdminClient.getLibraryRequest(ctx)
 		if err != nil {
 			return err
 		}
@@ -312,10 +343,10 @@ func (c *Plex) GetLibrary() error {
 		}
 
 		c.AdminClient = NewPlex(adminCfg, c.HttpClient)
-		if err := c.AdminClient.AddHeader(); err != nil {
+		if err := c.AdminClient.AddHeader(ctx); err != nil {
 			return err
 		}
-		err := c.AdminClient.getLibraryRequest()
+		err := c.AdminClient.getLibraryRequest(ctx)
 		if err != nil {
 			return err
 		}
@@ -323,12 +354,12 @@ func (c *Plex) GetLibrary() error {
 
 		return err
 	}
-	return c.getLibraryRequest()
+	return c.getLibraryRequest(ctx)
 }
 
-func (c *Plex) getLibraryRequest() error {
+func (c *Plex) getLibraryRequest(ctx context.Context) error {
 	params := "/library/sections/all"
-	body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers)
 	if err != nil {
 		return fmt.Errorf("failed to make request to plex: %s", err.Error())
 	}
@@ -345,17 +376,17 @@ func (c *Plex) getLibraryRequest() error {
 			return nil
 		}
 	}
-	if err = c.AddLibrary(); err != nil {
+	if err = c.AddLibrary(ctx); err != nil {
 		slog.Debug(err.Error())
 		return fmt.Errorf("library named %s not found and cannot be added, please create it manually and ensure 'Prefer local metadata' is checked", c.Cfg.LibraryName)
 	}
 	return fmt.Errorf("library '%s' not found", c.Cfg.LibraryName)
 }
 
-func (c *Plex) AddLibrary() error {
+func (c *Plex) AddLibrary(ctx context.Context) error {
 	params := fmt.Sprintf("/library/sections?name=%s&type=artist&scanner=Plex+Music&agent=tv.plex.agents.music&language=en-US&location=%s&prefs[respectTags]=1", c.Cfg.LibraryName, c.Cfg.DownloadDir)
 
-	body, err := c.HttpClient.MakeRequest("POST", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "POST", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers)
 	if err != nil {
 		return err
 	}
@@ -367,27 +398,27 @@ func (c *Plex) AddLibrary() error {
 	c.LibraryID = libraries.MediaContainer.Library[0].Key
 	return nil
 }
-func (c *Plex) RefreshLibrary() error {
+func (c *Plex) RefreshLibrary(ctx context.Context) error {
 	if c.AdminClient != nil {
-		return c.AdminClient.refreshLibraryRequest()
+		return c.AdminClient.refreshLibraryRequest(ctx)
 	}
 
-	return c.refreshLibraryRequest()
+	return c.refreshLibraryRequest(ctx)
 }
 
-func (c *Plex) refreshLibraryRequest() error {
+func (c *Plex) refreshLibraryRequest(ctx context.Context) error {
 	params := fmt.Sprintf("/library/sections/%s/refresh", c.LibraryID)
 
-	if _, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers); err != nil {
+	if _, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers); err != nil {
 		return fmt.Errorf("refreshPlexLibrary(): %s", err.Error())
 	}
 	return nil
 }
 
-func (c *Plex) CheckRefreshState() bool {
+func (c *Plex) CheckRefreshState(ctx context.Context) bool {
 	return false
 }
-func (c *Plex) SearchSongs(tracks []*models.Track) error {
+func (c *Plex) SearchSongs(ctx context.Context, tracks []*models.Track) error {
 	for _, track := range tracks {
 		params := fmt.Sprintf(
 			"/hubs/search?query=%s&limit=10",
@@ -398,14 +429,14 @@ func (c *Plex) SearchSongs(tracks []*models.Track) error {
 		var err error
 
 		if c.AdminClient != nil {
-			body, err = c.HttpClient.MakeRequest(
+			body, err = c.HttpClient.MakeRequest(ctx,
 				"GET",
 				c.Cfg.URL+params,
 				nil,
 				c.AdminClient.Cfg.Creds.Headers,
 			)
 		} else {
-			body, err = c.HttpClient.MakeRequest(
+			body, err = c.HttpClient.MakeRequest(ctx,
 				"GET",
 				c.Cfg.URL+params,
 				nil,
@@ -434,7 +465,7 @@ func (c *Plex) SearchSongs(tracks []*models.Track) error {
 			}
 		}
 
-		key, err := c.getPlexSong(track, all)
+		key, err := c.getPlexSong(ctx, track, all)
 		if err != nil {
 			slog.Warn("failed to find match", "title", track.Title, "err", err)
 			continue
@@ -451,10 +482,10 @@ func (c *Plex) SearchSongs(tracks []*models.Track) error {
 
 	return nil
 }
-func (c *Plex) SearchPlaylist() error {
+func (c *Plex) SearchPlaylist(ctx context.Context) error {
 	params := "/playlists"
 
-	body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers)
 	if err != nil {
 		return err
 	}
@@ -473,7 +504,7 @@ func (c *Plex) SearchPlaylist() error {
 	return nil
 }
 
-func (c *Plex) CreatePlaylist(tracks []*models.Track) error {
+func (c *Plex) CreatePlaylist(ctx context.Context, tracks []*models.Track) error {
 	if len(tracks) == 0 {
 		return fmt.Errorf("no tracks provided")
 	}
@@ -481,7 +512,7 @@ func (c *Plex) CreatePlaylist(tracks []*models.Track) error {
 	var err error
 	if c.AdminClient != nil {
 		c.AdminClient.machineID = c.machineID
-		userClient, err = c.ensureUserClient()
+		userClient, err = c.ensureUserClient(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to switch user: %w", err)
 		}
@@ -503,7 +534,7 @@ func (c *Plex) CreatePlaylist(tracks []*models.Track) error {
 
 	headers := userClient.cloneHeaders()
 
-	body, err := userClient.HttpClient.MakeRequest(
+	body, err := userClient.HttpClient.MakeRequest(ctx,
 		"POST",
 		userClient.Cfg.URL+params,
 		nil,
@@ -524,42 +555,42 @@ func (c *Plex) CreatePlaylist(tracks []*models.Track) error {
 
 	userClient.Cfg.PlaylistID = playlist.MediaContainer.Metadata[0].RatingKey
 
-	userClient.addtoPlaylist(tracks)
+	userClient.addtoPlaylist(ctx, tracks)
 
 	c.Cfg.PlaylistID = userClient.Cfg.PlaylistID
 
 	return nil
 }
-func (c *Plex) UpdatePlaylist() error {
+func (c *Plex) UpdatePlaylist(ctx context.Context) error {
 	params := fmt.Sprintf("/playlists/%s?summary=%s", c.Cfg.PlaylistID, url.QueryEscape(c.Cfg.PlaylistDescr))
 
-	if _, err := c.HttpClient.MakeRequest("PUT", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers); err != nil {
+	if _, err := c.HttpClient.MakeRequest(ctx, "PUT", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *Plex) DeletePlaylist() error {
+func (c *Plex) DeletePlaylist(ctx context.Context) error {
 	params := fmt.Sprintf("/playlists/%s", c.Cfg.PlaylistID)
 
-	if _, err := c.HttpClient.MakeRequest("DELETE", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers); err != nil {
+	if _, err := c.HttpClient.MakeRequest(ctx, "DELETE", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers); err != nil {
 		return err
 	}
 	return nil
 }
 
 // SetPlaylistArtwork uploads an image as the playlist's poster.
-func (c *Plex) SetPlaylistArtwork(localPath string) error {
+func (c *Plex) SetPlaylistArtwork(ctx context.Context, localPath string) error {
 	if c.Cfg.PlaylistID == "" {
 		return fmt.Errorf("plex: no PlaylistID set")
 	}
-	return uploadPlaylistArtwork(c.HttpClient, c.Cfg.URL+"/library/metadata/"+c.Cfg.PlaylistID+"/posters", localPath, c.Cfg.Creds.Headers)
+	return uploadPlaylistArtwork(ctx, c.HttpClient, c.Cfg.URL+"/library/metadata/"+c.Cfg.PlaylistID+"/posters", localPath, c.Cfg.Creds.Headers)
 }
 
-func (c *Plex) getServer() error {
+func (c *Plex) getServer(ctx context.Context) error {
 	params := "/identity"
 
-	body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers)
 	if err != nil {
 		return fmt.Errorf("failed to get server ID: %s", err.Error())
 	}
@@ -573,21 +604,23 @@ func (c *Plex) getServer() error {
 	return nil
 }
 
-func (c *Plex) getPlexSong(track *models.Track, metadata []SongMetadata) (string, error) {
+func (c *Plex) getPlexSong(ctx context.Context, track *models.Track, metadata []SongMetadata) (string, error) {
 	normArtist := util.AlnumOnly(track.MainArtist)
 	normalizedCleanTitle := util.NormalizeTitle(track.CleanTitle)
 	normalizedAlbum := util.AlnumOnly(strings.ToLower(track.Album))
 
+	bestKey, bestRank := "", matchNone
 	for _, md := range metadata {
 		if md.Type != "track" {
 			continue
 		}
 
                 var mbid string;
+                var isrcs []string
                 if c.AdminClient != nil {
-                    mbid = c.AdminClient.getPlexMBID(md.RatingKey)
+                    mbid, isrcs = c.AdminClient.getPlexIDs(ctx, md.RatingKey)
                 } else {
-                    mbid = c.getPlexMBID(md.RatingKey)
+                    mbid, isrcs = c.getPlexIDs(ctx, md.RatingKey)
                 }
 
 		normalizedSongTitle := util.NormalizeTitle(md.Title)
@@ -596,11 +629,29 @@ func (c *Plex) getPlexSong(track *models.Track, metadata []SongMetadata) (string
 		albumMatch := util.AlnumOnly(strings.ToLower(md.ParentTitle)) == normalizedAlbum
 		artistMatch := util.ContainsFold(util.AlnumOnly(md.OriginalTitle), normArtist) || util.ContainsFold(util.AlnumOnly(md.GrandparentTitle), normArtist)
 
-		if musicBrainzMatch || (titleMatch && (albumMatch || artistMatch)) {
-			slog.Debug("matched track via metadata", "title", track.Title, "artist", track.Artist)
+		if musicBrainzMatch {
+			slog.Debug("matched track via MBID", "title", track.Title, "artist", track.Artist)
 			return md.Key, nil
 		}
 
+		if isrcMatch(track, isrcs...) {
+			if bestRank < matchISRC {
+				slog.Debug("matched track via ISRC", "title", track.Title, "artist", track.Artist)
+				bestKey, bestRank = md.Key, matchISRC
+			}
+			continue
+		}
+
+		if bestRank >= matchFuzzy {
+			continue
+		}
+
+		if titleMatch && (albumMatch || artistMatch) {
+			slog.Debug("matched track via metadata", "title", track.Title, "artist", track.Artist)
+			bestKey, bestRank = md.Key, matchFuzzy
+			continue
+		}
+
 		if track.File == "" || len(md.Media) == 0 || len(md.Media[0].Part) == 0 {
 			continue
 		}
@@ -611,44 +662,161 @@ func (c *Plex) getPlexSong(track *models.Track, metadata []SongMetadata) (string
 
 		if durationMatch && pathMatch {
 			slog.Debug("matched track via path", "title", track.Title, "artist", track.Artist)
-			return md.Key, nil
+			bestKey, bestRank = md.Key, matchFuzzy
 		}
 	}
 
+	if bestRank > matchNone {
+		return bestKey, nil
+	}
+
 	slog.Debug(fmt.Sprintf("full search result: %v", metadata))
 	return "", fmt.Errorf("failed to find '%s' by '%s' in '%s'", track.Title, track.Artist, track.Album)
 }
 
-func (c *Plex) getPlexMBID(ratingKey string) string {
+// getPlexIDs returns the MusicBrainz ID and ISRCs Plex has stored in a track's GUIDs
+func (c *Plex) getPlexIDs(ctx context.Context, ratingKey string) (string, []string) {
 	params := fmt.Sprintf("/library/metadata/%s", ratingKey)
 
 
-	body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers)
 	if err != nil {
-		return ""
+		return "", nil
 	}
 
 	var metadata Metadata
 	if err = util.ParseResp(body, &metadata); err != nil {
-		return ""
+		return "", nil
 	}
-	prefix := "mbid://"
+
+	var mbid string
+	var isrcs []string
 	for _, guid := range metadata.GUID {
-		if strings.HasPrefix(guid.ID, prefix) {
-			return strings.TrimPrefix(guid.ID, prefix)
+		if id, ok := strings.CutPrefix(guid.ID, "mbid://"); ok && mbid == "" {
+			mbid = id
+		} else if id, ok := strings.CutPrefix(guid.ID, "isrc://"); ok {
+			isrcs = append(isrcs, id)
 		}
 	}
-	return ""
+	return mbid, isrcs
 }
 
-func (c *Plex) addtoPlaylist(tracks []*models.Track) {
+// GetRatings reads the star ratings of the playlist's user: 4 stars and up is loved,
+// 1 star is hated. Library IDs are metadata keys (/library/metadata/<ratingKey>).
+func (c *Plex) GetRatings(ctx context.Context, ids []string) (map[string]int, error) {
+	userClient, err := c.ensureUserClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ratings := make(map[string]int, len(ids))
+	for _, id := range ids {
+		body, err := userClient.HttpClient.MakeRequest(ctx, "GET", userClient.Cfg.URL+id, nil, userClient.Cfg.Creds.Headers)
+		if err != nil {
+			slog.Debug("[plex] failed to get track", "id", id, "err", err.Error())
+			continue
+		}
+		var resp PlexRating
+		if err := util.ParseResp(body, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp.MediaContainer.Metadata) == 0 {
+			continue
+		}
+		switch rating := resp.MediaContainer.Metadata[0].UserRating; {
+		case rating >= 8:
+			ratings[id] = RatingLove
+		case rating > 0 && rating <= 2:
+			ratings[id] = RatingHate
+		default:
+			ratings[id] = RatingNone
+		}
+	}
+	return ratings, nil
+}
+
+// GetPlays reads the playlist user's play history of the library
+func (c *Plex) GetPlays(ctx context.Context, ids []string, since time.Time) ([]Play, error) {
+	userClient, err := c.ensureUserClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accountID, err := c.accountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params := fmt.Sprintf("/status/sessions/history/all?sort=viewedAt:desc&librarySectionID=%s&accountID=%d&viewedAt%%3E=%d",
+		c.LibraryID, accountID, since.Unix())
+	body, err := userClient.HttpClient.MakeRequest(ctx, "GET", userClient.Cfg.URL+params, nil, userClient.Cfg.Creds.Headers)
+	if err != nil {
+		return nil, err
+	}
+	var history PlexHistory
+	if err := util.ParseResp(body, &history); err != nil {
+		return nil, err
+	}
+
+	var plays []Play
+	for _, md := range history.MediaContainer.Metadata {
+		// history of the owner's token covers every account, don't trust the filter alone
+		if md.AccountID == accountID && slices.Contains(ids, md.Key) {
+			plays = append(plays, Play{ID: md.Key, PlayedAt: time.Unix(md.ViewedAt, 0)})
+		}
+	}
+	return plays, nil
+}
+
+// accountID returns the server account of the configured user, play history is kept per
+// account. Shared users are looked up with the admin client, otherwise the account is found
+// by SYSTEM_USERNAME, and a token without a username is taken to be the server owner's (account 1).
+func (c *Plex) accountID(ctx context.Context) (int64, error) {
+	if c.AdminClient != nil {
+		user, err := c.AdminClient.findSharedUser(ctx, c.Cfg.Creds.User)
+		if err != nil {
+			return 0, err
+		}
+		id, err := strconv.ParseInt(user.UserID, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid plex user id %q", user.UserID)
+		}
+		return id, nil
+	}
+
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+"/accounts", nil, c.Cfg.Creds.Headers)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get plex accounts: %w", err)
+	}
+	var accounts PlexAccounts
+	if err := util.ParseResp(body, &accounts); err != nil {
+		return 0, err
+	}
+	list := accounts.MediaContainer.Account
+	if c.Cfg.Creds.User != "" {
+		for _, a := range list {
+			if strings.EqualFold(a.Name, c.Cfg.Creds.User) {
+				return a.ID, nil
+			}
+		}
+		return 0, fmt.Errorf("no plex account named %s", c.Cfg.Creds.User)
+	}
+	if len(list) == 1 {
+		return list[0].ID, nil
+	}
+	return 1, nil
+}
+
+func (c *Plex) addtoPlaylist(ctx context.Context, tracks []*models.Track) {
 	for _, track := range tracks {
 		if track.ID != "" {
 			params := fmt.Sprintf("/playlists/%s/items?uri=server://%s/com.plexapp.plugins.library%s", c.Cfg.PlaylistID, c.machineID, track.ID)
 
-			if _, err := c.HttpClient.MakeRequest("PUT", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers); err != nil {
+			if _, err := c.HttpClient.MakeRequest(ctx, "PUT", c.Cfg.URL+params, nil, c.Cfg.Creds.Headers); err != nil {
 				slog.Warn("failed to add to playlist", "title", track.Title, "err", err)
 			}
 		}
 	}
 }
+
+// PlaylistID returns the ID of the playlist found or created during this run
+func (c *Plex) PlaylistID() string {
+	return c.Cfg.PlaylistID
+}
@@ -1,8 +1,10 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
@@ -41,6 +43,7 @@ type SubResponse struct {
 				Album         string    `json:"album"`
 				Duration      int       `json:"duration"`
 				MusicBrainzID string    `json:"musicBrainzId"`
+				ISRC          []string  `json:"isrc"` // OpenSubsonic extension
 				Path          string    `json:"path"`
 			} `json:"song"`
 		} `json:"searchResult3"`
@@ -51,6 +54,28 @@ type SubResponse struct {
 	} `json:"subsonic-response"`
 }
 
+type SubSong struct {
+	SubsonicResponse struct {
+		Song struct {
+			ID         string `json:"id"`
+			Starred    string `json:"starred"`    // time the song was starred, empty if it isn't
+			UserRating int    `json:"userRating"` // 1-5 stars, 0 if not rated
+		} `json:"song"`
+	} `json:"subsonic-response"`
+}
+
+type SubNowPlaying struct {
+	SubsonicResponse struct {
+		NowPlaying struct {
+			Entry []struct {
+				ID         string `json:"id"`
+				Username   string `json:"username"`
+				MinutesAgo int    `json:"minutesAgo"`
+			} `json:"entry"`
+		} `json:"nowPlaying"`
+	} `json:"subsonic-response"`
+}
+
 type Playlist struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
@@ -94,11 +119,11 @@ func NewSubsonic(cfg config.ClientConfig, httpClient *util.HttpClient) *Subsonic
 		HttpClient: httpClient}
 }
 
-func (c *Subsonic) AddHeader() error {
+func (c *Subsonic) AddHeader(ctx context.Context) error {
 	return nil
 }
 
-func (c *Subsonic) GetAuth() error { // Generate salt and token
+func (c *Subsonic) GetAuth(ctx context.Context) error { // Generate salt and token
 	var salt = make([]byte, 6)
 
 
@@ -117,20 +142,20 @@ func (c *Subsonic) GetAuth() error { // Generate salt and token
 	return nil
 }
 
-func (c *Subsonic) GetLibrary() error {
+func (c *Subsonic) GetLibrary(ctx context.Context) error {
 	return nil
 }
 
-func (c *Subsonic) AddLibrary() error {
+func (c *Subsonic) AddLibrary(ctx context.Context) error {
 	return nil
 }
 
-func (c *Subsonic) SearchSongs(tracks []*models.Track) error {
+func (c *Subsonic) SearchSongs(ctx context.Context, tracks []*models.Track) error {
 	for _, track := range tracks {
 		searchQuery := fmt.Sprintf("%s %s", util.CleanSearchTitle(track.CleanTitle), track.MainArtist)
 		reqParam := fmt.Sprintf("search3?query=%s&f=json", url.QueryEscape(searchQuery))
 
-		body, err := c.subsonicRequest(reqParam)
+		body, err := c.subsonicRequest(ctx, reqParam)
 		if err != nil {
 			return err
 		}
@@ -146,6 +171,7 @@ func (c *Subsonic) SearchSongs(tracks []*models.Track) error {
 			continue
 		}
 		normalizedCleanTitle := util.NormalizeTitle(track.CleanTitle)
+		bestRank := matchNone
 		for _, song := range songs {
 			normalizedSongTitle := util.NormalizeTitle(song.Title)
 
@@ -156,15 +182,22 @@ func (c *Subsonic) SearchSongs(tracks []*models.Track) error {
 			durationMatch := util.Abs(song.Duration - (track.Duration / 1000)) < 10
 			pathMatch := util.ContainsFold(song.Path, track.File)
 
-			if musicBrainzMatch || (titleMatch && (albumMatch || artistMatch)) {
-				track.ID = song.ID
-				track.Present = true
-				break
+			rank := matchNone
+			switch {
+			case musicBrainzMatch:
+				rank = matchMBID
+			case isrcMatch(track, song.ISRC...):
+				rank = matchISRC
+			case titleMatch && (albumMatch || artistMatch), track.File != "" && durationMatch && pathMatch:
+				rank = matchFuzzy
 			}
 
-			if track.File != "" && durationMatch && pathMatch {
+			if rank > bestRank {
+				bestRank = rank
 				track.ID = song.ID
 				track.Present = true
+			}
+			if rank == matchMBID {
 				break
 			}
 		}
@@ -176,35 +209,35 @@ func (c *Subsonic) SearchSongs(tracks []*models.Track) error {
 	return nil
 }
 
-func (c *Subsonic) RefreshLibrary() error {
+func (c *Subsonic) RefreshLibrary(ctx context.Context) error {
 	if c.Cfg.AdminCreds.User != "" && c.Cfg.AdminCreds.Password != "" {
 		adminCfg := c.Cfg
 		adminCfg.Creds = config.Credentials{User: c.Cfg.AdminCreds.User, Password: c.Cfg.AdminCreds.Password}
 		adminClient := NewSubsonic(adminCfg, c.HttpClient)
 
-		if err := adminClient.GetAuth(); err != nil {
+		if err := adminClient.GetAuth(ctx); err != nil {
 			return err
 		}
-		return adminClient.startScan()
+		return adminClient.startScan(ctx)
 	}
 
-	return c.startScan()
+	return c.startScan(ctx)
 }
 
-func (c *Subsonic) startScan() error {
+func (c *Subsonic) startScan(ctx context.Context) error {
 	reqParam := "startScan?f=json"
-	if _, err := c.subsonicRequest(reqParam); err != nil {
+	if _, err := c.subsonicRequest(ctx, reqParam); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *Subsonic) CheckRefreshState() bool {
+func (c *Subsonic) CheckRefreshState(ctx context.Context) bool {
 	var state ScanState
 	reqParam := "getScanStatus?f=json"
 
 	for {
-		body, err := c.subsonicRequest(reqParam)
+		body, err := c.subsonicRequest(ctx, reqParam)
 		if err != nil {
 			slog.Warn("could not check scan status", "err", err.Error())
 			return false
@@ -217,11 +250,13 @@ func (c *Subsonic) CheckRefreshState() bool {
 			return true
 		}
 		slog.Debug("Library scan still ongoing")
-		time.Sleep(30 * time.Second)
+		if err := util.Sleep(ctx, 30*time.Second); err != nil {
+			return false
+		}
 	}
 }
 
-func (c *Subsonic) CreatePlaylist(tracks []*models.Track) error {
+func (c *Subsonic) CreatePlaylist(ctx context.Context, tracks []*models.Track) error {
 	var trackIDs strings.Builder
 	for _, track := range tracks { // build songID parameters
 		fmt.Fprintf(&trackIDs, "&songId=%s", track.ID)
@@ -229,7 +264,7 @@ func (c *Subsonic) CreatePlaylist(tracks []*models.Track) error {
 
 	reqParam := fmt.Sprintf("createPlaylist?name=%s%s&f=json", url.QueryEscape(c.Cfg.PlaylistName), trackIDs.String())
 
-	body, err := c.subsonicRequest(reqParam)
+	body, err := c.subsonicRequest(ctx, reqParam)
 	if err != nil {
 		return err
 	}
@@ -243,10 +278,10 @@ func (c *Subsonic) CreatePlaylist(tracks []*models.Track) error {
 	return nil
 }
 
-func (c *Subsonic) SearchPlaylist() error {
+func (c *Subsonic) SearchPlaylist(ctx context.Context) error {
 	reqParam := "getPlaylists?f=json"
 
-	body, err := c.subsonicRequest(reqParam)
+	body, err := c.subsonicRequest(ctx, reqParam)
 	if err != nil {
 		return err
 	}
@@ -266,28 +301,79 @@ func (c *Subsonic) SearchPlaylist() error {
 	return nil
 }
 
-func (c *Subsonic) UpdatePlaylist() error {
+func (c *Subsonic) UpdatePlaylist(ctx context.Context) error {
 	reqParam := fmt.Sprintf("updatePlaylist?playlistId=%s&comment=%s&f=json&public=%t",c.Cfg.PlaylistID, url.QueryEscape(c.Cfg.PlaylistDescr), c.Cfg.PublicPlaylist)
 
-	if _, err := c.subsonicRequest(reqParam); err != nil {
+	if _, err := c.subsonicRequest(ctx, reqParam); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *Subsonic) DeletePlaylist() error {
+func (c *Subsonic) DeletePlaylist(ctx context.Context) error {
 	reqParam := fmt.Sprintf("deletePlaylist?id=%s&f=json", c.Cfg.PlaylistID)
 
-	if _, err := c.subsonicRequest(reqParam); err != nil {
+	if _, err := c.subsonicRequest(ctx, reqParam); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *Subsonic) subsonicRequest(reqParams string) ([]byte, error) {
+// GetRatings treats starred songs and 5 star ratings as loved, 1 star ratings as hated
+func (c *Subsonic) GetRatings(ctx context.Context, ids []string) (map[string]int, error) {
+	ratings := make(map[string]int, len(ids))
+	for _, id := range ids {
+		body, err := c.subsonicRequest(ctx, fmt.Sprintf("getSong?id=%s&f=json", url.QueryEscape(id)))
+		if err != nil {
+			slog.Debug("[subsonic] failed to get song", "id", id, "err", err.Error())
+			continue
+		}
+		var resp SubSong
+		if err := util.ParseResp(body, &resp); err != nil {
+			return nil, err
+		}
+		song := resp.SubsonicResponse.Song
+		switch {
+		case song.Starred != "" || song.UserRating == 5:
+			ratings[id] = RatingLove
+		case song.UserRating == 1:
+			ratings[id] = RatingHate
+		default:
+			ratings[id] = RatingNone
+		}
+	}
+	return ratings, nil
+}
+
+// GetPlays returns what SYSTEM_USERNAME is playing right now. Subsonic has no play history,
+// so plays are only seen if this is polled while they're playing.
+func (c *Subsonic) GetPlays(ctx context.Context, ids []string, since time.Time) ([]Play, error) {
+	body, err := c.subsonicRequest(ctx, "getNowPlaying?f=json")
+	if err != nil {
+		return nil, err
+	}
+	var resp SubNowPlaying
+	if err := util.ParseResp(body, &resp); err != nil {
+		return nil, err
+	}
+
+	var plays []Play
+	now := time.Now().Truncate(time.Minute)
+	for _, e := range resp.SubsonicResponse.NowPlaying.Entry {
+		if !strings.EqualFold(e.Username, c.Cfg.Creds.User) || !slices.Contains(ids, e.ID) {
+			continue
+		}
+		if played := now.Add(-time.Duration(e.MinutesAgo) * time.Minute); played.After(since) {
+			plays = append(plays, Play{ID: e.ID, PlayedAt: played})
+		}
+	}
+	return plays, nil
+}
+
+func (c *Subsonic) subsonicRequest(ctx context.Context, reqParams string) ([]byte, error) {
 
 	reqURL := fmt.Sprintf("%s/rest/%s&u=%s&t=%s&s=%s&v=%s&c=%s",c.Cfg.URL, reqParams, c.Cfg.Creds.User, c.Token, c.Salt, c.Cfg.Subsonic.Version, c.Cfg.ClientID)
-	body, err := c.HttpClient.MakeRequest("GET", reqURL, nil, nil)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", reqURL, nil, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request %s", err.Error())
 	}
@@ -299,4 +385,9 @@ func (c *Subsonic) subsonicRequest(reqParams string) ([]byte, error) {
 		return nil, fmt.Errorf("%s", checkResp.SubsonicResponse.Error.Message)
 	}
 	return body, nil
-}
\ No newline at end of file
+}
+
+// PlaylistID returns the ID of the playlist found or created during this run
+func (c *Subsonic) PlaylistID() string {
+	return c.Cfg.PlaylistID
+}
@@ -22,12 +22,16 @@ type Config struct {
 	ClientCfg    ClientConfig
 	NotifyCfg    NotifyConfig
 	ServerCfg    ServerConfig
+	ExportCfg    ExportConfig
 	Flags        Flags
 	PersistENV   bool `env:"PERSIST" env-default:"true"`
 	Persist      bool
 	System       string `env:"EXPLO_SYSTEM"`
 	Debug        bool   `env:"DEBUG" env-default:"false"`
 	LogLevel     string `env:"LOG_LEVEL" env-default:"INFO"`
+	HTTPRetries  int    `env:"HTTP_RETRIES" env-default:"3"`         // retries for failed or rate limited API requests
+	HTTPMaxWait  int    `env:"HTTP_RETRY_MAX_WAIT" env-default:"60"` // max seconds to wait between retries
+	MetricsFile  string `env:"METRICS_TEXTFILE"`                     // node_exporter textfile collector output, written at the end of each run
 }
 
 type Flags struct {
@@ -40,17 +44,54 @@ type Flags struct {
 	PersistSet   bool
 	SearchMBID   string
 	RefreshOnly  bool
+	SyncFeedback bool
+	SyncListens  bool
+	RunID        string
+	Profile      string
+	Export       string
+}
+
+// ExportConfig controls the portable playlist files written after each run
+type ExportConfig struct {
+	Formats       string `env:"EXPORT_FORMATS"`                           // comma separated: m3u8, xspf, jspf (empty disables exports)
+	Dir           string `env:"EXPORT_DIR"`                               // defaults to PLAYLIST_DIR, then the download directory
+	RelativePaths bool   `env:"EXPORT_RELATIVE_PATHS" env-default:"true"` // write track paths relative to the export directory
 }
 
 type ServerConfig struct {
-	Enabled     bool   `env:"WEB_UI" env-default:"false"`
-	Port        string `env:"WEB_ADDR" env-default:":7288"`
-	Username    string `env:"UI_USERNAME"`
-	Password    string `env:"UI_PASSWORD"`
-	WebDataDir  string `env:"WEB_DATA_PATH" env-default:"/opt/explo/config/"`
-	WebEnvPath  string `env:"WEB_ENV_PATH" env-default:"/opt/explo/.env"`
-	CacheSizeMB int64  `env:"WEB_CACHE_MB" env-default:"500"`
-	ExploPath   string
+	Enabled      bool   `env:"WEB_UI" env-default:"false"`
+	Port         string `env:"WEB_ADDR" env-default:":7288"`
+	Username     string `env:"UI_USERNAME"`
+	Password     string `env:"UI_PASSWORD"`
+	WebDataDir   string `env:"WEB_DATA_PATH" env-default:"/opt/explo/config/"`
+	WebEnvPath   string `env:"WEB_ENV_PATH" env-default:"/opt/explo/.env"`
+	CacheSizeMB  int64  `env:"WEB_CACHE_MB" env-default:"500"`
+	HTTPCacheMB  int64  `env:"HTTP_CACHE_MB" env-default:"100"` // MusicBrainz/ListenBrainz response cache, 0 disables it
+	HTTPCacheDir string
+	PreRunCmd    string `env:"PRE_RUN_COMMAND"`                      // shell command run before each scheduled run
+	RunOnStart   bool   `env:"EXECUTE_ON_START" env-default:"false"` // run START_FLAGS once when the web UI starts
+	StartFlags   string `env:"START_FLAGS"`
+
+	Metrics       bool   `env:"METRICS_ENABLED" env-default:"false"` // serve Prometheus metrics on /metrics
+	MetricsToken  string `env:"METRICS_TOKEN"`                       // bearer token for scrapers, besides API tokens
+	MetricsPublic bool   `env:"METRICS_PUBLIC" env-default:"false"`  // serve /metrics without any auth
+
+	AuthProxyHeader    string `env:"AUTH_PROXY_HEADER"`    // username header set by an auth proxy, e.g. Remote-User
+	AuthTrustedProxies string `env:"AUTH_TRUSTED_PROXIES"` // comma separated CIDRs the header is accepted from
+	OIDCIssuer         string `env:"OIDC_ISSUER"`
+	OIDCClientID       string `env:"OIDC_CLIENT_ID"`
+	OIDCClientSecret   string `env:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL    string `env:"OIDC_REDIRECT_URL"` // defaults to <request host>/api/ui/oidc/callback
+	OIDCScopes         string `env:"OIDC_SCOPES" env-default:"openid profile email"`
+	OIDCAllowedUsers   string `env:"OIDC_ALLOWED_USERS"` // comma separated subjects or verified emails, empty allows all
+
+	SessionStore        string `env:"SESSION_STORE" env-default:"file"`       // file (survives restarts) or memory
+	SessionIdleHours    int    `env:"SESSION_IDLE_HOURS" env-default:"24"`    // log out after this long without activity, 0 disables it
+	SessionLifetimeDays int    `env:"SESSION_LIFETIME_DAYS" env-default:"7"`  // log in again after this long, however active
+	SessionRememberDays int    `env:"SESSION_REMEMBER_DAYS" env-default:"30"` // lifetime of "remember me" sessions
+	LoginMaxAttempts    int    `env:"LOGIN_MAX_ATTEMPTS" env-default:"5"`     // failed logins per IP before a lockout (4x per username), 0 disables it
+	LoginLockoutSeconds int    `env:"LOGIN_LOCKOUT_SECONDS" env-default:"30"` // first lockout, doubled with every further failure
+	ExploPath    string
 }
 
 type ClientConfig struct {
@@ -105,6 +146,8 @@ type DownloadConfig struct {
 	UseSubDir         bool     `env:"USE_SUBDIRECTORY" env-default:"true"`
 	Discovery         string   `env:"LISTENBRAINZ_DISCOVERY" env-default:"playlist"`
 	Services          []string `env:"DOWNLOAD_SERVICES" env-default:"youtube"`
+	SharedIndex       string   // tracks downloaded by any profile, so they are only fetched once
+	Profile           string   // profile of the run, files used by other profiles are kept when clearing DownloadDir
 }
 
 type Filters struct {
@@ -152,8 +195,22 @@ type SlskdMon struct {
 type DiscoveryConfig struct {
 	Discovery    string `env:"DISCOVERY_SERVICE" env-default:"listenbrainz"`
 	ArtistBlacklist []string `env:"ARTIST_BLACKLIST"`
+	TrackRules   string `env:"TRACK_RULES"` // include/exclude rules for every playlist, see discovery.ParseRules
+	Balance      BalanceConfig
 	Listenbrainz Listenbrainz
 }
+
+// BalanceConfig limits how many discovered tracks share an artist or album, 0 is no limit
+type BalanceConfig struct {
+	MaxPerArtist int  `env:"MAX_TRACKS_PER_ARTIST" env-default:"0"`
+	MaxPerAlbum  int  `env:"MAX_TRACKS_PER_ALBUM" env-default:"0"`
+	Interleave   bool `env:"INTERLEAVE_ARTISTS" env-default:"false"` // reorder so an artist never plays twice in a row
+}
+
+// Enabled reports if discovered tracks need balancing
+func (b BalanceConfig) Enabled() bool {
+	return b.MaxPerArtist > 0 || b.MaxPerAlbum > 0 || b.Interleave
+}
 type Listenbrainz struct {
 	Discovery              string `env:"LISTENBRAINZ_DISCOVERY" env-default:"playlist"`
 	User                   string `env:"LISTENBRAINZ_USER"`
@@ -161,6 +218,9 @@ type Listenbrainz struct {
 	SingleArtist           bool   `env:"SINGLE_ARTIST" env-default:"true"`
 	CoverArtSize           string `env:"COVER_ART_SIZE" env-default:"250"`
 	EnrichTrackMetadata	   bool   `env:"ENRICH_TRACK_METADATA" env-default:"false"`
+	Token                  string `env:"LISTENBRAINZ_TOKEN"`
+	Publish                bool   `env:"LISTENBRAINZ_PUBLISH" env-default:"false"`
+	PublishPublic          bool   `env:"LISTENBRAINZ_PUBLISH_PUBLIC" env-default:"false"`
 }
 
 type NotifyConfig struct {
@@ -218,7 +278,11 @@ func (cfg *Config) ReadEnv() {
 
 func (cfg *Config) CommonFixes() {
 	cfg.DownloadCfg.Youtube.FileExtension = strings.TrimPrefix(cfg.DownloadCfg.Youtube.FileExtension, ".")
-	cfg.DownloadCfg.Youtube.CoversDir = filepath.Join(filepath.Dir(cfg.ServerCfg.WebDataDir), "cache", "covers")
+	// same cache dir the web UI keeps its covers and playlist caches in
+	cacheDir := filepath.Join(cfg.ServerCfg.WebDataDir, "cache")
+	cfg.DownloadCfg.Youtube.CoversDir = filepath.Join(cacheDir, "covers")
+	cfg.ServerCfg.HTTPCacheDir = filepath.Join(cacheDir, "http")
+	cfg.DownloadCfg.SharedIndex = filepath.Join(cfg.ServerCfg.WebDataDir, "downloads.json")
 	cfg.ClientCfg.URL = fixBaseURL(cfg.ClientCfg.URL)
 	cfg.DownloadCfg.Slskd.URL = fixBaseURL(cfg.DownloadCfg.Slskd.URL)
 	cfg.NormalizeDir()
@@ -280,6 +344,44 @@ func (cfg *Config) GenPlaylistDetails() {
 	}
 }
 
+// PlaylistEnvPrefix returns the prefix of a playlist's own env keys like <PREFIX>_SCHEDULE:
+// WEEKLY_EXPLORATION for weekly-exploration, or the custom playlist name's prefix for custom-* ids.
+func PlaylistEnvPrefix(playlistType, customName string) string {
+	if strings.HasPrefix(playlistType, "custom-") {
+		return CustomEnvPrefix(customName)
+	}
+	return strings.ToUpper(strings.ReplaceAll(playlistType, "-", "_"))
+}
+
+// CustomEnvPrefix converts a playlist name like "Today's Hits"
+// to an env-var prefix like "CUSTOM_TODAYS_HITS".
+// Non-alphanumeric characters are collapsed into underscores.
+func CustomEnvPrefix(name string) string {
+	var b strings.Builder
+	prevUnderscore := true // start true so leading separators are skipped
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			prevUnderscore = false
+		} else if !prevUnderscore {
+			b.WriteRune('_')
+			prevUnderscore = true
+		}
+	}
+	return "CUSTOM_" + strings.TrimRight(b.String(), "_")
+}
+
+// TrackRules returns TRACK_RULES followed by the current playlist's <PREFIX>_TRACK_RULES.
+// Call it after the custom playlist name is known.
+func (cfg *Config) TrackRules() string {
+	prefix := PlaylistEnvPrefix(cfg.Flags.Playlist, cfg.ClientCfg.PlaylistName)
+	rules := cfg.DiscoveryCfg.TrackRules
+	if own := os.Getenv(prefix + "_TRACK_RULES"); own != "" {
+		rules += ";" + own
+	}
+	return rules
+}
+
 func getPlaylistName(playlistType, format string, persist bool) string {
 
 
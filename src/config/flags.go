@@ -24,6 +24,11 @@ func (cfg *Config) GetFlags() error {
 	var showVersion bool
 	var searchMBID string
 	var refreshOnly bool
+	var syncFeedback bool
+	var syncListens bool
+	var runID string
+	var profile string
+	var export string
 	// Long flags
 	flag.StringVarP(&configPath, "config", "c", ".env", "Path of the configuration file")
 	flag.StringVarP(&playlist, "playlist", "p", "weekly-exploration", "Playlist where to get tracks. Supported: weekly-exploration, weekly-jams, daily-jams, on-repeat")
@@ -33,6 +38,11 @@ func (cfg *Config) GetFlags() error {
 	flag.BoolVarP(&showVersion, "version", "v", false, "Print version and exit")
 	flag.StringVar(&searchMBID, "search-mbid", "", "Test Plex search for a single recording MBID (resolves via ListenBrainz, then searches your library)")
 	flag.BoolVar(&refreshOnly, "refresh-only", false, "Trigger alibrary rescan and exit; skips discovery and downloads")
+	flag.BoolVar(&syncFeedback, "sync-feedback", false, "Send favourites and ratings of tracks Explo added to ListenBrainz as love/hate feedback and exit")
+	flag.BoolVar(&syncListens, "sync-listens", false, "Submit plays of tracks Explo added to ListenBrainz as listens and exit (for players that don't scrobble)")
+	flag.StringVar(&runID, "run-id", "", "ID of the run report (generated if empty)")
+	flag.StringVar(&profile, "profile", "", "Profile whose settings override the config file (see WEB_DATA_PATH/profiles)")
+	flag.StringVar(&export, "export", "", "Also write the playlist as these formats (comma separated: m3u8, xspf, jspf), overrides EXPORT_FORMATS")
 
   flag.Parse()
 
@@ -64,6 +74,11 @@ func (cfg *Config) GetFlags() error {
 	cfg.Flags.Persist = persist
 	cfg.Flags.SearchMBID = searchMBID
 	cfg.Flags.RefreshOnly = refreshOnly
+	cfg.Flags.SyncFeedback = syncFeedback
+	cfg.Flags.SyncListens = syncListens
+	cfg.Flags.RunID = runID
+	cfg.Flags.Profile = profile
+	cfg.Flags.Export = export
 
 	// for deprecation purposes (can be removed at a later date)
 	cfg.Flags.PersistSet = persistSet
@@ -74,11 +89,16 @@ func (cfg *Config) GetFlags() error {
 func (cfg *Config) MergeFlags() {
 	cfg.DiscoveryCfg.Listenbrainz.ImportPlaylist = cfg.Flags.Playlist
 	cfg.DownloadCfg.ExcludeLocal = cfg.Flags.ExcludeLocal
+	cfg.DownloadCfg.Profile = cfg.Flags.Profile
 
 	if cfg.Flags.CfgSet {
 		cfg.ServerCfg.WebEnvPath = cfg.Flags.CfgPath
 	}
 
+	if cfg.Flags.Export != "" {
+		cfg.ExportCfg.Formats = cfg.Flags.Export
+	}
+
 	if cfg.Flags.PersistSet {
 		cfg.Persist = cfg.Flags.Persist
 	} else {
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/joho/godotenv"
+)
+
+// Profiles let one instance serve several people. A profile is an .env file in
+// <WEB_DATA_PATH>/profiles that overrides keys of the main config for runs started
+// with --profile, usually LISTENBRAINZ_USER and the media server user/credentials.
+
+var validProfileID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,39}$`)
+
+// ValidProfileID reports whether id can be used as a profile name
+func ValidProfileID(id string) bool {
+	return validProfileID.MatchString(id)
+}
+
+// ProfilesDir returns the directory holding profile .env files
+func ProfilesDir(dataDir string) string {
+	return filepath.Join(dataDir, "profiles")
+}
+
+// ProfilePath returns the .env file of profile id
+func ProfilePath(dataDir, id string) string {
+	return filepath.Join(ProfilesDir(dataDir), id+".env")
+}
+
+// ReadProfile applies the --profile overrides on top of the main config
+func (cfg *Config) ReadProfile() {
+	if cfg.Flags.Profile == "" {
+		return
+	}
+	if !ValidProfileID(cfg.Flags.Profile) {
+		slog.Error("invalid profile name", "profile", cfg.Flags.Profile)
+		os.Exit(1)
+	}
+	path := ProfilePath(cfg.ServerCfg.WebDataDir, cfg.Flags.Profile)
+	values, err := godotenv.Read(path)
+	if err != nil {
+		slog.Error("failed to load profile", "profile", cfg.Flags.Profile, "path", path, "context", err.Error())
+		os.Exit(1)
+	}
+	// empty keys keep the main config's value instead of clearing it
+	for k, v := range values {
+		if v != "" {
+			os.Setenv(k, v)
+		}
+	}
+	if err := cleanenv.ReadEnv(cfg); err != nil {
+		slog.Error("failed to load profile", "profile", cfg.Flags.Profile, "path", path, "context", err.Error())
+		os.Exit(1)
+	}
+	cfg.CommonFixes()
+}
@@ -0,0 +1,168 @@
+package discovery
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	cfg "explo/src/config"
+	"explo/src/models"
+)
+
+// overflowProvider is implemented by discoveries that fetch more tracks than the playlist
+// holds, to replace tracks removed while balancing
+type overflowProvider interface {
+	Overflow() []*models.Track
+}
+
+// Balance drops tracks over the per artist and per album limits and fills the playlist up to
+// size with the first overflow tracks that fit. Overflow is passed through prepare (enrich
+// and rules) a batch at a time, only as far as needed. With interleaving the result is
+// reordered so the same artist doesn't play twice in a row, where that's possible.
+func Balance(tracks []*models.Track, size int, overflow []*models.Track, prepare func([]*models.Track) []*models.Track, b cfg.BalanceConfig) []*models.Track {
+	if !b.Enabled() {
+		return tracks
+	}
+
+	perArtist := make(map[string]int)
+	perAlbum := make(map[string]int)
+	seen := make(map[string]bool) // recording MBIDs already in the playlist
+	balanced := make([]*models.Track, 0, size)
+
+	// reason returns why the track can't be added, empty if it can
+	reason := func(t *models.Track) string {
+		if b.MaxPerArtist > 0 && perArtist[artistKey(t)] >= b.MaxPerArtist {
+			return fmt.Sprintf("max %d tracks per artist", b.MaxPerArtist)
+		}
+		if album := albumKey(t); album != "" && b.MaxPerAlbum > 0 && perAlbum[album] >= b.MaxPerAlbum {
+			return fmt.Sprintf("max %d tracks per album", b.MaxPerAlbum)
+		}
+		return ""
+	}
+	add := func(t *models.Track) {
+		perArtist[artistKey(t)]++
+		if album := albumKey(t); album != "" {
+			perAlbum[album]++
+		}
+		if t.MusicBrainzTrackID != "" {
+			seen[t.MusicBrainzTrackID] = true
+		}
+		balanced = append(balanced, t)
+	}
+
+	for _, t := range tracks {
+		if r := reason(t); r != "" {
+			slog.Info("track removed by balancing", "track", t.CleanTitle, "artist", t.MainArtist, "reason", r)
+			continue
+		}
+		add(t)
+	}
+	var refilled int
+	for len(balanced) < size && len(overflow) > 0 {
+		batch := overflow[:min(size-len(balanced), len(overflow))]
+		overflow = overflow[len(batch):]
+		if prepare != nil {
+			batch = prepare(batch)
+		}
+		for _, t := range batch {
+			if len(balanced) >= size {
+				break
+			}
+			if seen[t.MusicBrainzTrackID] || reason(t) != "" {
+				continue
+			}
+			slog.Debug("track added from overflow recommendations", "track", t.CleanTitle, "artist", t.MainArtist)
+			add(t)
+			refilled++
+		}
+	}
+	if len(balanced) < size {
+		slog.Info("balancing left fewer tracks than discovered", "discovered", size, "tracks", len(balanced), "refilled", refilled)
+	} else if refilled > 0 {
+		slog.Info("refilled balanced playlist from overflow recommendations", "tracks", refilled)
+	}
+
+	if b.Interleave {
+		balanced = interleave(balanced)
+	}
+	return balanced
+}
+
+// interleave reorders tracks so no artist plays twice in a row, staying as close to the
+// original order as it can: every position takes the first remaining track by another
+// artist that still lets the rest be spread out. When none does, an artist has too many
+// tracks and goes next, so as few of its tracks as possible end up back to back.
+func interleave(tracks []*models.Track) []*models.Track {
+	rest := append([]*models.Track(nil), tracks...)
+	remaining := make(map[string]int)
+	for _, t := range rest {
+		remaining[artistKey(t)]++
+	}
+
+	out := make([]*models.Track, 0, len(tracks))
+	prev := ""
+	for len(rest) > 0 {
+		pick := -1
+		fallback := -1
+		for i, t := range rest {
+			artist := artistKey(t)
+			if artist == prev && len(rest) > 1 {
+				continue
+			}
+			if fallback == -1 || remaining[artist] > remaining[artistKey(rest[fallback])] {
+				fallback = i
+			}
+			remaining[artist]--
+			ok := spreadable(remaining, len(rest)-1, artist)
+			remaining[artist]++
+			if ok {
+				pick = i
+				break
+			}
+		}
+		if pick == -1 {
+			pick = max(fallback, 0)
+		}
+		t := rest[pick]
+		rest = append(rest[:pick], rest[pick+1:]...)
+		prev = artistKey(t)
+		remaining[prev]--
+		out = append(out, t)
+	}
+	return out
+}
+
+// spreadable reports if n tracks with the given artist counts can follow a track by last
+// without two tracks by one artist in a row
+func spreadable(remaining map[string]int, n int, last string) bool {
+	for artist, count := range remaining {
+		limit := (n + 1) / 2
+		if artist == last {
+			limit = n / 2
+		}
+		if count > limit {
+			return false
+		}
+	}
+	return true
+}
+
+func artistKey(t *models.Track) string {
+	if t.MusicBrainzArtistID != "" {
+		return t.MusicBrainzArtistID
+	}
+	return strings.ToLower(strings.TrimSpace(t.MainArtist))
+}
+
+// albumKey identifies the track's album, empty when the album is unknown
+func albumKey(t *models.Track) string {
+	switch {
+	case t.MusicBrainzReleaseGroupID != "":
+		return t.MusicBrainzReleaseGroupID
+	case t.MusicBrainzAlbumID != "":
+		return t.MusicBrainzAlbumID
+	case t.Album != "":
+		return strings.ToLower(artistKey(t) + "|" + t.Album)
+	}
+	return ""
+}
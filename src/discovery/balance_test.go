@@ -0,0 +1,170 @@
+package discovery
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	cfg "explo/src/config"
+	"explo/src/models"
+)
+
+// track builds a test track from "artist/album/title", the title doubles as recording MBID
+func track(spec string) *models.Track {
+	parts := strings.SplitN(spec, "/", 3)
+	return &models.Track{MainArtist: parts[0], Album: parts[1], CleanTitle: parts[2], MusicBrainzTrackID: parts[2]}
+}
+
+func tracks(specs ...string) []*models.Track {
+	out := make([]*models.Track, len(specs))
+	for i, s := range specs {
+		out[i] = track(s)
+	}
+	return out
+}
+
+func titles(tracks []*models.Track) string {
+	out := make([]string, len(tracks))
+	for i, t := range tracks {
+		out[i] = t.CleanTitle
+	}
+	return strings.Join(out, " ")
+}
+
+func TestBalance(t *testing.T) {
+	tests := []struct {
+		name     string
+		tracks   []*models.Track
+		size     int // 0 means len(tracks)
+		overflow []*models.Track
+		prepare  func([]*models.Track) []*models.Track
+		cfg      cfg.BalanceConfig
+		want     string
+	}{
+		{
+			name:   "disabled",
+			tracks: tracks("a/x/1", "a/x/2", "a/x/3"),
+			want:   "1 2 3",
+		},
+		{
+			name:   "per artist",
+			tracks: tracks("a/x/1", "A/y/2", "b/z/3", "a/w/4"),
+			cfg:    cfg.BalanceConfig{MaxPerArtist: 1},
+			want:   "1 3",
+		},
+		{
+			name:   "per album",
+			tracks: tracks("a/x/1", "a/x/2", "a/y/3", "b/x/4", "c//5", "c//6"),
+			cfg:    cfg.BalanceConfig{MaxPerAlbum: 1},
+			want:   "1 3 4 5 6", // same album name by another artist is another album, unknown albums aren't limited
+		},
+		{
+			name:     "refill from overflow",
+			tracks:   tracks("a/x/1", "a/y/2", "b/z/3"),
+			overflow: tracks("a/v/4", "b/z/5", "c/u/1", "c/u/6", "d/t/7"),
+			cfg:      cfg.BalanceConfig{MaxPerArtist: 1, MaxPerAlbum: 1},
+			want:     "1 3 6", // 4 artist, 5 album, 1 already in the playlist
+		},
+		{
+			name:     "refill up to the discovered size",
+			tracks:   tracks("a/x/1", "a/y/2"),
+			size:     4, // two tracks were removed by rules before balancing
+			overflow: tracks("b/x/3", "c/x/4", "d/x/5"),
+			cfg:      cfg.BalanceConfig{MaxPerArtist: 1},
+			want:     "1 3 4 5",
+		},
+		{
+			name:     "overflow is prepared before use",
+			tracks:   tracks("a/x/1", "a/y/2"),
+			overflow: tracks("b/x/3", "c/x/4"),
+			prepare: func(batch []*models.Track) []*models.Track {
+				return slices.DeleteFunc(slices.Clone(batch), func(t *models.Track) bool { return t.MainArtist == "b" })
+			},
+			cfg:  cfg.BalanceConfig{MaxPerArtist: 1},
+			want: "1 4",
+		},
+		{
+			name:     "not enough overflow",
+			tracks:   tracks("a/x/1", "a/y/2", "a/z/3"),
+			overflow: tracks("a/v/4"),
+			cfg:      cfg.BalanceConfig{MaxPerArtist: 1},
+			want:     "1",
+		},
+		{
+			name:   "interleave only",
+			tracks: tracks("a/x/1", "a/x/2", "b/x/3", "b/x/4"),
+			cfg:    cfg.BalanceConfig{Interleave: true},
+			want:   "1 3 2 4",
+		},
+		{
+			name:     "limit then interleave",
+			tracks:   tracks("a/x/1", "a/y/2", "a/z/3", "b/x/4"),
+			overflow: tracks("c/x/5"),
+			cfg:      cfg.BalanceConfig{MaxPerArtist: 2, Interleave: true},
+			want:     "1 4 2 5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.size
+			if size == 0 {
+				size = len(tt.tracks)
+			}
+			got := Balance(tt.tracks, size, tt.overflow, tt.prepare, tt.cfg)
+			if titles(got) != tt.want {
+				t.Errorf("Balance() = %q, want %q", titles(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestBalancePreparesOnlyWhatItNeeds(t *testing.T) {
+	overflow := tracks("b/x/3", "c/x/4", "d/x/5", "e/x/6", "f/x/7")
+	var prepared int
+	prepare := func(batch []*models.Track) []*models.Track {
+		prepared += len(batch)
+		return batch
+	}
+	got := Balance(tracks("a/x/1", "a/y/2"), 2, overflow, prepare, cfg.BalanceConfig{MaxPerArtist: 1})
+	if titles(got) != "1 3" {
+		t.Errorf("Balance() = %q, want %q", titles(got), "1 3")
+	}
+	if prepared != 1 {
+		t.Errorf("prepared %d overflow tracks, want 1", prepared)
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want string
+	}{
+		{"empty", nil, ""},
+		{"already spread", []string{"a/x/1", "b/x/2", "a/x/3"}, "1 2 3"},
+		{"pairs", []string{"a/x/1", "a/x/2", "b/x/3", "b/x/4"}, "1 3 2 4"},
+		{"stays close to the order", []string{"a/x/1", "a/x/2", "b/x/3", "c/x/4", "d/x/5"}, "1 3 2 4 5"},
+		{"dominant artist goes first", []string{"b/x/1", "a/x/2", "a/x/3", "a/x/4"}, "2 1 3 4"},
+		{"impossible ends back to back", []string{"a/x/1", "a/x/2", "a/x/3", "b/x/4"}, "1 4 2 3"},
+		{"artist names ignore case", []string{"Air/x/1", "air/x/2", "b/x/3"}, "1 3 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := titles(interleave(tracks(tt.in...))); got != tt.want {
+				t.Errorf("interleave() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArtistKeyPrefersMBID(t *testing.T) {
+	a := &models.Track{MainArtist: "Nirvana", MusicBrainzArtistID: "5b11f4ce-a62d-471e-81fc-a69a8278c7da"}
+	b := &models.Track{MainArtist: "Nirvana", MusicBrainzArtistID: "9282c8b4-ca0b-4c6b-b7e3-4f7762dfc4d6"} // the 60s band
+	if artistKey(a) == artistKey(b) {
+		t.Error("artists with the same name but different MBIDs share a key")
+	}
+	c := &models.Track{MainArtist: " NIRVANA "}
+	if artistKey(c) != "nirvana" {
+		t.Errorf("artistKey() = %q, want nirvana", artistKey(c))
+	}
+}
@@ -1,10 +1,11 @@
 package discovery
 
 import (
+	"context"
 	cfg "explo/src/config"
 	"explo/src/models"
 	"explo/src/util"
-	"log/slog"
+	"strings"
 )
 
 type DiscoverClient struct {
@@ -12,7 +13,7 @@ type DiscoverClient struct {
 	Discovery Discovery
 }
 type Discovery interface {
-	QueryTracks() ([]*models.Track, error)
+	QueryTracks(ctx context.Context) ([]*models.Track, error)
 }
 
 func NewDiscoverer(cfg cfg.DiscoveryConfig, httpClient *util.HttpClient) *DiscoverClient {
@@ -27,8 +28,8 @@ func NewDiscoverer(cfg cfg.DiscoveryConfig, httpClient *util.HttpClient) *Discov
 	return c
 }
 
-func (c *DiscoverClient) Discover() ([]*models.Track, error) {
-	tracks, err := c.Discovery.QueryTracks()
+func (c *DiscoverClient) Discover(ctx context.Context) ([]*models.Track, error) {
+	tracks, err := c.Discovery.QueryTracks(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -36,34 +37,25 @@ func (c *DiscoverClient) Discover() ([]*models.Track, error) {
 	return c.filterArtists(tracks), nil
 }
 
+// Overflow returns the discovered tracks that didn't fit in the playlist, best first, for
+// refilling it after balancing. Only the ListenBrainz recommendations API has them.
+func (c *DiscoverClient) Overflow() []*models.Track {
+	o, ok := c.Discovery.(overflowProvider)
+	if !ok {
+		return nil
+	}
+	return c.filterArtists(o.Overflow())
+}
+
+// filterArtists drops tracks by ARTIST_BLACKLIST artists, as an exclude artist rule
 func (c DiscoverClient) filterArtists(tracks []*models.Track) []*models.Track {
 	if len(c.cfg.ArtistBlacklist) == 0 {
 		return tracks
 	}
 
-	// create map for faster lookup
-	blacklist := make(map[string]struct{}, len(c.cfg.ArtistBlacklist))
+	rule := Rule{Exclude: true, Field: FieldArtist}
 	for _, artist := range c.cfg.ArtistBlacklist {
-		blacklist[artist] = struct{}{}
+		rule.Values = append(rule.Values, strings.ToLower(strings.TrimSpace(artist)))
 	}
-
-	filtered := tracks[:0]
-
-	for _, track := range tracks {
-		_, blockedName := blacklist[track.MainArtist]
-		_, blockedMBID := blacklist[track.MusicBrainzArtistID]
-
-		if blockedName || blockedMBID {
-			slog.Debug("filtered out artist",
-				"name", track.MainArtist,
-				"mbid", track.MusicBrainzArtistID,
-				"track", track.CleanTitle,
-			)
-			continue
-		}
-
-		filtered = append(filtered, track)
-	}
-
-	return filtered
-}
\ No newline at end of file
+	return Rules{rule}.Apply(tracks)
+}
@@ -0,0 +1,149 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	cfg "explo/src/config"
+	"explo/src/models"
+	"explo/src/util"
+)
+
+// MBRecordingList is returned by MusicBrainz ISRC lookups and recording searches
+type MBRecordingList struct {
+	Recordings []struct {
+		ID           string `json:"id"`
+		Score        int    `json:"score"`
+		Title        string `json:"title"`
+		Length       int    `json:"length"`
+		ArtistCredit []struct {
+			Name   string `json:"name"`
+			Artist struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"artist"`
+		} `json:"artist-credit"`
+	} `json:"recordings"`
+}
+
+// minimum search score for a text search match to be accepted
+const minSearchScore = 90
+
+// Enricher resolves tracks from any source to MusicBrainz recordings and fills in full metadata
+type Enricher struct {
+	lb *ListenBrainz
+}
+
+func NewEnricher(cfg cfg.DiscoveryConfig, httpClient *util.HttpClient) *Enricher {
+	return &Enricher{lb: NewListenBrainz(cfg, httpClient)}
+}
+
+// Enrich looks up recordings for tracks without an MBID (by ISRC, then by title and artist)
+// and adds release, track number, genres, ISRCs and cover art to every resolved track.
+// Tracks that can't be resolved are returned unchanged
+func (e *Enricher) Enrich(ctx context.Context, tracks []*models.Track) []*models.Track {
+	if len(tracks) == 0 {
+		return tracks
+	}
+
+	var resolved, unresolved int
+	for _, track := range tracks {
+		if track.MusicBrainzTrackID != "" {
+			continue
+		}
+		mbid, err := e.lb.resolveRecording(ctx, track)
+		if ctx.Err() != nil {
+			return tracks
+		}
+		if err != nil {
+			unresolved++
+			slog.Debug("could not resolve recording", "title", track.CleanTitle, "artist", track.MainArtist, "error", err.Error())
+			continue
+		}
+		track.MusicBrainzTrackID = mbid
+		resolved++
+	}
+	if resolved+unresolved > 0 {
+		slog.Info("resolved tracks to MusicBrainz recordings", "resolved", resolved, "unresolved", unresolved)
+	}
+
+	enriched, err := e.lb.enrichTracks(ctx, tracks, e.lb.cfg.SingleArtist)
+	if err != nil {
+		slog.Warn("failed to enrich track metadata", "error", err)
+		return tracks
+	}
+	return enriched
+}
+
+// resolveRecording finds the recording MBID for a track, trying its ISRCs before a text search
+func (c *ListenBrainz) resolveRecording(ctx context.Context, track *models.Track) (string, error) {
+	for _, isrc := range track.ISRCs {
+		list, err := c.mbSearch(ctx, fmt.Sprintf("isrc/%s?inc=artist-credits&fmt=json", url.PathEscape(isrc)))
+		if err != nil {
+			continue
+		}
+		if id := bestRecording(list, track, 0); id != "" {
+			return id, nil
+		}
+	}
+
+	title := util.CleanSearchTitle(track.CleanTitle)
+	if title == "" || track.MainArtist == "" {
+		return "", fmt.Errorf("not enough data to search for recording")
+	}
+	query := fmt.Sprintf(`recording:"%s" AND artist:"%s"`, escapeQuery(title), escapeQuery(track.MainArtist))
+	list, err := c.mbSearch(ctx, fmt.Sprintf("recording/?query=%s&limit=10&fmt=json", url.QueryEscape(query)))
+	if err != nil {
+		return "", err
+	}
+	if id := bestRecording(list, track, minSearchScore); id != "" {
+		return id, nil
+	}
+	return "", fmt.Errorf("no matching recording found")
+}
+
+// bestRecording picks the first recording whose title and artist match the track
+func bestRecording(list *MBRecordingList, track *models.Track, minScore int) string {
+	wantTitle := util.NormalizeTitle(track.CleanTitle)
+	wantArtist := util.AlnumOnly(strings.ToLower(track.MainArtist))
+
+	for _, rec := range list.Recordings {
+		if rec.Score < minScore {
+			continue
+		}
+		if util.NormalizeTitle(rec.Title) != wantTitle {
+			continue
+		}
+		if track.Duration > 0 && rec.Length > 0 && util.Abs(track.Duration-rec.Length) > 10000 { // 10s+ difference is a different version
+			continue
+		}
+		for _, credit := range rec.ArtistCredit {
+			name := util.AlnumOnly(strings.ToLower(credit.Name))
+			if name != "" && (strings.Contains(name, wantArtist) || strings.Contains(wantArtist, name)) {
+				return rec.ID
+			}
+		}
+	}
+	return ""
+}
+
+func (c *ListenBrainz) mbSearch(ctx context.Context, path string) (*MBRecordingList, error) {
+	body, err := c.mbGet(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list MBRecordingList
+	if err := util.ParseResp(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse MusicBrainz response: %s", err)
+	}
+	return &list, nil
+}
+
+// escapeQuery escapes characters that would end a quoted Lucene phrase
+func escapeQuery(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
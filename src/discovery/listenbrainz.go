@@ -1,8 +1,11 @@
 package discovery
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -180,62 +183,79 @@ type MBRecording struct {
 	} `json:"releases"`
 }
 
+// apiPlaylistSize is the number of recommendations LB returns by default, and the size of
+// playlists from the recommendations API
+const apiPlaylistSize = 25
+
+// apiOverflowFactor is how many more recommendations are requested when tracks get
+// balanced, so tracks dropped for an artist or album limit can be replaced
+const apiOverflowFactor = 4
+
 type ListenBrainz struct {
 	HttpClient *util.HttpClient
 	cfg        cfg.Listenbrainz
 	Separator  string
+	balance    bool
+	overflow   []*models.Track // recommendations past the playlist size, best first
 }
 
 func NewListenBrainz(cfg cfg.DiscoveryConfig, httpClient *util.HttpClient) *ListenBrainz {
 	return &ListenBrainz{
 		cfg:        cfg.Listenbrainz,
 		HttpClient: httpClient,
+		balance:    cfg.Balance.Enabled(),
 	}
 }
-func (c *ListenBrainz) QueryTracks() ([]*models.Track, error) {
+
+// Overflow returns the recommendations of the last query that didn't fit in the playlist
+func (c *ListenBrainz) Overflow() []*models.Track {
+	return c.overflow
+}
+func (c *ListenBrainz) QueryTracks(ctx context.Context) ([]*models.Track, error) {
 	// Stats-based playlists bypass the discovery mode switch
 	if c.cfg.ImportPlaylist == "on-repeat" {
-		return c.getTopRecordings(c.cfg.User)
+		return c.getTopRecordings(ctx, c.cfg.User)
 	}
 
 	var tracks []*models.Track
 
 	switch c.cfg.Discovery {
 	case "playlist":
-		id, err := c.getImportPlaylist(c.cfg.User)
+		id, err := c.getImportPlaylist(ctx, c.cfg.User)
 		if err != nil {
 			return nil, err
 		}
-		_, tracks, err = c.parsePlaylist(id, c.cfg.SingleArtist)
+		_, tracks, err = c.parsePlaylist(ctx, id, c.cfg.SingleArtist)
 		if err != nil {
 			return nil, err
 		}
-		if c.cfg.EnrichTrackMetadata && len(tracks) > 0 {
-			enrichedTracks, err := c.enrichTracks(tracks, c.cfg.SingleArtist)
-			if err != nil {
-				slog.Warn("failed to enrich playlist metadata", "error", err)
-			} else {
-				tracks = enrichedTracks
-			}
-		}
 
 	default:
-		mbids, err := c.getAPIRecommendations(c.cfg.User)
+		count := apiPlaylistSize
+		if c.balance {
+			count *= apiOverflowFactor
+		}
+		mbids, err := c.getAPIRecommendations(ctx, c.cfg.User, count)
 		if err != nil {
 			return nil, err
 		}
-		tracks, err = c.getTracks(mbids, c.cfg.SingleArtist)
+		tracks, err = c.getTracks(ctx, mbids, c.cfg.SingleArtist)
 		if err != nil {
 			return nil, err
 		}
+		if len(tracks) > apiPlaylistSize {
+			c.overflow = tracks[apiPlaylistSize:]
+			tracks = tracks[:apiPlaylistSize]
+		}
 	}
 	return tracks, nil
 }
 
-func (c *ListenBrainz) getAPIRecommendations(user string) ([]string, error) {
+// getAPIRecommendations returns up to count recommended recording MBIDs, best first
+func (c *ListenBrainz) getAPIRecommendations(ctx context.Context, user string, count int) ([]string, error) {
 	var mbids []string
 
-	body, err := c.lbRequest(fmt.Sprintf("cf/recommendation/user/%s/recording", user))
+	body, err := c.lbRequest(ctx, fmt.Sprintf("cf/recommendation/user/%s/recording?count=%d", user, count))
 	if err != nil {
 		return mbids, fmt.Errorf("could not get recommendations from API: %s", err.Error())
 	}
@@ -256,8 +276,8 @@ func (c *ListenBrainz) getAPIRecommendations(user string) ([]string, error) {
 	return mbids, nil
 }
 
-func (c *ListenBrainz) getTopRecordings(user string) ([]*models.Track, error) {
-	body, err := c.lbRequest(fmt.Sprintf("stats/user/%s/recordings?count=30&range=month", user))
+func (c *ListenBrainz) getTopRecordings(ctx context.Context, user string) ([]*models.Track, error) {
+	body, err := c.lbRequest(ctx, fmt.Sprintf("stats/user/%s/recordings?count=30&range=month", user))
 	if err != nil {
 		return nil, fmt.Errorf("getTopRecordings(): %s", err.Error())
 	}
@@ -289,17 +309,17 @@ func (c *ListenBrainz) getTopRecordings(user string) ([]*models.Track, error) {
 
 	return tracks, nil
 }
-func (c *ListenBrainz) LookupRecording(mbid string) (*models.Track, error) {
-	tracks, err := c.getTracks([]string{mbid}, false)
+func (c *ListenBrainz) LookupRecording(ctx context.Context, mbid string) (*models.Track, error) {
+	tracks, err := c.getTracks(ctx, []string{mbid}, false)
 	if err != nil {
 		return nil, err
 	}
 	return tracks[0], nil
 }
-func (c *ListenBrainz) getTracks(mbids []string, singleArtist bool) ([]*models.Track, error) {
+func (c *ListenBrainz) getTracks(ctx context.Context, mbids []string, singleArtist bool) ([]*models.Track, error) {
 	strMbids := strings.Join(mbids, ",")
 
-	body, err := c.lbRequest(fmt.Sprintf("metadata/recording/?recording_mbids=%s&inc=release+artist", strMbids))
+	body, err := c.lbRequest(ctx, fmt.Sprintf("metadata/recording/?recording_mbids=%s&inc=release+artist", strMbids))
 	if err != nil {
 		return nil, fmt.Errorf("getTracks(): %s", err.Error())
 	}
@@ -357,39 +377,54 @@ func (c *ListenBrainz) getTracks(mbids []string, singleArtist bool) ([]*models.T
 		})
 	}
 
+	// recordings is a map, restore the order of the requested MBIDs
+	order := make(map[string]int, len(mbids))
+	for i, mbid := range mbids {
+		order[mbid] = i
+	}
+	sort.SliceStable(tracks, func(i, j int) bool {
+		return order[tracks[i].MusicBrainzTrackID] < order[tracks[j].MusicBrainzTrackID]
+	})
+
 	return tracks, nil
 
 }
 
-func (c *ListenBrainz) enrichTracks(tracks []*models.Track, singleArtist bool) ([]*models.Track, error) {
+func (c *ListenBrainz) enrichTracks(ctx context.Context, tracks []*models.Track, singleArtist bool) ([]*models.Track, error) {
 	mbids := make([]string, 0, len(tracks))
-	// wait time in s between MusicBrainz requests
-	waitTime := 2
-	totalWait := waitTime * len(tracks)
-	slog.Info("enriching tracks with metadata. This may take a moment", "estimated_seconds", totalWait)
+	// MusicBrainz requests are rate limited to 1 req/s by the http client
+	slog.Info("enriching tracks with metadata. This may take a moment", "estimated_seconds", len(tracks))
 
 	for _, track := range tracks {
 		if track.MusicBrainzTrackID != "" {
 			mbids = append(mbids, track.MusicBrainzTrackID)
 		}
 	}
-	strMbids := strings.Join(mbids, ",")
-
-	body, err := c.lbRequest(fmt.Sprintf("metadata/recording/?recording_mbids=%s&inc=release+artist+tag+release_group+recording", strMbids))
-	if err != nil {
-		return nil, fmt.Errorf("getTracks(): %s", err.Error())
+	if len(mbids) == 0 {
+		return tracks, nil
 	}
 
-	var recordings Recordings
-	if err := util.ParseResp(body, &recordings); err != nil {
-		return nil, fmt.Errorf("getTracks(): %s", err.Error())
+	// query in batches to keep request URLs at a sane length for large imports
+	recordings := make(Recordings, len(mbids))
+	for batch := range slices.Chunk(mbids, 100) {
+		strMbids := strings.Join(batch, ",")
+		body, err := c.lbRequest(ctx, fmt.Sprintf("metadata/recording/?recording_mbids=%s&inc=release+artist+tag+release_group+recording", strMbids))
+		if err != nil {
+			return nil, fmt.Errorf("getTracks(): %s", err.Error())
+		}
+
+		var batchRecordings Recordings
+		if err := util.ParseResp(body, &batchRecordings); err != nil {
+			return nil, fmt.Errorf("getTracks(): %s", err.Error())
+		}
+		maps.Copy(recordings, batchRecordings)
 	}
 
 	if len(recordings) == 0 {
-		return nil, fmt.Errorf("no recordings found for MBIDs: %s", strMbids)
+		return nil, fmt.Errorf("no recordings found for MBIDs: %s", strings.Join(mbids, ","))
 	}
 
-	for i, track := range tracks {
+	for _, track := range tracks {
 		recording, ok := recordings[track.MusicBrainzTrackID]
 		if !ok {
 			continue
@@ -458,17 +493,9 @@ func (c *ListenBrainz) enrichTracks(tracks []*models.Track, singleArtist bool) (
 		mbReleaseTrackID := ""
 		releaseType := ""
 
-		var mbData *MBRecording
-		var mbErr error
-		for attempt := 1; attempt <= 3; attempt++ {
-
-			if attempt <= 3 {
-				time.Sleep(time.Duration(waitTime) * time.Second)
-			}
-			mbData, mbErr = c.mbRequest(fmt.Sprintf("recording/%s?inc=media+releases+artist-credits+release-groups&fmt=json", track.MusicBrainzTrackID))
-			if mbErr == nil && mbData != nil {
-				break
-			}
+		mbData, mbErr := c.mbRequest(ctx, fmt.Sprintf("recording/%s?inc=media+releases+artist-credits+release-groups&fmt=json", track.MusicBrainzTrackID))
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
 
 		if mbData != nil {
@@ -519,45 +546,48 @@ func (c *ListenBrainz) enrichTracks(tracks []*models.Track, singleArtist bool) (
 			slog.Debug("failed to enrich from MusicBrainz after retries", "mbid", track.MusicBrainzTrackID, "error", mbErr)
 		}
 
-		tracks[i] = &models.Track{
-			ID:                        track.ID,
-			File:                      track.File,
-			Size:                      track.Size,
-			Present:                   track.Present,
-			Album:                     recording.Release.Name,
-			AlbumArtist:               recording.Release.AlbumArtistName,
-			Artist:                    artist,
-			Artists:                   artists,
-			MainArtist:                mainArtist,
-			MainArtistID:              mainArtistID,
-			ArtistSort:                artistSort,
-			CleanTitle:                rec.Name,
-			Title:                     title,
-			Duration:                  rec.Length,
-			ReleaseCountry:            releaseCountry,
-			ReleaseStatus:             releaseStatus,
-			ReleaseType:               releaseType,
-			OriginalDate:              originalDate,
-			OriginalYear:              originalYear,
-			CoverURL:                  track.CoverURL,
-			Genres:                    strings.Join(genres, "; "),
-			ISRCs:                     append([]string(nil), rec.ISRCs...),
-			Media:                     media,
-			TrackNumber:               trackNumber,
-			TrackTotal:                trackTotal,
-			DiscNumber:                discNumber,
-			DiscTotal:                 discTotal,
-			MusicBrainzTrackID:        track.MusicBrainzTrackID,
-			MusicBrainzAlbumID:        recording.Release.CaaReleaseMbid,
-			MusicBrainzReleaseGroupID: mbReleaseGroupID,
-			MusicBrainzAlbumArtistID:  mbAlbumArtistID,
-			MusicBrainzReleaseTrackID: mbReleaseTrackID,
-			MusicBrainzArtistID: func() string {
-				if len(recArtists) == 0 {
-					return ""
-				}
-				return recArtists[0].ArtistMbid
-			}(),
+		coverURL := track.CoverURL
+		if coverURL == "" && recording.Release.CaaReleaseMbid != "" && recording.Release.CaaID != 0 {
+			coverURL = fmt.Sprintf("https://coverartarchive.org/release/%s/%d-%s.jpg",
+				recording.Release.CaaReleaseMbid, recording.Release.CaaID, c.cfg.CoverArtSize)
+		}
+
+		isrcs := append([]string(nil), rec.ISRCs...)
+		if len(isrcs) == 0 {
+			isrcs = track.ISRCs
+		}
+
+		// update in place, the track keeps fields set by its source and the downloader (VideoID, Path, ...)
+		track.Album = recording.Release.Name
+		track.AlbumArtist = recording.Release.AlbumArtistName
+		track.Artist = artist
+		track.Artists = artists
+		track.MainArtist = mainArtist
+		track.MainArtistID = mainArtistID
+		track.ArtistSort = artistSort
+		track.CleanTitle = rec.Name
+		track.Title = title
+		track.Duration = rec.Length
+		track.ReleaseCountry = releaseCountry
+		track.ReleaseStatus = releaseStatus
+		track.ReleaseType = releaseType
+		track.OriginalDate = originalDate
+		track.OriginalYear = originalYear
+		track.CoverURL = coverURL
+		track.Genres = strings.Join(genres, "; ")
+		track.ISRCs = isrcs
+		track.Media = media
+		track.TrackNumber = trackNumber
+		track.TrackTotal = trackTotal
+		track.DiscNumber = discNumber
+		track.DiscTotal = discTotal
+		track.MusicBrainzAlbumID = recording.Release.CaaReleaseMbid
+		track.MusicBrainzReleaseGroupID = mbReleaseGroupID
+		track.MusicBrainzAlbumArtistID = mbAlbumArtistID
+		track.MusicBrainzReleaseTrackID = mbReleaseTrackID
+		track.MusicBrainzArtistID = ""
+		if len(recArtists) > 0 {
+			track.MusicBrainzArtistID = recArtists[0].ArtistMbid
 		}
 	}
 
@@ -565,8 +595,14 @@ func (c *ListenBrainz) enrichTracks(tracks []*models.Track, singleArtist bool) (
 
 }
 
+// Attempts and wait between them when fetching the user's generated playlists
+const (
+	importPlaylistAttempts = 5
+	importPlaylistWait     = 5 * time.Minute
+)
+
 // Get user LB playlists and find wanted playlists ID
-func (c *ListenBrainz) getImportPlaylist(user string) (string, error) {
+func (c *ListenBrainz) getImportPlaylist(ctx context.Context, user string) (string, error) {
 	var offset int
 	var bestDate time.Time
 	var bestID string
@@ -575,9 +611,11 @@ func (c *ListenBrainz) getImportPlaylist(user string) (string, error) {
 		var body []byte
 		var err error
 
-		for retries := range 5 {
-			body, err = c.lbRequest(fmt.Sprintf("user/%s/playlists/createdfor?offset=%d", user, offset))
-			if err == nil {
+		// lbRequest only retries briefly, ListenBrainz can be down for a while when it's
+		// generating playlists, so keep waiting for it
+		for retries := range importPlaylistAttempts {
+			body, err = c.lbRequest(ctx, fmt.Sprintf("user/%s/playlists/createdfor?offset=%d", user, offset))
+			if err == nil || retries == importPlaylistAttempts-1 {
 				break
 			}
 			slog.Warn(
@@ -585,7 +623,9 @@ func (c *ListenBrainz) getImportPlaylist(user string) (string, error) {
 				"retry", retries+1,
 				"error", err,
 			)
-			time.Sleep(5 * time.Minute)
+			if serr := util.Sleep(ctx, importPlaylistWait); serr != nil {
+				return "", serr
+			}
 		}
 
 		if err != nil {
@@ -623,30 +663,40 @@ func (c *ListenBrainz) getImportPlaylist(user string) (string, error) {
 
 
 // FetchPlaylistByMBID fetches a LB playlist by MBID. For use outside the discovery flow.
-func FetchPlaylistByMBID(httpClient *util.HttpClient, mbid string) (string, []*models.Track, error) {
+func FetchPlaylistByMBID(ctx context.Context, httpClient *util.HttpClient, mbid string) (string, []*models.Track, error) {
 	lb := &ListenBrainz{HttpClient: httpClient}
-	return lb.parsePlaylist(mbid, false)
+	return lb.parsePlaylist(ctx, mbid, false)
 }
 
 // FetchTopRecordings returns the user's top recordings for the current month.
-func FetchTopRecordings(httpClient *util.HttpClient, user string) ([]*models.Track, error) {
+func FetchTopRecordings(ctx context.Context, httpClient *util.HttpClient, user string) ([]*models.Track, error) {
 	lb := &ListenBrainz{HttpClient: httpClient}
-	return lb.getTopRecordings(user)
+	return lb.getTopRecordings(ctx, user)
 }
 
 // FetchMostRecentPlaylistByType finds and fetches the most recent LB-generated playlist of the given type for the user.
-func FetchMostRecentPlaylistByType(httpClient *util.HttpClient, user, playlistType string) ([]*models.Track, error) {
+func FetchMostRecentPlaylistByType(ctx context.Context, httpClient *util.HttpClient, user, playlistType string) ([]*models.Track, error) {
 	lb := &ListenBrainz{HttpClient: httpClient, cfg: cfg.Listenbrainz{ImportPlaylist: playlistType}}
-	id, err := lb.getImportPlaylist(user)
+	id, err := lb.getImportPlaylist(ctx, user)
 	if err != nil {
 		return nil, err
 	}
-	_, tracks, err := lb.parsePlaylist(id, false)
+	_, tracks, err := lb.parsePlaylist(ctx, id, false)
 	return tracks, err
 }
 
-func (c *ListenBrainz) parsePlaylist(identifier string, singleArtist bool) (string, []*models.Track, error) {
-	body, err := c.lbRequest(fmt.Sprintf("playlist/%s", identifier))
+// ParseJSPF reads a JSPF playlist file, such as one exported from ListenBrainz.
+func ParseJSPF(data []byte) (string, []*models.Track, error) {
+	var exploration Exploration
+	if err := util.ParseResp(data, &exploration); err != nil {
+		return "", nil, fmt.Errorf("invalid JSPF: %w", err)
+	}
+	lb := &ListenBrainz{cfg: cfg.Listenbrainz{CoverArtSize: "250"}}
+	return exploration.Playlist.Title, lb.jspfTracks(exploration, false), nil
+}
+
+func (c *ListenBrainz) parsePlaylist(ctx context.Context, identifier string, singleArtist bool) (string, []*models.Track, error) {
+	body, err := c.lbRequest(ctx, fmt.Sprintf("playlist/%s", identifier))
 	if err != nil {
 		return "", nil, fmt.Errorf("parsePlaylist: %s", err.Error())
 	}
@@ -655,11 +705,15 @@ func (c *ListenBrainz) parsePlaylist(identifier string, singleArtist bool) (stri
 	if err != nil {
 		return "", nil, fmt.Errorf("parsePlaylist: %s", err.Error())
 	}
-	srcTracks := exploration.Playlist.Tracks
-	if len(srcTracks) == 0 {
+	if len(exploration.Playlist.Tracks) == 0 {
 		return "", nil, fmt.Errorf("no tracks found in playlist %s", identifier)
 	}
+	return exploration.Playlist.Title, c.jspfTracks(exploration, singleArtist), nil
+}
 
+// jspfTracks converts the tracks of a JSPF playlist
+func (c *ListenBrainz) jspfTracks(exploration Exploration, singleArtist bool) []*models.Track {
+	srcTracks := exploration.Playlist.Tracks
 	tracks := make([]*models.Track, 0, len(srcTracks))
 	for _, track := range srcTracks {
 		title := track.Title
@@ -717,15 +771,14 @@ func (c *ListenBrainz) parsePlaylist(identifier string, singleArtist bool) (stri
 		})
 	}
 
-	return exploration.Playlist.Title, tracks, nil
-
+	return tracks
 }
 
 // Handle ListenBrainz API requests
-func (c *ListenBrainz) lbRequest(path string) ([]byte, error) {
+func (c *ListenBrainz) lbRequest(ctx context.Context, path string) ([]byte, error) {
 
 	reqURL := fmt.Sprintf("https://api.listenbrainz.org/1/%s", path)
-	body, err := c.HttpClient.MakeRequest("GET", reqURL, nil, nil)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", reqURL, nil, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request to ListenBrainz API: %s", err)
 	}
@@ -737,9 +790,9 @@ func (c *ListenBrainz) lbRequest(path string) ([]byte, error) {
 	return body, nil
 }
 
-func (c *ListenBrainz) mbRequest(path string) (*MBRecording, error) {
+func (c *ListenBrainz) mbGet(ctx context.Context, path string) ([]byte, error) {
 	reqURL := fmt.Sprintf("https://musicbrainz.org/ws/2/%s", path)
-	body, err := c.HttpClient.MakeRequest("GET", reqURL, nil, nil)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", reqURL, nil, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request to MusicBrainz API: %s", err)
 	}
@@ -747,6 +800,14 @@ func (c *ListenBrainz) mbRequest(path string) (*MBRecording, error) {
 	if len(body) == 0 {
 		return nil, fmt.Errorf("MusicBrainz API returned empty response for: %s", reqURL)
 	}
+	return body, nil
+}
+
+func (c *ListenBrainz) mbRequest(ctx context.Context, path string) (*MBRecording, error) {
+	body, err := c.mbGet(ctx, path)
+	if err != nil {
+		return nil, err
+	}
 
 	var recording MBRecording
 	if err := util.ParseResp(body, &recording); err != nil {
@@ -0,0 +1,268 @@
+package discovery
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+
+	"explo/src/models"
+)
+
+// Rule fields
+const (
+	FieldArtist   = "artist"   // artist name (case-insensitive) or MusicBrainz artist ID
+	FieldTag      = "tag"      // genre/tag, alias "genre"
+	FieldYear     = "year"     // original release year or range
+	FieldType     = "type"     // release type (album, single, ...) or status (official, bootleg, ...)
+	FieldDuration = "duration" // track length in seconds or range
+	FieldTitle    = "title"    // keyword in the track title
+)
+
+// Rule keeps (include) or drops (exclude) tracks whose field matches one of its values
+type Rule struct {
+	Exclude bool
+	Field   string
+	Values  []string   // lower-cased, for text fields
+	Ranges  []numRange // for year and duration
+}
+
+type numRange struct{ min, max int }
+
+// Rules are applied together: a track is dropped when an exclude rule matches it, or when
+// a field has include rules and none of them match. Include rules for different fields must
+// all match. Tracks without the metadata a rule needs are never dropped by it, tags, years,
+// release types and durations are only known for ListenBrainz tracks with
+// ENRICH_TRACK_METADATA enabled.
+type Rules []Rule
+
+// ParseRules parses rules separated by ";" like
+//
+//	exclude artist:Drake,8e68819d-71be-4e7d-b41d-f1df81b01d3f; include tag:rock,metal; include year:1990-2009
+//
+// Values are comma separated, ranges ("1990-2009", "2000-", "-1979") work for year and
+// duration (seconds).
+func ParseRules(s string) (Rules, error) {
+	var rules Rules
+	for part := range strings.SplitSeq(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		rule, err := parseRule(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid track rule %q: %w", part, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseRule(s string) (Rule, error) {
+	var rule Rule
+	action, rest, _ := strings.Cut(s, " ")
+	switch strings.ToLower(action) {
+	case "include":
+	case "exclude":
+		rule.Exclude = true
+	default:
+		return rule, fmt.Errorf("must start with include or exclude")
+	}
+	field, values, ok := strings.Cut(strings.TrimSpace(rest), ":")
+	if !ok {
+		return rule, fmt.Errorf("expected <field>:<values>")
+	}
+	rule.Field = strings.ToLower(strings.TrimSpace(field))
+	if rule.Field == "genre" {
+		rule.Field = FieldTag
+	}
+	switch rule.Field {
+	case FieldArtist, FieldTag, FieldType, FieldTitle, FieldYear, FieldDuration:
+	default:
+		return rule, fmt.Errorf("unknown field %q", field)
+	}
+
+	for v := range strings.SplitSeq(values, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if rule.Field != FieldYear && rule.Field != FieldDuration {
+			rule.Values = append(rule.Values, strings.ToLower(v))
+			continue
+		}
+		r, err := parseRange(v)
+		if err != nil {
+			return rule, err
+		}
+		rule.Ranges = append(rule.Ranges, r)
+	}
+	if len(rule.Values) == 0 && len(rule.Ranges) == 0 {
+		return rule, fmt.Errorf("no values")
+	}
+	return rule, nil
+}
+
+func parseRange(s string) (numRange, error) {
+	r := numRange{math.MinInt, math.MaxInt}
+	lo, hi, isRange := strings.Cut(s, "-")
+	if !isRange {
+		hi = lo
+	}
+	var err error
+	if lo = strings.TrimSpace(lo); lo != "" {
+		if r.min, err = strconv.Atoi(lo); err != nil {
+			return r, fmt.Errorf("invalid number %q", lo)
+		}
+	}
+	if hi = strings.TrimSpace(hi); hi != "" {
+		if r.max, err = strconv.Atoi(hi); err != nil {
+			return r, fmt.Errorf("invalid number %q", hi)
+		}
+	}
+	if r.min > r.max {
+		return r, fmt.Errorf("empty range %q", s)
+	}
+	return r, nil
+}
+
+// String formats the rule the way it's written in the config
+func (r Rule) String() string {
+	action := "include"
+	if r.Exclude {
+		action = "exclude"
+	}
+	values := r.Values
+	for _, rng := range r.Ranges {
+		values = append(values[:len(values):len(values)], rng.String())
+	}
+	return fmt.Sprintf("%s %s:%s", action, r.Field, strings.Join(values, ","))
+}
+
+func (r numRange) String() string {
+	switch {
+	case r.min == r.max:
+		return strconv.Itoa(r.min)
+	case r.min == math.MinInt:
+		return fmt.Sprintf("-%d", r.max)
+	case r.max == math.MaxInt:
+		return fmt.Sprintf("%d-", r.min)
+	}
+	return fmt.Sprintf("%d-%d", r.min, r.max)
+}
+
+// match reports if the track matches one of the rule's values, known is false when the
+// track lacks the field
+func (r Rule) match(t *models.Track) (matched, known bool) {
+	switch r.Field {
+	case FieldArtist:
+		names := append([]string{t.MainArtist, t.Artist, t.MainArtistID, t.MusicBrainzArtistID}, t.Artists...)
+		return r.matchAny(names, strings.EqualFold), true
+	case FieldTag:
+		if t.Genres == "" {
+			return false, false
+		}
+		return r.matchAny(strings.Split(t.Genres, ";"), strings.EqualFold), true
+	case FieldType:
+		if t.ReleaseType == "" && t.ReleaseStatus == "" {
+			return false, false
+		}
+		return r.matchAny([]string{t.ReleaseType, t.ReleaseStatus}, strings.EqualFold), true
+	case FieldTitle:
+		title := t.CleanTitle
+		if title == "" {
+			title = t.Title
+		}
+		return r.matchAny([]string{strings.ToLower(title)}, strings.Contains), true
+	case FieldYear:
+		if t.OriginalYear == 0 {
+			return false, false
+		}
+		return r.inRange(t.OriginalYear), true
+	case FieldDuration:
+		if t.Duration == 0 {
+			return false, false
+		}
+		return r.inRange(t.Duration / 1000), true
+	}
+	return false, false
+}
+
+func (r Rule) matchAny(fields []string, cmp func(field, value string) bool) bool {
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		for _, v := range r.Values {
+			if cmp(f, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r Rule) inRange(n int) bool {
+	for _, rng := range r.Ranges {
+		if n >= rng.min && n <= rng.max {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the tracks the rules keep, logging every dropped track with the rule that
+// dropped it
+func (rules Rules) Apply(tracks []*models.Track) []*models.Track {
+	if len(rules) == 0 {
+		return tracks
+	}
+	filtered := make([]*models.Track, 0, len(tracks))
+	for _, t := range tracks {
+		if rule, drop := rules.dropRule(t); drop {
+			slog.Info("track removed by rule",
+				"track", t.CleanTitle,
+				"artist", t.MainArtist,
+				"rule", rule,
+			)
+			continue
+		}
+		filtered = append(filtered, t)
+	}
+	return filtered
+}
+
+// dropRule returns the rule that drops the track, for include rules that's all of them
+// for the field that didn't match
+func (rules Rules) dropRule(t *models.Track) (string, bool) {
+	included := make(map[string]bool) // field → an include rule matched, or the field is unknown
+	var includes []string             // fields with include rules, in config order
+	for _, r := range rules {
+		matched, known := r.match(t)
+		if r.Exclude {
+			if matched {
+				return r.String(), true
+			}
+			continue
+		}
+		if _, seen := included[r.Field]; !seen {
+			includes = append(includes, r.Field)
+		}
+		included[r.Field] = included[r.Field] || matched || !known
+	}
+	for _, field := range includes {
+		if included[field] {
+			continue
+		}
+		var failed []string
+		for _, r := range rules {
+			if !r.Exclude && r.Field == field {
+				failed = append(failed, r.String())
+			}
+		}
+		return strings.Join(failed, " | "), true
+	}
+	return "", false
+}
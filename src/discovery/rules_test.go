@@ -0,0 +1,131 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+
+	"explo/src/models"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string // rules formatted back with String
+		wantErr string
+	}{
+		{in: "", want: nil},
+		{in: " ; ;", want: nil},
+		{in: "exclude artist:Drake", want: []string{"exclude artist:drake"}},
+		{
+			in:   "Exclude Artist: Drake , 8e68819d-71be-4e7d-b41d-f1df81b01d3f ; include genre:Rock,metal",
+			want: []string{"exclude artist:drake,8e68819d-71be-4e7d-b41d-f1df81b01d3f", "include tag:rock,metal"},
+		},
+		{in: "include year:1990-2009,2015", want: []string{"include year:1990-2009,2015"}},
+		{in: "include year:2000-", want: []string{"include year:2000-"}},
+		{in: "exclude duration:-90", want: []string{"exclude duration:-90"}},
+		{in: "exclude title:live,remix", want: []string{"exclude title:live,remix"}},
+		{in: "keep artist:Drake", wantErr: "must start with include or exclude"},
+		{in: "exclude Drake", wantErr: "expected <field>:<values>"},
+		{in: "exclude mood:sad", wantErr: "unknown field"},
+		{in: "include tag: , ", wantErr: "no values"},
+		{in: "include year:nineties", wantErr: "invalid number"},
+		{in: "include year:2010-1990", wantErr: "empty range"},
+		{in: "exclude artist:Drake; include year:x", wantErr: `invalid track rule "include year:x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			rules, err := ParseRules(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range rules {
+				got = append(got, r.String())
+			}
+			if strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+				t.Errorf("ParseRules(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDropRule(t *testing.T) {
+	enriched := &models.Track{
+		CleanTitle:          "Paranoid Android",
+		Artist:              "Radiohead",
+		MainArtist:          "Radiohead",
+		MusicBrainzArtistID: "a74b1b7f-71a5-4011-9441-d0b5e4122711",
+		Genres:              "Alternative Rock;art rock",
+		ReleaseType:         "Album",
+		ReleaseStatus:       "Official",
+		OriginalYear:        1997,
+		Duration:            387000,
+	}
+	bare := &models.Track{CleanTitle: "Paranoid Android (Live)", Artist: "Radiohead", MainArtist: "Radiohead"}
+	featured := &models.Track{CleanTitle: "Stan", Artist: "Eminem feat. Dido", MainArtist: "Eminem", Artists: []string{"Eminem", "Dido"}}
+
+	tests := []struct {
+		name  string
+		rules string
+		track *models.Track
+		want  string // rule that drops the track, empty if it's kept
+	}{
+		{"no rules", "", enriched, ""},
+		{"exclude artist by name", "exclude artist:RADIOHEAD", enriched, "exclude artist:radiohead"},
+		{"exclude artist by mbid", "exclude artist:a74b1b7f-71a5-4011-9441-d0b5e4122711", enriched, "exclude artist:a74b1b7f-71a5-4011-9441-d0b5e4122711"},
+		{"exclude featured artist", "exclude artist:dido", featured, "exclude artist:dido"},
+		{"exclude other artist", "exclude artist:muse", enriched, ""},
+		{"include tag", "include tag:art rock", enriched, ""},
+		{"include tag misses", "include tag:jazz,blues", enriched, "include tag:jazz,blues"},
+		{"include tag unknown", "include tag:jazz", bare, ""},
+		{"include year range", "include year:1990-1999", enriched, ""},
+		{"include year misses", "include year:2000-", enriched, "include year:2000-"},
+		{"exclude type", "exclude type:bootleg,official", enriched, "exclude type:bootleg,official"},
+		{"exclude type unknown", "exclude type:bootleg", bare, ""},
+		{"include duration in seconds", "include duration:90-400", enriched, ""},
+		{"exclude long tracks", "exclude duration:360-", enriched, "exclude duration:360-"},
+		{"exclude title keyword", "exclude title:live", bare, "exclude title:live"},
+		{"exclude title keyword misses", "exclude title:live", enriched, ""},
+		{"include rules of a field are ORed", "include tag:jazz; include tag:alternative rock", enriched, ""},
+		{"all include rules of a field reported", "include tag:jazz; include year:1997; include tag:blues", enriched, "include tag:jazz | include tag:blues"},
+		{"include fields are ANDed", "include tag:rock,alternative rock; include year:2001", enriched, "include year:2001"},
+		{"exclude wins over include", "include tag:alternative rock; exclude artist:radiohead", enriched, "exclude artist:radiohead"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseRules(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, drop := rules.dropRule(tt.track)
+			if drop != (tt.want != "") || got != tt.want {
+				t.Errorf("dropRule() = %q, %v, want %q", got, drop, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulesApply(t *testing.T) {
+	tracks := []*models.Track{
+		{CleanTitle: "One", MainArtist: "Keep"},
+		{CleanTitle: "Two", MainArtist: "Drop"},
+		{CleanTitle: "Three", MainArtist: "Keep"},
+	}
+	rules, err := ParseRules("exclude artist:drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := rules.Apply(tracks)
+	if len(got) != 2 || got[0] != tracks[0] || got[1] != tracks[2] {
+		t.Errorf("Apply kept %v", got)
+	}
+	if len(tracks) != 3 || tracks[1].CleanTitle != "Two" {
+		t.Error("Apply modified its input")
+	}
+}
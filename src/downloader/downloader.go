@@ -10,10 +10,13 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	cfg "explo/src/config"
+	"explo/src/metrics"
 	"explo/src/models"
+	"explo/src/progress"
 	"explo/src/util"
 
 	ffmpeg "github.com/u2takey/ffmpeg-go"
@@ -25,14 +28,23 @@ import (
 type DownloadClient struct {
 	Cfg         *cfg.DownloadConfig
 	Downloaders []Downloader
+
+	mu          sync.Mutex
+	requestedAt map[*models.Track]time.Time // when GetTrack was called, for transfer durations
 }
 
 type Downloader interface {
-	QueryTrack(*models.Track) error
-	GetTrack(*models.Track) error
+	QueryTrack(context.Context, *models.Track) error
+	GetTrack(context.Context, *models.Track) error
 	Monitor
 }
 
+// Optional interface for downloaders that keep state on a remote service (searches, queued transfers)
+// which should be removed when a run is cancelled
+type Canceller interface {
+	CancelDownloads(context.Context, []*models.Track) error
+}
+
 // get download services from config and append them to DownloadClient
 func NewDownloader(cfg *cfg.DownloadConfig, httpClient *util.HttpClient, filterLocal bool) (*DownloadClient, error) {
 	var downloader []Downloader
@@ -54,7 +66,7 @@ func NewDownloader(cfg *cfg.DownloadConfig, httpClient *util.HttpClient, filterL
 		Downloaders: downloader}, nil
 }
 
-func (c *DownloadClient) StartDownload(tracks *[]*models.Track) {
+func (c *DownloadClient) StartDownload(ctx context.Context, tracks *[]*models.Track) {
 	if c.Cfg.ExcludeLocal { // remove locally found tracks, so they can't be added to playlist
 		filterLocalTracks(tracks, true)
 	}
@@ -66,8 +78,24 @@ func (c *DownloadClient) StartDownload(tracks *[]*models.Track) {
 		}
 	}
 
-	for _, d := range c.Downloaders {
-		var g errgroup.Group
+	shared := loadSharedIndex(c.Cfg.SharedIndex)
+	if n := shared.claim(*tracks, c.Cfg.Profile); n > 0 {
+		slog.Info(fmt.Sprintf("%d track(s) were already downloaded for another profile", n))
+	}
+	defer shared.save()
+
+	lastTried := make(map[*models.Track]string) // last service that tried each track
+	for i, d := range c.Downloaders {
+		service := c.Cfg.Services[i]
+		var pending []*models.Track
+		for _, track := range *tracks {
+			if !track.Present {
+				pending = append(pending, track)
+				lastTried[track] = service
+			}
+		}
+
+		g, gctx := errgroup.WithContext(ctx)
 		g.SetLimit(3)
 
 		limiter := rate.NewLimiter(rate.Every(time.Second), c.Cfg.DownloadLimiter)
@@ -80,25 +108,38 @@ func (c *DownloadClient) StartDownload(tracks *[]*models.Track) {
 			track := track
 
 			g.Go(func() error {
-				ctx := context.Background()
-
-				if err := limiter.Wait(ctx); err != nil {
+				if err := limiter.Wait(gctx); err != nil {
 					return err
 				}
 
-				if err := d.QueryTrack(track); err != nil {
+				progress.Track(track, progress.StateSearching, service, "")
+				searchStart := time.Now()
+				err := d.QueryTrack(gctx, track)
+				metrics.SearchDuration.WithLabelValues(service).Observe(time.Since(searchStart).Seconds())
+				if err != nil {
 					slog.Warn(err.Error())
+					track.DownloadError = fmt.Sprintf("%s: %s", service, err.Error())
+					progress.Track(track, progress.StateFailed, service, track.DownloadError)
 					return nil
 				}
 
-				if err := limiter.Wait(ctx); err != nil {
+				if err := limiter.Wait(gctx); err != nil {
 					return err
 				}
 
-				if err := d.GetTrack(track); err != nil {
+				c.markRequested(track)
+				if err := d.GetTrack(gctx, track); err != nil {
 					slog.Warn(err.Error())
+					track.DownloadError = fmt.Sprintf("%s: %s", service, err.Error())
+					progress.Track(track, progress.StateFailed, service, track.DownloadError)
 					return nil
 				}
+				if track.Present { // downloaded synchronously (youtube)
+					if d, ok := c.transferTime(track); ok {
+						metrics.TransferDuration.WithLabelValues(service).Observe(d.Seconds())
+					}
+				}
+				progress.Track(track, progress.StateQueued, service, "")
 
 				return nil
 			})
@@ -106,18 +147,78 @@ func (c *DownloadClient) StartDownload(tracks *[]*models.Track) {
 
 		if err := g.Wait(); err != nil {
 			slog.Warn(err.Error())
+			if ctx.Err() != nil {
+				c.cancelDownloads(ctx, d, *tracks)
+			}
 			return
 		}
 
 		if m, ok := d.(Monitor); ok {
-			if err := c.MonitorDownloads(*tracks, m); err != nil {
+			if err := c.MonitorDownloads(ctx, *tracks, m); err != nil {
 				slog.Warn(err.Error())
 			}
 		}
+
+		for _, track := range pending {
+			if track.Present {
+				track.DownloadService = service
+				track.DownloadError = ""
+				metrics.TracksDownloaded.WithLabelValues(service).Inc()
+				shared.record(track, service, c.Cfg.Profile)
+			}
+		}
+
+		if ctx.Err() != nil {
+			c.cancelDownloads(ctx, d, *tracks)
+			return
+		}
 	}
 
+	// a track only failed once every service had a go at it
+	for track, service := range lastTried {
+		if !track.Present {
+			metrics.TracksFailed.WithLabelValues(service).Inc()
+		}
+	}
 	filterLocalTracks(tracks, false)
 }
+
+func (c *DownloadClient) markRequested(track *models.Track) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.requestedAt == nil {
+		c.requestedAt = make(map[*models.Track]time.Time)
+	}
+	c.requestedAt[track] = time.Now()
+}
+
+// transferTime returns how long ago the track's download was requested
+func (c *DownloadClient) transferTime(track *models.Track) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.requestedAt[track]
+	if !ok {
+		return 0, false
+	}
+	delete(c.requestedAt, track)
+	return time.Since(t), true
+}
+
+// remove leftover remote state after the run got cancelled, using a fresh context so the requests can still go out
+func (c *DownloadClient) cancelDownloads(ctx context.Context, d Downloader, tracks []*models.Track) {
+	canceller, ok := d.(Canceller)
+	if !ok {
+		return
+	}
+
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	if err := canceller.CancelDownloads(cleanupCtx, tracks); err != nil {
+		slog.Warn("failed to cancel pending downloads", "context", err.Error())
+	}
+}
+
 func (c *DownloadClient) needsDownloadDir() bool {
 	for _, svc := range c.Cfg.Services {
 		if svc == "youtube" || svc == "youtube-music" {
@@ -127,14 +228,24 @@ func (c *DownloadClient) needsDownloadDir() bool {
 	return c.Cfg.Slskd.MigrateDL
 }
 
+// DeleteSongs empties DownloadDir, except for files other profiles' playlists still use
 func (c *DownloadClient) DeleteSongs() {
+	shared := loadSharedIndex(c.Cfg.SharedIndex)
+	inUse := shared.release(c.Cfg.DownloadDir, c.Cfg.Profile)
+	defer shared.save()
+
 	entries, err := os.ReadDir(c.Cfg.DownloadDir)
 	if err != nil {
 		slog.Error("failed to read directory", "context", err.Error())
 	}
 	for _, entry := range entries {
 		if !(entry.IsDir()) {
-			err = os.Remove(path.Join(c.Cfg.DownloadDir, entry.Name()))
+			file := path.Join(c.Cfg.DownloadDir, entry.Name())
+			if inUse[file] {
+				slog.Debug("keeping file used by another profile", "file", file)
+				continue
+			}
+			err = os.Remove(file)
 
 			if err != nil {
 				slog.Error("failed to remove file", "context", err.Error())
@@ -325,6 +436,7 @@ func (c *DownloadClient) MoveDownload(srcDir, destDir, trackPath string, track *
 	if err = os.Remove(srcFile); err != nil {
 		return fmt.Errorf("failed to delete original file: %s", err.Error())
 	}
+	track.Path = dstFile
 
 	isEmpty, err := isDirEmpty(trackDir)
 	if err != nil {
@@ -351,6 +463,9 @@ func overwriteMetadata(metadata []string, srcFile string) error {
 		tmpFile := tempAudioFile(srcFile)
 
 		if err := util.WriteMetadata(streams, "", tmpFile, opts); err != nil {
+			if rerr := os.Remove(tmpFile); rerr != nil && !os.IsNotExist(rerr) {
+				slog.Debug(fmt.Sprintf("failed to remove %s", tmpFile), "context", rerr.Error())
+			}
 			return fmt.Errorf("failed to overwrite metadata: %w", err)
 		} else {
 			if err := os.Rename(tmpFile, srcFile); err != nil {
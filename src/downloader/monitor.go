@@ -1,8 +1,11 @@
 package downloader
 
 import (
+	"context"
 	"explo/src/logging"
+	"explo/src/metrics"
 	"explo/src/models"
+	"explo/src/progress"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -10,9 +13,9 @@ import (
 )
 
 type Monitor interface {
-	GetDownloadStatus([]*models.Track) (map[string]FileStatus, error)
+	GetDownloadStatus(context.Context, []*models.Track) (map[string]FileStatus, error)
 	GetConf() (MonitorConfig, error)
-	Cleanup(models.Track, string) error
+	Cleanup(context.Context, models.Track, string) error
 }
 
 type MonitorConfig struct {
@@ -34,7 +37,7 @@ type FileStatus struct {
 	PercentComplete  float64   `json:"percentComplete"`
 }
 
-func (c *DownloadClient) MonitorDownloads(tracks []*models.Track, m Monitor) error {
+func (c *DownloadClient) MonitorDownloads(ctx context.Context, tracks []*models.Track, m Monitor) error {
 	var successDownloads int
 
 	progressMap := make(map[string]*DownloadMonitor)
@@ -46,8 +49,14 @@ func (c *DownloadClient) MonitorDownloads(tracks []*models.Track, m Monitor) err
 	ticker := time.NewTicker(monCfg.CheckInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		statuses, err := m.GetDownloadStatus(tracks)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		statuses, err := m.GetDownloadStatus(ctx, tracks)
 		if err != nil {
 			return fmt.Errorf("[%s/monitor] error fetching download status: %s", monCfg.Service, err.Error())
 		}
@@ -78,6 +87,8 @@ func (c *DownloadClient) MonitorDownloads(tracks []*models.Track, m Monitor) err
 				if tracker.Counter >= 2 {
 					slog.Info("[monitor] track not found in queue after retries, skipping", "service", monCfg.Service,"track title", track.CleanTitle, "track artist", track.MainArtist)
 					tracker.Skipped = true
+					track.DownloadError = fmt.Sprintf("%s: not found in download queue", monCfg.Service)
+					progress.Track(track, progress.StateFailed, monCfg.Service, track.DownloadError)
 				}
 				continue
 			}
@@ -85,6 +96,10 @@ func (c *DownloadClient) MonitorDownloads(tracks []*models.Track, m Monitor) err
 			if fileStatus.BytesRemaining == 0 || fileStatus.PercentComplete == 100 || strings.Contains(fileStatus.State, "Succeeded") {		
 				track.Present = true
 				slog.Info("[monitor] file downloaded successfully", "service", monCfg.Service, "file", track.File)
+				progress.Track(track, progress.StateDownloaded, monCfg.Service, "")
+				if d, ok := c.transferTime(track); ok {
+					metrics.TransferDuration.WithLabelValues(monCfg.Service).Observe(d.Seconds())
+				}
 				var path string
 				track.File, path = parsePath(track.File)
 				if monCfg.MigrateDownload {
@@ -96,7 +111,7 @@ func (c *DownloadClient) MonitorDownloads(tracks []*models.Track, m Monitor) err
 				}
 				delete(progressMap, key)
 				successDownloads += 1
-				if err = m.Cleanup(*track, fileStatus.ID); err != nil {
+				if err = m.Cleanup(ctx, *track, fileStatus.ID); err != nil {
 					slog.Debug("cleanup failed", logging.RuntimeAttr(err.Error()))
 				}
 				continue
@@ -105,12 +120,15 @@ func (c *DownloadClient) MonitorDownloads(tracks []*models.Track, m Monitor) err
 				tracker.LastBytesTransferred = fileStatus.BytesTransferred
 				tracker.LastUpdated = currentTime
 				slog.Info("[monitor] progress updated", "service", monCfg.Service, "file", track.File, "bytes transferred", fileStatus.BytesTransferred)
+				progress.Bytes(track, monCfg.Service, fileStatus.BytesTransferred, fileStatus.Size, fileStatus.PercentComplete)
 				continue
 
 			} else if currentTime.Sub(tracker.LastUpdated) > monCfg.MonitorDuration || fileStatus.State == "Errored" {
 				slog.Info("[monitor] no download progress for file, skipping", "service", monCfg.Service, "file", track.File, "duration", monCfg.MonitorDuration)
 				tracker.Skipped = true
-				if err = m.Cleanup(*track, fileStatus.ID); err != nil {
+				track.DownloadError = fmt.Sprintf("%s: no download progress (state %s)", monCfg.Service, fileStatus.State)
+				progress.Track(track, progress.StateFailed, monCfg.Service, track.DownloadError)
+				if err = m.Cleanup(ctx, *track, fileStatus.ID); err != nil {
 					slog.Debug("cleanup failed", logging.RuntimeAttr(err.Error()))
 				}
 				continue
@@ -122,7 +140,6 @@ func (c *DownloadClient) MonitorDownloads(tracks []*models.Track, m Monitor) err
 			return nil
 		}
 	}
-	return nil
 }
 
 // Checks if all tracks are processed (either downloaded or skipped)
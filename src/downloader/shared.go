@@ -0,0 +1,155 @@
+package downloader
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"explo/src/models"
+	"explo/src/progress"
+)
+
+// sharedTTL is how long a download is reused for other profiles. By then the media server
+// has normally scanned the file, so the library check finds it without the index.
+const sharedTTL = 7 * 24 * time.Hour
+
+// sharedDownload is a track some profile already downloaded
+type sharedDownload struct {
+	Service      string    `json:"service"`
+	File         string    `json:"file"`
+	Path         string    `json:"path,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+	Profiles     []string  `json:"profiles,omitempty"` // profiles whose playlists use the file
+}
+
+// profileRef names a profile in the index, the default profile has no name
+func profileRef(profile string) string {
+	if profile == "" {
+		return "default"
+	}
+	return profile
+}
+
+// sharedIndex remembers downloads across runs and profiles so a track wanted by
+// several people is fetched once, even before the media server has picked it up.
+type sharedIndex struct {
+	path    string
+	entries map[string]sharedDownload
+}
+
+func loadSharedIndex(path string) *sharedIndex {
+	idx := &sharedIndex{path: path, entries: map[string]sharedDownload{}}
+	if path == "" {
+		return idx
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("failed to read shared download index", "path", path, "err", err.Error())
+		}
+		return idx
+	}
+	if err := json.Unmarshal(data, &idx.entries); err != nil {
+		slog.Warn("failed to parse shared download index", "path", path, "err", err.Error())
+	}
+	return idx
+}
+
+// claim marks tracks that were downloaded recently and are still on disk as present for the
+// profile, returns how many it claimed
+func (idx *sharedIndex) claim(tracks []*models.Track, profile string) int {
+	var n int
+	for _, t := range tracks {
+		if t.Present {
+			continue
+		}
+		key := progress.Key(t)
+		e, ok := idx.entries[key]
+		if !ok || time.Since(e.DownloadedAt) > sharedTTL {
+			continue
+		}
+		if _, err := os.Stat(e.Path); e.Path == "" || err != nil {
+			delete(idx.entries, key) // removed since, or recorded without a path
+			continue
+		}
+		if ref := profileRef(profile); !slices.Contains(e.Profiles, ref) {
+			e.Profiles = append(e.Profiles, ref)
+			idx.entries[key] = e
+		}
+		t.Present = true
+		t.File = e.File
+		t.Path = e.Path
+		t.DownloadService = e.Service
+		slog.Info("track was already downloaded, reusing it", "service", e.Service, "track", t.CleanTitle, "artist", t.MainArtist)
+		n++
+	}
+	return n
+}
+
+func (idx *sharedIndex) record(t *models.Track, service, profile string) {
+	key := progress.Key(t)
+	profiles := []string{profileRef(profile)}
+	if old, ok := idx.entries[key]; ok && old.Path == t.Path {
+		// downloaded to the same file again, the profiles using it still do
+		for _, p := range old.Profiles {
+			if !slices.Contains(profiles, p) {
+				profiles = append(profiles, p)
+			}
+		}
+	}
+	idx.entries[key] = sharedDownload{
+		Service:      service,
+		File:         t.File,
+		Path:         t.Path,
+		DownloadedAt: time.Now().UTC(),
+		Profiles:     profiles,
+	}
+}
+
+// save drops expired entries and writes the index
+func (idx *sharedIndex) save() {
+	if idx.path == "" {
+		return
+	}
+	for k, e := range idx.entries {
+		if time.Since(e.DownloadedAt) > sharedTTL {
+			delete(idx.entries, k)
+		}
+	}
+	data, err := json.MarshalIndent(idx.entries, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(idx.path), 0755)
+	}
+	if err == nil {
+		tmp := idx.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0644); err == nil {
+			err = os.Rename(tmp, idx.path)
+		}
+	}
+	if err != nil {
+		slog.Warn("failed to save shared download index", "path", idx.path, "err", err.Error())
+	}
+}
+
+// release drops the profile from the downloads in dir, and forgets the ones no other profile
+// uses. Returns the paths other profiles still use, which must not be deleted.
+func (idx *sharedIndex) release(dir, profile string) map[string]bool {
+	ref := profileRef(profile)
+	inUse := make(map[string]bool)
+	for k, e := range idx.entries {
+		if e.Path == "" || filepath.Dir(e.Path) != filepath.Clean(dir) {
+			continue
+		}
+		e.Profiles = slices.DeleteFunc(e.Profiles, func(p string) bool { return p == ref })
+		if len(e.Profiles) == 0 {
+			delete(idx.entries, k)
+			continue
+		}
+		idx.entries[k] = e
+		inUse[filepath.Clean(e.Path)] = true
+	}
+	return inUse
+}
@@ -2,6 +2,7 @@ package downloader
 
 import (
 	"bytes" // Could be moved to util for all clients
+	"context"
 	"encoding/json"
 	"errors"
 	"explo/src/config"
@@ -132,25 +133,25 @@ func (c *Slskd) GetConf() (MonitorConfig, error) {
 
 var errNoRes = errors.New("no results found for query")
 
-func (c *Slskd) QueryTrack(track *models.Track) error {
+func (c *Slskd) QueryTrack(ctx context.Context, track *models.Track) error {
 
 	wildcardSearch := false
 	trackDetails := fmt.Sprintf("%s - %s", track.CleanTitle, track.Artist)
 
 	retry:
-		ID, err := c.searchTrack(trackDetails)
+		ID, err := c.searchTrack(ctx, trackDetails)
 		if err != nil {
 			return err
 		}
 		slog.Info("initiating search", "track", trackDetails)
 
 		cleanup := func() {
-    		if err := c.deleteSearch(ID); err != nil {
+    		if err := c.deleteSearch(context.WithoutCancel(ctx), ID); err != nil {
         		slog.Warn("failed to delete search", "context", err.Error())
     		}
 		}
 
-		completed, err := c.searchStatus(ID, trackDetails, 0)
+		completed, err := c.searchStatus(ctx, ID, trackDetails, 0)
 		if errors.Is(err, errNoRes) && !wildcardSearch {
 			cleanup()
 			wildcardSearch = true
@@ -174,8 +175,8 @@ func (c *Slskd) QueryTrack(track *models.Track) error {
 		return nil
 }
 
-func (c *Slskd) GetTrack(track *models.Track) error {
-	results, err := c.searchResults(track.ID)
+func (c *Slskd) GetTrack(ctx context.Context, track *models.Track) error {
+	results, err := c.searchResults(ctx, track.ID)
 	if err != nil {
 		return err
 	}
@@ -187,13 +188,13 @@ func (c *Slskd) GetTrack(track *models.Track) error {
 	if err != nil {
 		return err
 	}
-	if err := c.queueDownload(filterFiles, track); err != nil {
+	if err := c.queueDownload(ctx, filterFiles, track); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c Slskd) searchTrack(trackDetails string) (string, error) {
+func (c Slskd) searchTrack(ctx context.Context, trackDetails string) (string, error) {
 	reqParams := "/api/v0/searches"
 
 	payloadStr := SearchPayload{
@@ -204,7 +205,7 @@ func (c Slskd) searchTrack(trackDetails string) (string, error) {
 		return "", fmt.Errorf("failed to marshal search payload %w", err)
 	}
 
-	body, err := c.HttpClient.MakeRequest("POST", c.Cfg.URL+reqParams, bytes.NewReader(payload), c.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "POST", c.Cfg.URL+reqParams, bytes.NewReader(payload), c.Headers)
 	if err != nil {
 		return "", err
 	}
@@ -215,10 +216,10 @@ func (c Slskd) searchTrack(trackDetails string) (string, error) {
 	return queryResult.ID, nil
 }
 
-func (c Slskd) searchStatus(ID, trackDetails string, count int) (bool, error) { // Recursive func to see if search for track is finished
+func (c Slskd) searchStatus(ctx context.Context, ID, trackDetails string, count int) (bool, error) { // Recursive func to see if search for track is finished
 	reqParams := fmt.Sprintf("/api/v0/searches/%s", ID)
 
-	body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+reqParams, nil, c.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+reqParams, nil, c.Headers)
 	if err != nil {
 		return false, err
 	}
@@ -238,14 +239,16 @@ func (c Slskd) searchStatus(ID, trackDetails string, count int) (bool, error) {
 	}
 
 	slog.Debug(fmt.Sprintf("[%s] (%d/%d) Searching for %s", "slskd", count, c.Cfg.Retry, trackDetails))
-	time.Sleep(15 * time.Second)
-	return c.searchStatus(ID, trackDetails, count+1)
+	if err := util.Sleep(ctx, 15*time.Second); err != nil {
+		return false, err
+	}
+	return c.searchStatus(ctx, ID, trackDetails, count+1)
 }
 
-func (c Slskd) searchResults(ID string) (SearchResults, error) {
+func (c Slskd) searchResults(ctx context.Context, ID string) (SearchResults, error) {
 	reqParams := fmt.Sprintf("/api/v0/searches/%s/responses", ID)
 
-	body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+reqParams, nil, c.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+reqParams, nil, c.Headers)
 	if err != nil {
 		return nil, err
 	}
@@ -257,10 +260,10 @@ func (c Slskd) searchResults(ID string) (SearchResults, error) {
 	return results, nil
 }
 
-func (c Slskd) deleteSearch(ID string) error {
+func (c Slskd) deleteSearch(ctx context.Context, ID string) error {
 	reqParams := fmt.Sprintf("/api/v0/searches/%s", ID)
 
-	_, err := c.HttpClient.MakeRequest("DELETE", c.Cfg.URL+reqParams, nil, c.Headers)
+	_, err := c.HttpClient.MakeRequest(ctx, "DELETE", c.Cfg.URL+reqParams, nil, c.Headers)
 	if err != nil {
 		return err
 	}
@@ -339,7 +342,7 @@ func (c Slskd) filterFiles(files []File) ([]File, error) {
 	return filtered, nil
 }
 
-func (c Slskd) queueDownload(files []File, track *models.Track) error {
+func (c Slskd) queueDownload(ctx context.Context, files []File, track *models.Track) error {
 	for i, file := range files {
 		reqParams := fmt.Sprintf("/api/v0/transfers/downloads/%s", file.Username)
 		payload := []DownloadPayload{
@@ -354,7 +357,7 @@ func (c Slskd) queueDownload(files []File, track *models.Track) error {
 			return fmt.Errorf("failed to marshal payload: %s", err.Error())
 		}
 
-		_, err = c.HttpClient.MakeRequest("POST", c.Cfg.URL+reqParams, bytes.NewBuffer(DLpayload), c.Headers)
+		_, err = c.HttpClient.MakeRequest(ctx, "POST", c.Cfg.URL+reqParams, bytes.NewBuffer(DLpayload), c.Headers)
 		if err == nil {
 			track.MainArtistID = file.Username
 			track.Size = file.Size
@@ -365,17 +368,17 @@ func (c Slskd) queueDownload(files []File, track *models.Track) error {
 		slog.Warn(fmt.Sprintf("[%d/%d] failed to queue download for '%s - %s': %s", i+1, len(files), track.CleanTitle, track.Artist, err.Error()))
 		continue
 	}
-	if err := c.deleteSearch(track.ID); err != nil {
+	if err := c.deleteSearch(context.WithoutCancel(ctx), track.ID); err != nil {
 		slog.Debug("failed to delete search", logging.RuntimeAttr(err.Error()))
 	}
 	return fmt.Errorf("couldn't download track: %s - %s", track.CleanTitle, track.Artist)
 }
 
 
-func (c *Slskd) GetDownloadStatus(tracks []*models.Track) (map[string]FileStatus, error) {
+func (c *Slskd) GetDownloadStatus(ctx context.Context, tracks []*models.Track) (map[string]FileStatus, error) {
 	reqParams := "/api/v0/transfers/downloads"
 	fileStatuses := make(map[string]FileStatus, len(tracks))
-	body, err := c.HttpClient.MakeRequest("GET", c.Cfg.URL+reqParams, nil, c.Headers)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", c.Cfg.URL+reqParams, nil, c.Headers)
 	if err != nil {
 		return nil, err
 	}
@@ -412,32 +415,67 @@ func (c *Slskd) GetDownloadStatus(tracks []*models.Track) (map[string]FileStatus
 	return nil, fmt.Errorf("no files found to monitor")
 }
 
-func (c Slskd) deleteDownload(user, ID string) error {
+func (c Slskd) deleteDownload(ctx context.Context, user, ID string) error {
 	reqParams := fmt.Sprintf("/api/v0/transfers/downloads/%s/%s", user, ID)
 
 	// cancel download
-	if _, err := c.HttpClient.MakeRequest("DELETE", c.Cfg.URL+reqParams+"?remove=false", nil, c.Headers); err != nil {
+	if _, err := c.HttpClient.MakeRequest(ctx, "DELETE", c.Cfg.URL+reqParams+"?remove=false", nil, c.Headers); err != nil {
 		return fmt.Errorf("soft delete failed: %s", err.Error())
 	}
-	time.Sleep(1 * time.Second) // Small buffer between soft and hard delete
+	if err := util.Sleep(ctx, 1*time.Second); err != nil { // Small buffer between soft and hard delete
+		return err
+	}
 	// delete download
-	if _, err := c.HttpClient.MakeRequest("DELETE", c.Cfg.URL+reqParams+"?remove=true", nil, c.Headers); err != nil {
+	if _, err := c.HttpClient.MakeRequest(ctx, "DELETE", c.Cfg.URL+reqParams+"?remove=true", nil, c.Headers); err != nil {
 		return fmt.Errorf("hard delete failed: %s", err.Error())
 	}
 
 	return nil
 }
 
-func (c *Slskd) Cleanup(track models.Track, fileID string) error {
-	if err := c.deleteSearch(track.ID); err != nil {
+func (c *Slskd) Cleanup(ctx context.Context, track models.Track, fileID string) error {
+	if err := c.deleteSearch(ctx, track.ID); err != nil {
 		slog.Debug("failed to delete search request", logging.RuntimeAttr(err.Error()))
 	}
-	if err := c.deleteDownload(track.MainArtistID, fileID); err != nil {
+	if err := c.deleteDownload(ctx, track.MainArtistID, fileID); err != nil {
 		slog.Debug("failed to delete download", logging.RuntimeAttr(err.Error()))
 	}
 	return nil
 }
 
+// CancelDownloads removes searches and queued transfers for tracks that didn't finish downloading
+func (c *Slskd) CancelDownloads(ctx context.Context, tracks []*models.Track) error {
+	var pending []*models.Track
+	for _, track := range tracks {
+		if !track.Present && track.ID != "" {
+			pending = append(pending, track)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	statuses, err := c.GetDownloadStatus(ctx, pending)
+	if err != nil {
+		slog.Debug("[slskd] no transfers to cancel", logging.RuntimeAttr(err.Error()))
+	}
+
+	for _, track := range pending {
+		if err := c.deleteSearch(ctx, track.ID); err != nil {
+			slog.Debug("failed to delete search request", logging.RuntimeAttr(err.Error()))
+		}
+		status, ok := statuses[track.File]
+		if !ok || strings.Contains(status.State, "Succeeded") {
+			continue
+		}
+		if err := c.deleteDownload(ctx, track.MainArtistID, status.ID); err != nil {
+			slog.Debug("failed to delete download", logging.RuntimeAttr(err.Error()))
+		}
+	}
+	slog.Info("[slskd] cancelled pending searches and downloads", "tracks", len(pending))
+	return nil
+}
+
 func parsePath(p string) (string, string) { // parse filepath to downloaded format, return filename and parent dir
 	p = strings.ReplaceAll(p, `\`, `/`)
 	return filepath.Base(p), filepath.Base(filepath.Dir(p))
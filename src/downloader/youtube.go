@@ -74,18 +74,23 @@ func (c *Youtube) GetConf() (MonitorConfig, error) {
 	return MonitorConfig{}, fmt.Errorf("[youtube] no monitoring required")
 }
 
-func (c *Youtube) QueryTrack(track *models.Track) error { // Queries youtube for the song
+func (c *Youtube) QueryTrack(ctx context.Context, track *models.Track) error { // Queries youtube for the song
+
+	if track.VideoID != "" { // the playlist source already knows the video
+		track.ID = track.VideoID
+		return nil
+	}
 
 	query := fmt.Sprintf("%s - %s", track.Title, track.Artist)
 	if c.Cfg.APIKey == "" { // if no API key set, use Python YT Music module
-		err := queryYTMusic(track, query)
+		err := queryYTMusic(ctx, track, query)
 		return err
 	}
 
 	escQuery := url.PathEscape(query)
 	queryURL := fmt.Sprintf("https://youtube.googleapis.com/youtube/v3/search?part=snippet&q=%s&type=video&videoCategoryId=10&key=%s", escQuery, c.Cfg.APIKey)
 
-	body, err := c.HttpClient.MakeRequest("GET", queryURL, nil, nil)
+	body, err := c.HttpClient.MakeRequest(ctx, "GET", queryURL, nil, nil)
 	if err != nil {
 		return err
 	}
@@ -103,11 +108,11 @@ func (c *Youtube) QueryTrack(track *models.Track) error { // Queries youtube for
 	return nil
 }
 
-func queryYTMusic(track *models.Track, query string) error {
+func queryYTMusic(ctx context.Context, track *models.Track, query string) error {
 
 	slog.Debug(fmt.Sprintf("Querying YTMusic for track %s", query))
 
-	cmd := exec.Command("python3", "search_ytmusic.py", query, "1")
+	cmd := exec.CommandContext(ctx, "python3", "search_ytmusic.py", query, "1")
 
 	out, err := cmd.Output()
 	if err != nil {
@@ -129,11 +134,10 @@ func queryYTMusic(track *models.Track, query string) error {
 	return nil
 }
 
-func (c *Youtube) GetTrack(track *models.Track) error {
-	ctx := context.Background() // ctx for yt-dlp
-
+func (c *Youtube) GetTrack(ctx context.Context, track *models.Track) error {
 	track.File = fmt.Sprintf("%s.%s", getFilename(track.Title, track.Artist), c.Cfg.FileExtension)
-	track.Present = fetchAndSaveVideo(ctx, *c, *track)
+	track.Path = fetchAndSaveVideo(ctx, *c, *track)
+	track.Present = track.Path != ""
 
 	if track.Present {
 		slog.Info("download finished", "service", "youtube", "track", track.File)
@@ -175,7 +179,7 @@ func getVideo(ctx context.Context, c Youtube, videoID string) (*goutubedl.Downlo
 
 }
 
-func saveVideo(c Youtube, track models.Track, stream *goutubedl.DownloadResult) bool {
+func saveVideo(ctx context.Context, c Youtube, track models.Track, stream *goutubedl.DownloadResult) string {
 
 	defer func() {
 		if err := stream.Close(); err != nil {
@@ -187,7 +191,7 @@ func saveVideo(c Youtube, track models.Track, stream *goutubedl.DownloadResult)
 	file, err := os.Create(input)
 	if err != nil {
 		slog.Error("failed to create song file", "context", err.Error())
-		return false
+		return ""
 	}
 
 	defer func() {
@@ -207,7 +211,11 @@ func saveVideo(c Youtube, track models.Track, stream *goutubedl.DownloadResult)
 
 	if _, err = io.Copy(file, stream); err != nil {
 		slog.Error("failed to copy stream to file", "context", err.Error())
-		return false
+		return ""
+	}
+
+	if ctx.Err() != nil { // run was cancelled while the stream was copied
+		return ""
 	}
 
 	metadata := util.BuildffmpegMetadata(track)
@@ -223,7 +231,7 @@ func saveVideo(c Youtube, track models.Track, stream *goutubedl.DownloadResult)
 
 	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
 			slog.Error("failed to create output directory", "context", err.Error())
-			return false
+			return ""
 	}
 
 	var opts ffmpeg.KwArgs
@@ -248,10 +256,13 @@ func saveVideo(c Youtube, track models.Track, stream *goutubedl.DownloadResult)
 	}
 
 	if err := util.WriteMetadata(streams, c.Cfg.FfmpegPath, outputPath, opts); err != nil {
-		return false
+		if rerr := os.Remove(outputPath); rerr != nil && !os.IsNotExist(rerr) { // don't leave partial output behind
+			slog.Debug(fmt.Sprintf("failed to remove %s", outputPath), logging.RuntimeAttr(rerr.Error()))
+		}
+		return ""
 	}
 
-	return true
+	return outputPath
 }
 
 // filter out video ID
@@ -272,25 +283,26 @@ func (c *Youtube) gatherVideo(cfg cfg.Youtube, videos Videos, track models.Track
 	return ""
 }
 
-func fetchAndSaveVideo(ctx context.Context, cfg Youtube, track models.Track) bool {
+// fetchAndSaveVideo downloads the track's video as audio, returns the output path or "" on failure
+func fetchAndSaveVideo(ctx context.Context, cfg Youtube, track models.Track) string {
 	stream, err := getVideo(ctx, cfg, track.ID)
 	if err != nil {
 		slog.Error("failed getting stream for video", "trackID", track.ID, "context", err.Error())
-		return false
+		return ""
 	}
 
 	if stream != nil {
-		return saveVideo(cfg, track, stream)
+		return saveVideo(ctx, cfg, track, stream)
 	}
 
 	slog.Error("stream was empty for video", "trackID", track.ID)
-	return false
+	return ""
 }
 
-func (c *Youtube) GetDownloadStatus(tracks []*models.Track) (map[string]FileStatus, error) {
+func (c *Youtube) GetDownloadStatus(ctx context.Context, tracks []*models.Track) (map[string]FileStatus, error) {
 	return nil, fmt.Errorf("no monitoring required")
 }
 
-func (c *Youtube) Cleanup(track models.Track, ID string) error {
+func (c *Youtube) Cleanup(ctx context.Context, track models.Track, ID string) error {
 	return nil
 }
@@ -0,0 +1,293 @@
+// Package export writes generated playlists in portable formats: extended M3U8, XSPF and JSPF,
+// so they can be used by players and services that explo doesn't talk to directly.
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"explo/src/models"
+	"explo/src/util"
+)
+
+// Formats lists the supported export formats, they double as file extensions
+var Formats = []string{"m3u8", "xspf", "jspf"}
+
+// ContentTypes are the MIME types exports are served with
+var ContentTypes = map[string]string{
+	"m3u8": "audio/x-mpegurl",
+	"xspf": "application/xspf+xml",
+	"jspf": "application/json",
+}
+
+// Options control how track locations are written
+type Options struct {
+	// RelativeTo makes paths relative to this directory, empty writes absolute paths
+	RelativeTo string
+}
+
+// ParseFormats splits a comma separated format list, rejecting unknown formats
+func ParseFormats(list string) ([]string, error) {
+	var out []string
+	for f := range strings.SplitSeq(list, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" || slices.Contains(out, f) {
+			continue
+		}
+		if !slices.Contains(Formats, f) {
+			return nil, fmt.Errorf("unknown export format %q (must be one of: %s)", f, strings.Join(Formats, ", "))
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// FileName returns the file an export named base is written to
+func FileName(base, format string) string {
+	return util.FilenameSafe(base) + "." + format
+}
+
+// WriteFiles writes the playlist titled name to dir in each format, as base.<format>.
+// It returns the written paths, a failed format is logged and skipped.
+func WriteFiles(dir, base, name string, tracks []*models.Track, formats []string, opts Options) []string {
+	if len(formats) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		slog.Warn("failed to create export directory", "dir", dir, "err", err.Error())
+		return nil
+	}
+	var written []string
+	for _, format := range formats {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, name, tracks, opts); err != nil {
+			slog.Warn("failed to export playlist", "format", format, "err", err.Error())
+			continue
+		}
+		path := filepath.Join(dir, FileName(base, format))
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+			slog.Warn("failed to write playlist export", "path", path, "err", err.Error())
+			continue
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			slog.Warn("failed to write playlist export", "path", path, "err", err.Error())
+			continue
+		}
+		written = append(written, path)
+	}
+	return written
+}
+
+// Write encodes the playlist in format. Only tracks that made it into the playlist
+// (Present) are written.
+func Write(w io.Writer, format, name string, tracks []*models.Track, opts Options) error {
+	var present []*models.Track
+	for _, t := range tracks {
+		if t.Present {
+			present = append(present, t)
+		}
+	}
+	switch format {
+	case "m3u8":
+		return writeM3U8(w, name, present, opts)
+	case "xspf":
+		return writeXSPF(w, name, present, opts)
+	case "jspf":
+		return writeJSPF(w, name, present, opts)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// location returns the track's file path as configured by opts, "" if it isn't known.
+// Tracks matched in the music system without being downloaded only have a file name.
+func location(t *models.Track, opts Options) string {
+	p := t.Path
+	if p == "" {
+		return t.File
+	}
+	if opts.RelativeTo != "" {
+		if rel, err := filepath.Rel(opts.RelativeTo, p); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return p
+}
+
+// fileURL turns a location into a URI for XSPF/JSPF, relative paths stay relative references
+func fileURL(loc string) string {
+	if loc == "" {
+		return ""
+	}
+	u := url.URL{Path: filepath.ToSlash(loc)}
+	if filepath.IsAbs(loc) {
+		u.Scheme = "file"
+	}
+	return u.String()
+}
+
+func mbURL(entity, mbid string) string {
+	if mbid == "" {
+		return ""
+	}
+	return "https://musicbrainz.org/" + entity + "/" + mbid
+}
+
+// ── M3U8 ─────────────────────────────────────────────────────────────────────
+
+func writeM3U8(w io.Writer, name string, tracks []*models.Track, opts Options) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	fmt.Fprintf(&b, "#PLAYLIST:%s\n", name)
+	for _, t := range tracks {
+		loc := location(t, opts)
+		if loc == "" { // M3U entries are locations, nothing to write
+			continue
+		}
+		seconds := -1
+		if t.Duration > 0 {
+			seconds = t.Duration / 1000
+		}
+		fmt.Fprintf(&b, "#EXTINF:%d,%s - %s\n%s\n", seconds, oneLine(t.Artist), oneLine(t.CleanTitle), loc)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func oneLine(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+// ── XSPF ─────────────────────────────────────────────────────────────────────
+
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Version string      `xml:"version,attr"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	Creator string      `xml:"creator"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location   string   `xml:"location,omitempty"`
+	Identifier []string `xml:"identifier,omitempty"`
+	Title      string   `xml:"title"`
+	Creator    string   `xml:"creator"`
+	Album      string   `xml:"album,omitempty"`
+	TrackNum   int      `xml:"trackNum,omitempty"`
+	Duration   int      `xml:"duration,omitempty"` // milliseconds
+	Image      string   `xml:"image,omitempty"`
+}
+
+func writeXSPF(w io.Writer, name string, tracks []*models.Track, opts Options) error {
+	pl := xspfPlaylist{
+		Version: "1",
+		XMLNS:   "http://xspf.org/ns/0/",
+		Title:   name,
+		Creator: "Explo",
+		Tracks:  make([]xspfTrack, 0, len(tracks)),
+	}
+	for _, t := range tracks {
+		xt := xspfTrack{
+			Location: fileURL(location(t, opts)),
+			Title:    t.CleanTitle,
+			Creator:  t.Artist,
+			Album:    t.Album,
+			TrackNum: t.TrackNumber,
+			Duration: t.Duration,
+		}
+		if id := mbURL("recording", t.MusicBrainzTrackID); id != "" {
+			xt.Identifier = append(xt.Identifier, id)
+		}
+		if strings.HasPrefix(t.CoverURL, "http") {
+			xt.Image = t.CoverURL
+		}
+		pl.Tracks = append(pl.Tracks, xt)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(pl); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ── JSPF ─────────────────────────────────────────────────────────────────────
+
+// jspfTrackExt is the MusicBrainz JSPF track extension, see https://musicbrainz.org/doc/jspf
+type jspfTrackExt struct {
+	ArtistIdentifiers []string `json:"artist_identifiers,omitempty"`
+	ReleaseIdentifier string   `json:"release_identifier,omitempty"`
+}
+
+type jspfTrack struct {
+	Location   []string                `json:"location,omitempty"`
+	Identifier []string                `json:"identifier,omitempty"`
+	Title      string                  `json:"title"`
+	Creator    string                  `json:"creator"`
+	Album      string                  `json:"album,omitempty"`
+	TrackNum   int                     `json:"trackNum,omitempty"`
+	Duration   int                     `json:"duration,omitempty"`
+	Image      string                  `json:"image,omitempty"`
+	Extension  map[string]jspfTrackExt `json:"extension,omitempty"`
+}
+
+type jspfDoc struct {
+	Playlist struct {
+		Title   string      `json:"title"`
+		Creator string      `json:"creator"`
+		Track   []jspfTrack `json:"track"`
+	} `json:"playlist"`
+}
+
+func writeJSPF(w io.Writer, name string, tracks []*models.Track, opts Options) error {
+	var doc jspfDoc
+	doc.Playlist.Title = name
+	doc.Playlist.Creator = "Explo"
+	doc.Playlist.Track = make([]jspfTrack, 0, len(tracks))
+	for _, t := range tracks {
+		jt := jspfTrack{
+			Title:    t.CleanTitle,
+			Creator:  t.Artist,
+			Album:    t.Album,
+			TrackNum: t.TrackNumber,
+			Duration: t.Duration,
+		}
+		if loc := fileURL(location(t, opts)); loc != "" {
+			jt.Location = []string{loc}
+		}
+		if id := mbURL("recording", t.MusicBrainzTrackID); id != "" {
+			jt.Identifier = []string{id}
+		}
+		if strings.HasPrefix(t.CoverURL, "http") {
+			jt.Image = t.CoverURL
+		}
+		var ext jspfTrackExt
+		if id := mbURL("artist", t.MusicBrainzArtistID); id != "" {
+			ext.ArtistIdentifiers = []string{id}
+		}
+		ext.ReleaseIdentifier = mbURL("release", t.MusicBrainzAlbumID)
+		if ext.ArtistIdentifiers != nil || ext.ReleaseIdentifier != "" {
+			jt.Extension = map[string]jspfTrackExt{"https://musicbrainz.org/doc/jspf#track": ext}
+		}
+		doc.Playlist.Track = append(doc.Playlist.Track, jt)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,96 @@
+package lbsync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"explo/src/client"
+	"explo/src/config"
+	"explo/src/listenbrainz"
+	"explo/src/report"
+)
+
+// feedbackState is the feedback already sent, per ListenBrainz user and recording MBID
+type feedbackState map[string]map[string]int
+
+func feedbackStatePath(dataDir string) string {
+	return filepath.Join(dataDir, "listenbrainz-feedback.json")
+}
+
+// SyncFeedback sends the user's favourites and ratings of tracks Explo added to playlists
+// to ListenBrainz as recording feedback: loved tracks as love, hated ones as hate. Feedback
+// is only sent when a rating changed since the last sync, a track that's no longer rated
+// has its feedback removed.
+func SyncFeedback(ctx context.Context, cfg *config.Config, c *client.Client) error {
+	lbCfg := cfg.DiscoveryCfg.Listenbrainz
+	if lbCfg.Token == "" || lbCfg.User == "" {
+		return fmt.Errorf("feedback sync requires LISTENBRAINZ_USER and LISTENBRAINZ_TOKEN")
+	}
+
+	added, err := report.Added(filepath.Join(cfg.ServerCfg.WebDataDir, "runs"), cfg.System, cfg.Flags.Profile)
+	if err != nil {
+		return fmt.Errorf("failed to read run reports: %w", err)
+	}
+	mbids := make(map[string]string) // library ID → recording MBID, from the newest run
+	var ids []string
+	for _, t := range added {
+		if _, seen := mbids[t.LibraryID]; !seen && t.MBID != "" {
+			mbids[t.LibraryID] = t.MBID
+			ids = append(ids, t.LibraryID)
+		}
+	}
+	if len(ids) == 0 {
+		slog.Info("feedback sync: no tracks with a recording MBID in earlier runs")
+		return nil
+	}
+
+	ratings, err := c.GetRatings(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	statePath := feedbackStatePath(cfg.ServerCfg.WebDataDir)
+	state := feedbackState{}
+	loadState(statePath, &state)
+	sent := state[lbCfg.User]
+	if sent == nil {
+		sent = make(map[string]int)
+		state[lbCfg.User] = sent
+	}
+
+	lb := listenbrainz.NewClient(lbCfg.Token)
+	var submitted, failed int
+	for _, id := range ids {
+		rating, ok := ratings[id]
+		mbid := mbids[id]
+		if !ok || rating == sent[mbid] {
+			continue
+		}
+		if err := lb.SubmitFeedback(ctx, mbid, rating); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			slog.Warn("feedback sync: failed to submit feedback", "mbid", mbid, "err", err.Error())
+			failed++
+			continue
+		}
+		slog.Debug("feedback sync: submitted feedback", "mbid", mbid, "score", rating)
+		if rating == listenbrainz.FeedbackRemove {
+			delete(sent, mbid)
+		} else {
+			sent[mbid] = rating
+		}
+		submitted++
+	}
+
+	if err := saveState(statePath, state); err != nil {
+		slog.Warn("feedback sync: failed to save state", "path", statePath, "err", err.Error())
+	}
+	slog.Info("feedback sync finished", "tracks", len(ids), "submitted", submitted, "failed", failed)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package lbsync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"explo/src/client"
+	"explo/src/config"
+	"explo/src/listenbrainz"
+	"explo/src/report"
+)
+
+const (
+	// playDedupWindow treats plays of a track this close to the last submitted one as the
+	// same play, Subsonic's now playing only has minute precision
+	playDedupWindow = 2 * time.Minute
+	// historyOverlap is how far before the last sync play history is read again, for plays
+	// the music system records late
+	historyOverlap = 24 * time.Hour
+)
+
+// listensState is the last sync and the last submitted listen per track, per ListenBrainz user
+type listensState map[string]*userListens
+
+type userListens struct {
+	LastSync  time.Time        `json:"last_sync"`
+	Submitted map[string]int64 `json:"submitted"` // listen key → listened_at of the last submitted play
+}
+
+func listensStatePath(dataDir string) string {
+	return filepath.Join(dataDir, "listenbrainz-listens.json")
+}
+
+// listenKey identifies a track across music systems
+func listenKey(t report.AddedTrack) string {
+	if t.MBID != "" {
+		return t.MBID
+	}
+	return strings.ToLower(t.Artist + "|" + t.Title)
+}
+
+// SyncListens submits the user's plays of tracks Explo added to playlists to ListenBrainz, for
+// players that don't scrobble. Only plays after a track was added count, and every play is
+// submitted once.
+func SyncListens(ctx context.Context, cfg *config.Config, c *client.Client) error {
+	lbCfg := cfg.DiscoveryCfg.Listenbrainz
+	if lbCfg.Token == "" || lbCfg.User == "" {
+		return fmt.Errorf("listen sync requires LISTENBRAINZ_USER and LISTENBRAINZ_TOKEN")
+	}
+
+	added, err := report.Added(filepath.Join(cfg.ServerCfg.WebDataDir, "runs"), cfg.System, cfg.Flags.Profile)
+	if err != nil {
+		return fmt.Errorf("failed to read run reports: %w", err)
+	}
+	tracks := make(map[string]report.AddedTrack) // library ID → track, from the oldest run that added it
+	var ids []string
+	for _, t := range added {
+		if _, seen := tracks[t.LibraryID]; !seen {
+			ids = append(ids, t.LibraryID)
+		}
+		tracks[t.LibraryID] = t // reports are newest first
+	}
+	if len(ids) == 0 {
+		slog.Info("listen sync: no tracks in earlier runs")
+		return nil
+	}
+
+	statePath := listensStatePath(cfg.ServerCfg.WebDataDir)
+	state := listensState{}
+	loadState(statePath, &state)
+	user := state[lbCfg.User]
+	if user == nil {
+		user = &userListens{}
+		state[lbCfg.User] = user
+	}
+	if user.Submitted == nil {
+		user.Submitted = make(map[string]int64)
+	}
+
+	since := added[len(added)-1].AddedAt
+	if !user.LastSync.IsZero() && user.LastSync.Add(-historyOverlap).After(since) {
+		since = user.LastSync.Add(-historyOverlap)
+	}
+	syncStart := time.Now()
+	plays, err := c.GetPlays(ctx, ids, since)
+	if err != nil {
+		return err
+	}
+	sort.Slice(plays, func(i, j int) bool { return plays[i].PlayedAt.Before(plays[j].PlayedAt) })
+
+	var listens []listenbrainz.Listen
+	submitted := make(map[string]int64)
+	for _, p := range plays {
+		t, ok := tracks[p.ID]
+		if !ok || !p.PlayedAt.After(t.AddedAt) {
+			continue
+		}
+		key := listenKey(t)
+		last, ok := submitted[key]
+		if !ok {
+			last = user.Submitted[key]
+		}
+		if last != 0 && !p.PlayedAt.After(time.Unix(last, 0).Add(playDedupWindow)) {
+			continue
+		}
+		listens = append(listens, listenbrainz.Listen{
+			ListenedAt:    p.PlayedAt,
+			Title:         t.Title,
+			Artist:        t.Artist,
+			Album:         t.Album,
+			RecordingMBID: t.MBID,
+			MediaPlayer:   cfg.System,
+		})
+		submitted[key] = p.PlayedAt.Unix()
+	}
+
+	if len(listens) > 0 {
+		if err := listenbrainz.NewClient(lbCfg.Token).SubmitListens(ctx, listens); err != nil {
+			return err
+		}
+	}
+	for key, at := range submitted {
+		user.Submitted[key] = at
+	}
+	user.LastSync = syncStart
+	if err := saveState(statePath, state); err != nil {
+		slog.Warn("listen sync: failed to save state", "path", statePath, "err", err.Error())
+	}
+	slog.Info("listen sync finished", "tracks", len(ids), "plays", len(plays), "submitted", len(listens))
+	return nil
+}
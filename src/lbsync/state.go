@@ -0,0 +1,37 @@
+// Package lbsync contains jobs that send what happens in the music system back to
+// ListenBrainz, for the tracks Explo added to playlists.
+package lbsync
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// loadState reads a job's JSON state file into target, a missing or unreadable file
+// leaves target as is
+func loadState(path string, target any) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		slog.Warn("lbsync: failed to parse state, starting over", "path", path, "err", err.Error())
+	}
+}
+
+func saveState(path string, state any) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,63 @@
+// Package listenbrainz writes to a user's ListenBrainz account with their user token.
+// Reading playlists and recommendations is part of discovery, which needs no token.
+package listenbrainz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"explo/src/util"
+)
+
+const apiBase = "https://api.listenbrainz.org/1"
+
+// Client sends authenticated ListenBrainz API requests
+type Client struct {
+	http  *util.HttpClient
+	token string
+}
+
+// NewClient returns a client for the user the token belongs to, see
+// https://listenbrainz.org/settings/ for the token
+func NewClient(token string) *Client {
+	httpClient := util.NewHttp(util.HttpClientConfig{Timeout: 30})
+	httpClient.Cache = nil // the user's own data changes with every write
+	return &Client{http: httpClient, token: token}
+}
+
+func (c *Client) request(ctx context.Context, method, path string, payload, target any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("listenbrainz: failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	resp, err := c.http.MakeRequest(ctx, method, apiBase+path, body, c.headers())
+	if err != nil {
+		return fmt.Errorf("listenbrainz: %w", err)
+	}
+	if target == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp, target); err != nil {
+		return fmt.Errorf("listenbrainz: invalid response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) headers() map[string]string {
+	return map[string]string{"Authorization": "Token " + c.token}
+}
+
+// isNotFound reports if a request failed because the entity doesn't exist (anymore)
+func isNotFound(err error) bool {
+	var statusErr *util.StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
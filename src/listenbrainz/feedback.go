@@ -0,0 +1,26 @@
+package listenbrainz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Feedback scores
+const (
+	FeedbackHate   = -1
+	FeedbackRemove = 0 // clears earlier feedback
+	FeedbackLove   = 1
+)
+
+// SubmitFeedback loves or hates a recording, FeedbackRemove takes earlier feedback back
+func (c *Client) SubmitFeedback(ctx context.Context, recordingMBID string, score int) error {
+	payload := struct {
+		RecordingMBID string `json:"recording_mbid"`
+		Score         int    `json:"score"`
+	}{recordingMBID, score}
+	if err := c.request(ctx, http.MethodPost, "/feedback/recording-feedback", payload, nil); err != nil {
+		return fmt.Errorf("failed to submit feedback for %s: %w", recordingMBID, err)
+	}
+	return nil
+}
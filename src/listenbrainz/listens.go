@@ -0,0 +1,68 @@
+package listenbrainz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"explo/src/config"
+)
+
+// maxListensPerRequest keeps submissions well below ListenBrainz's request size limit
+const maxListensPerRequest = 100
+
+// Listen is a play of a recording
+type Listen struct {
+	ListenedAt    time.Time
+	Title         string
+	Artist        string
+	Album         string
+	RecordingMBID string
+	MediaPlayer   string // music system the track was played on
+}
+
+type listenPayload struct {
+	ListenedAt    int64 `json:"listened_at"`
+	TrackMetadata struct {
+		ArtistName     string `json:"artist_name"`
+		TrackName      string `json:"track_name"`
+		ReleaseName    string `json:"release_name,omitempty"`
+		AdditionalInfo struct {
+			RecordingMBID           string `json:"recording_mbid,omitempty"`
+			MediaPlayer             string `json:"media_player,omitempty"`
+			SubmissionClient        string `json:"submission_client"`
+			SubmissionClientVersion string `json:"submission_client_version"`
+		} `json:"additional_info"`
+	} `json:"track_metadata"`
+}
+
+// SubmitListens imports past listens
+func (c *Client) SubmitListens(ctx context.Context, listens []Listen) error {
+	for len(listens) > 0 {
+		batch := listens[:min(len(listens), maxListensPerRequest)]
+		listens = listens[len(batch):]
+
+		payload := make([]listenPayload, len(batch))
+		for i, l := range batch {
+			p := &payload[i]
+			p.ListenedAt = l.ListenedAt.Unix()
+			p.TrackMetadata.ArtistName = l.Artist
+			p.TrackMetadata.TrackName = l.Title
+			p.TrackMetadata.ReleaseName = l.Album
+			info := &p.TrackMetadata.AdditionalInfo
+			info.RecordingMBID = l.RecordingMBID
+			info.MediaPlayer = l.MediaPlayer
+			info.SubmissionClient = "Explo"
+			info.SubmissionClientVersion = config.Version
+		}
+		body := struct {
+			ListenType string          `json:"listen_type"`
+			Payload    []listenPayload `json:"payload"`
+		}{"import", payload}
+		if err := c.request(ctx, http.MethodPost, "/submit-listens", body, nil); err != nil {
+			return fmt.Errorf("failed to submit listens: %w", err)
+		}
+	}
+	return nil
+}
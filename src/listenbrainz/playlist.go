@@ -0,0 +1,165 @@
+package listenbrainz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"explo/src/models"
+)
+
+// maxItemsPerRequest is the most recordings ListenBrainz accepts in one create or add request
+const maxItemsPerRequest = 100
+
+type jspfTrack struct {
+	Identifier string `json:"identifier"`
+}
+
+type jspfPlaylistExt struct {
+	Public bool `json:"public"`
+}
+
+type jspfPlaylist struct {
+	Title      string                     `json:"title,omitempty"`
+	Annotation string                     `json:"annotation,omitempty"`
+	Track      []jspfTrack                `json:"track,omitempty"`
+	Extension  map[string]jspfPlaylistExt `json:"extension,omitempty"`
+}
+
+type jspf struct {
+	Playlist jspfPlaylist `json:"playlist"`
+}
+
+// PlaylistInfo is the published playlist's metadata
+type PlaylistInfo struct {
+	Title      string
+	Annotation string
+	Public     bool
+}
+
+func (p PlaylistInfo) jspf(tracks []jspfTrack) jspf {
+	return jspf{Playlist: jspfPlaylist{
+		Title:      p.Title,
+		Annotation: p.Annotation,
+		Track:      tracks,
+		Extension:  map[string]jspfPlaylistExt{"https://musicbrainz.org/doc/jspf#playlist": {Public: p.Public}},
+	}}
+}
+
+// PublishPlaylist creates or replaces a playlist on the user's ListenBrainz account with the
+// given tracks. Tracks without a recording MBID can't be added and are skipped.
+// mbid is the playlist written by an earlier run, if it was deleted on ListenBrainz a new
+// playlist is created. The returned MBID identifies the playlist for the next run.
+func (c *Client) PublishPlaylist(ctx context.Context, mbid string, info PlaylistInfo, tracks []*models.Track) (string, error) {
+	var items []jspfTrack
+	for _, t := range tracks {
+		if t.MusicBrainzTrackID != "" {
+			items = append(items, jspfTrack{Identifier: "https://musicbrainz.org/recording/" + t.MusicBrainzTrackID})
+		}
+	}
+	if skipped := len(tracks) - len(items); skipped > 0 {
+		slog.Info("listenbrainz: skipping tracks without a recording MBID", "count", skipped)
+	}
+
+	if mbid != "" {
+		err := c.replacePlaylist(ctx, mbid, info, items)
+		if err == nil || !isNotFound(err) {
+			return mbid, err
+		}
+		slog.Info("listenbrainz: published playlist no longer exists, creating a new one", "mbid", mbid)
+	}
+	return c.createPlaylist(ctx, info, items)
+}
+
+func (c *Client) createPlaylist(ctx context.Context, info PlaylistInfo, items []jspfTrack) (string, error) {
+	first := items[:min(len(items), maxItemsPerRequest)]
+	var resp struct {
+		PlaylistMBID string `json:"playlist_mbid"`
+	}
+	if err := c.request(ctx, http.MethodPost, "/playlist/create", info.jspf(first), &resp); err != nil {
+		return "", fmt.Errorf("failed to create playlist: %w", err)
+	}
+	if resp.PlaylistMBID == "" {
+		return "", fmt.Errorf("failed to create playlist: no playlist MBID in response")
+	}
+	if err := c.addItems(ctx, resp.PlaylistMBID, items[len(first):]); err != nil {
+		return resp.PlaylistMBID, err
+	}
+	return resp.PlaylistMBID, nil
+}
+
+// replacePlaylist updates the metadata and swaps all tracks of an existing playlist
+func (c *Client) replacePlaylist(ctx context.Context, mbid string, info PlaylistInfo, items []jspfTrack) error {
+	var current jspf
+	if err := c.request(ctx, http.MethodGet, "/playlist/"+mbid+"?fetch_metadata=false", nil, &current); err != nil {
+		return err
+	}
+	if err := c.request(ctx, http.MethodPost, "/playlist/edit/"+mbid, info.jspf(nil), nil); err != nil {
+		return fmt.Errorf("failed to update playlist details: %w", err)
+	}
+	if n := len(current.Playlist.Track); n > 0 {
+		del := map[string]int{"index": 0, "count": n}
+		if err := c.request(ctx, http.MethodPost, "/playlist/"+mbid+"/item/delete", del, nil); err != nil {
+			return fmt.Errorf("failed to clear playlist: %w", err)
+		}
+	}
+	return c.addItems(ctx, mbid, items)
+}
+
+func (c *Client) addItems(ctx context.Context, mbid string, items []jspfTrack) error {
+	for len(items) > 0 {
+		batch := items[:min(len(items), maxItemsPerRequest)]
+		items = items[len(batch):]
+		if err := c.request(ctx, http.MethodPost, "/playlist/"+mbid+"/item/add", jspf{Playlist: jspfPlaylist{Track: batch}}, nil); err != nil {
+			return fmt.Errorf("failed to add tracks to playlist: %w", err)
+		}
+	}
+	return nil
+}
+
+// ── Published playlist state ─────────────────────────────────────────────────
+
+// PublishedPlaylists maps "<LB user>/<playlist>" to the MBID of the ListenBrainz playlist
+// it's published to, so every run updates the same playlist
+type PublishedPlaylists map[string]string
+
+// PublishedKey identifies a published playlist in PublishedPlaylists
+func PublishedKey(user, playlist string) string {
+	return user + "/" + playlist
+}
+
+func PublishedPath(dataDir string) string {
+	return filepath.Join(dataDir, "listenbrainz-playlists.json")
+}
+
+// LoadPublished reads the published playlists, an unreadable file is treated as empty
+func LoadPublished(path string) PublishedPlaylists {
+	published := PublishedPlaylists{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return published
+	}
+	if err := json.Unmarshal(data, &published); err != nil {
+		slog.Warn("listenbrainz: failed to parse published playlists", "path", path, "err", err.Error())
+	}
+	return published
+}
+
+func (p PublishedPlaylists) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
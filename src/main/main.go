@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"explo/src/logging"
 	"explo/src/models"
@@ -9,13 +10,23 @@ import (
 	"log"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"explo/src/client"
 	"explo/src/config"
 	"explo/src/discovery"
 	"explo/src/downloader"
+	"explo/src/export"
+	"explo/src/lbsync"
+	"explo/src/listenbrainz"
+	"explo/src/metrics"
+	"explo/src/progress"
+	"explo/src/report"
+	"explo/src/sources"
 	"explo/src/util"
 )
 
@@ -26,64 +37,87 @@ type Song struct {
 }
 
 // loadCustomTracks reads a custom playlist's track cache and returns them as
-// models.Track slices, bypassing the LB discovery step entirely.
-func loadCustomTracks(dataDir, playlistID string) ([]*models.Track, string, error) {
+// models.Track slices, bypassing the LB discovery step entirely. Without a cache
+// the tracks are fetched from the playlist's source.
+func loadCustomTracks(ctx context.Context, dataDir, playlistID string) ([]*models.Track, string, error) {
 	type cachedTrack struct {
-		Title      string `json:"title"`
-		Artist     string `json:"artist"`
-		MainArtist string `json:"mainArtist"`
-		Release    string `json:"release"`
-		CoverURL   string `json:"coverUrl"`
-		CoverPath  string `json:"coverPath"`
+		Title      string   `json:"title"`
+		Artist     string   `json:"artist"`
+		MainArtist string   `json:"mainArtist"`
+		Release    string   `json:"release"`
+		CoverURL   string   `json:"coverUrl"`
+		CoverPath  string   `json:"coverPath"`
+		ISRCs      []string `json:"isrcs"`
+		VideoID    string   `json:"videoId"`
 	}
 	type cacheFile struct {
 		Tracks []cachedTrack `json:"tracks"`
 	}
 	type customPlaylist struct {
-		ID   string `json:"id"`
-		Name string `json:"name"`
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Source    string `json:"source"`
+		SourceURL string `json:"source_url"`
+		LBMBID    string `json:"lb_mbid"`
 	}
 
-	data, err := os.ReadFile(filepath.Join(dataDir, "cache", playlistID+".json"))
-	if err != nil {
-		return nil, "", fmt.Errorf("custom playlist %q not found in cache: %w", playlistID, err)
-	}
-	var c cacheFile
-	if err := json.Unmarshal(data, &c); err != nil {
-		return nil, "", fmt.Errorf("failed to parse custom playlist cache: %w", err)
-	}
-
-	// Look up the human-readable name from metadata
-	name := playlistID
-	if meta, err := os.ReadFile(filepath.Join(dataDir, "custom-playlists.json")); err == nil {
+	// Look up the human-readable name and source from metadata
+	meta := customPlaylist{ID: playlistID, Name: playlistID}
+	if raw, err := os.ReadFile(filepath.Join(dataDir, "custom-playlists.json")); err == nil {
 		var all []customPlaylist
-		if json.Unmarshal(meta, &all) == nil {
+		if json.Unmarshal(raw, &all) == nil {
 			for _, p := range all {
 				if p.ID == playlistID {
-					name = p.Name
+					meta = p
 					break
 				}
 			}
 		}
 	}
 
+	data, err := os.ReadFile(filepath.Join(dataDir, "cache", playlistID+".json"))
+	if err != nil {
+		url := meta.SourceURL
+		if url == "" {
+			url = meta.LBMBID
+		}
+		if url == "" || meta.Source == sources.FileSource { // uploads only exist as cache
+			return nil, "", fmt.Errorf("custom playlist %q not found in cache: %w", playlistID, err)
+		}
+		src, serr := sources.Get(meta.Source)
+		if serr != nil {
+			return nil, "", serr
+		}
+		slog.Info("no cache for custom playlist, fetching from source", "playlist", playlistID, "source", src.Name())
+		pl, ferr := src.Fetch(ctx, url)
+		if ferr != nil {
+			return nil, "", fmt.Errorf("failed to fetch custom playlist %q: %w", playlistID, ferr)
+		}
+		tracks := make([]*models.Track, len(pl.Tracks))
+		for i, t := range pl.Tracks {
+			tracks[i] = t.Model()
+		}
+		return tracks, meta.Name, nil
+	}
+	var c cacheFile
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, "", fmt.Errorf("failed to parse custom playlist cache: %w", err)
+	}
+
 	tracks := make([]*models.Track, len(c.Tracks))
 	for i, t := range c.Tracks {
-		mainArtist := t.MainArtist
-		if mainArtist == "" {
-			mainArtist = t.Artist
-		}
-		tracks[i] = &models.Track{
-			CleanTitle: t.Title,
+		tracks[i] = sources.Track{
 			Title:      t.Title,
 			Artist:     t.Artist,
-			MainArtist: mainArtist,
+			MainArtist: t.MainArtist,
 			Album:      t.Release,
 			CoverURL:   t.CoverURL,
-			CoverPath:  t.CoverPath,
-		}
+			ISRCs:      t.ISRCs,
+			VideoID:    t.VideoID,
+		}.Model()
+		tracks[i].CoverPath = t.CoverPath
 	}
-	return tracks, name, nil
+	return tracks, meta.Name, nil
 }
 
 func initHttpClient() *util.HttpClient {
@@ -97,22 +131,25 @@ func setup(cfg *config.Config) {
 	cfg.HandleDeprecation()
 	notifyClient := logging.InitNotify(cfg.NotifyCfg)
 	logging.Init(cfg.LogLevel, notifyClient)
+	util.ConfigureRetries(cfg.HTTPRetries, time.Duration(cfg.HTTPMaxWait)*time.Second)
+	util.ConfigureCache(cfg.ServerCfg.HTTPCacheDir, cfg.ServerCfg.HTTPCacheMB<<20)
+	sources.ConfigureYtdlp(cfg.DownloadCfg.Youtube.YtdlpPath)
 	cfg.GenPlaylistDetails()
 }
-func runSearchTest(cfg *config.Config, httpClient *util.HttpClient) {
+func runSearchTest(ctx context.Context, cfg *config.Config, httpClient *util.HttpClient) {
 	lb := discovery.NewListenBrainz(cfg.DiscoveryCfg, httpClient)
-	track, err := lb.LookupRecording(cfg.Flags.SearchMBID)
+	track, err := lb.LookupRecording(ctx, cfg.Flags.SearchMBID)
 	if err != nil {
 		log.Fatalf("failed to resolve MBID %s from ListenBrainz: %s", cfg.Flags.SearchMBID, err)
 	}
 	slog.Info("resolved recording", "title", track.CleanTitle, "artist", track.MainArtist, "album", track.Album, "duration_ms", track.Duration)
 
-	c, err := client.NewClient(cfg)
+	c, err := client.NewClient(ctx, cfg)
 	if err != nil {
 		log.Fatalf("failed to init client: %s", err)
 	}
 	tracks := []*models.Track{track}
-	if err := c.CheckTracks(tracks); err != nil {
+	if err := c.CheckTracks(ctx, tracks); err != nil {
 		slog.Warn("CheckTracks error", "err", err)
 	}
 
@@ -128,13 +165,14 @@ func main() {
 		log.Fatal(err)
 	}
 	cfg.ReadEnv()
+	cfg.ReadProfile()
 	cfg.MergeFlags()
 	setup(&cfg)
 
 	httpClient := initHttpClient()
 
 	if cfg.Flags.SearchMBID != "" {
-		runSearchTest(&cfg, httpClient)
+		runSearchTest(context.Background(), &cfg, httpClient)
 		return
 	}
 
@@ -156,8 +194,12 @@ func main() {
 		log.Fatal(srv.Start())
 	}
 
+	// Cancelled on SIGINT/SIGTERM (docker stop, Ctrl+C, or stopping a run from the web UI)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	if cfg.Flags.RefreshOnly {
-		if err := client.TriggerRefresh(&cfg); err != nil {
+		if err := client.TriggerRefresh(ctx, &cfg); err != nil {
 			slog.Error("refresh-only failed", "err", err.Error())
 			os.Exit(1)
 		}
@@ -165,37 +207,61 @@ func main() {
 		return
 	}
 
-	var tracks []*models.Track
+	if cfg.Flags.SyncFeedback || cfg.Flags.SyncListens {
+		if err := runSyncJobs(ctx, &cfg); err != nil {
+			slog.Error(err.Error(), "notify", true)
+			os.Exit(1)
+		}
+		return
+	}
+
+	progress.Init()
+	metrics.Init(cfg.MetricsFile)
+	rep := report.New(filepath.Join(cfg.ServerCfg.WebDataDir, "runs"), cfg.Flags.RunID, cfg.Flags.Playlist)
+	rep.System = cfg.System
+	rep.DownloadMode = cfg.Flags.DownloadMode
+	rep.Profile = cfg.Flags.Profile
+
+	var tracks, overflow []*models.Track
 	var err error
+	progress.Phase(progress.PhaseDiscovery)
 	if strings.HasPrefix(cfg.Flags.Playlist, "custom-") {
 		var playlistName string
-		tracks, playlistName, err = loadCustomTracks(cfg.ServerCfg.WebDataDir, cfg.Flags.Playlist)
+		tracks, playlistName, err = loadCustomTracks(ctx, cfg.ServerCfg.WebDataDir, cfg.Flags.Playlist)
 		if err == nil {
 			cfg.ClientCfg.PlaylistName = playlistName
 		}
 	} else {
 		disc := discovery.NewDiscoverer(cfg.DiscoveryCfg, httpClient)
-		tracks, err = disc.Discover()
+		tracks, err = disc.Discover(ctx)
+		overflow = disc.Overflow()
 	}
 
-  if err != nil {
-		slog.Error(err.Error(), "notify", true)
-		os.Exit(1)
+	exitIfCancelled(ctx, rep)
+	if err != nil {
+		exitWithError(rep, err)
+	}
+	tracks, err = prepareTracks(ctx, &cfg, httpClient, tracks, overflow)
+	exitIfCancelled(ctx, rep)
+	if err != nil {
+		exitWithError(rep, err)
 	}
 	allTracks := append([]*models.Track(nil), tracks...)
+	rep.PlaylistName = cfg.ClientCfg.PlaylistName
+	rep.SetTracks(allTracks)
+	metrics.TracksDiscovered.WithLabelValues(cfg.Flags.Playlist).Add(float64(len(allTracks)))
+	progress.Tracks(allTracks, progress.StateDiscovered)
 
-	client, err := client.NewClient(&cfg)
+	client, err := client.NewClient(ctx, &cfg)
 	if err != nil {
-		slog.Error(err.Error(), "notify", true)
-		os.Exit(1)
+		exitWithError(rep, err)
 	}
 	downloader, err := downloader.NewDownloader(&cfg.DownloadCfg, httpClient, cfg.Flags.ExcludeLocal)
 	if err != nil {
-		slog.Error(err.Error(), "notify", true)
-		os.Exit(1)
+		exitWithError(rep, err)
 	}
 	if !cfg.Persist {
-		err := client.DeletePlaylist()
+		err := client.DeletePlaylist(ctx)
 		if err != nil {
 			slog.Warn(err.Error(), "notify", true)
 		}
@@ -204,17 +270,29 @@ func main() {
 		}
 	}
 	if cfg.Flags.DownloadMode != "force" {
-		if err := client.CheckTracks(tracks); err != nil { // Check if tracks exist on system before downloading
+		progress.Phase(progress.PhaseLibrary)
+		if err := client.CheckTracks(ctx, tracks); err != nil { // Check if tracks exist on system before downloading
 			slog.Warn(err.Error(), "notify", true)
 		}
+		rep.MarkLibrary(tracks)
+		for _, t := range tracks {
+			if t.Present {
+				progress.Track(t, progress.StateInLibrary, "", "")
+				metrics.TracksMatched.WithLabelValues(cfg.System).Inc()
+			}
+		}
 	}
 
 	if cfg.Flags.DownloadMode != "skip" {
-		downloader.StartDownload(&tracks)
+		progress.Phase(progress.PhaseDownload)
+		downloader.StartDownload(ctx, &tracks)
+		rep.MarkDownloads(false)
+		exitIfCancelled(ctx, rep)
 		if len(tracks) == 0 {
-			slog.Error("couldn't download any tracks", "notify", true)
-			os.Exit(1)
+			exitWithError(rep, fmt.Errorf("couldn't download any tracks"))
 		}
+	} else {
+		rep.MarkDownloads(true)
 	}
 
 	if cfg.ServerCfg.Enabled {
@@ -222,21 +300,170 @@ func main() {
 		for _, t := range tracks {
 			added[t.CleanTitle+"|"+t.Artist] = true
 		}
-		backend.WritePlaylistCache(cfg.Flags.CfgPath, cfg.Flags.Playlist, allTracks, added)
+		backend.WritePlaylistCache(cfg.Flags.CfgPath, backend.PlaylistBase(cfg.Flags.Playlist, cfg.Flags.Profile), allTracks, added)
 	}
 
-	if err := client.CreatePlaylist(tracks); err != nil {
+	progress.Phase(progress.PhasePlaylist)
+	if err := client.CreatePlaylist(ctx, tracks); err != nil {
+		exitIfCancelled(ctx, rep)
 		slog.Warn(err.Error())
+		rep.Finish(report.StatusFailed, err)
 	} else {
 		slog.Info("playlist created successfully", "system", cfg.System, "playlistName", cfg.ClientCfg.PlaylistName, "notify", true)
-		uploadCustomPlaylistArtwork(&cfg, client)
+		uploadCustomPlaylistArtwork(ctx, &cfg, client)
+		for _, t := range tracks {
+			if t.Present {
+				progress.Track(t, progress.StateAdded, "", "")
+			}
+		}
+		rep.MarkPlaylist(tracks, client.PlaylistID())
+		publishToListenBrainz(ctx, &cfg, tracks)
+		rep.Finish(report.StatusSuccess, nil)
+	}
+	exportPlaylist(&cfg, tracks)
+	metrics.Flush()
+}
+
+// prepareTracks enriches the discovered tracks, applies TRACK_RULES and balances the
+// result, in that order so rules and balancing see the enriched metadata. Overflow tracks
+// refilling the balanced playlist go through enrichment and the rules too. Custom playlists
+// are only interleaved, their tracks were picked by the user and there's no overflow to
+// replace any removed by the per artist and per album limits.
+func prepareTracks(ctx context.Context, cfg *config.Config, httpClient *util.HttpClient, tracks, overflow []*models.Track) ([]*models.Track, error) {
+	rules, err := discovery.ParseRules(cfg.TrackRules())
+	if err != nil {
+		return nil, err
+	}
+	prepare := rules.Apply
+	if cfg.DiscoveryCfg.Listenbrainz.EnrichTrackMetadata {
+		progress.Phase(progress.PhaseEnrich)
+		enricher := discovery.NewEnricher(cfg.DiscoveryCfg, httpClient)
+		prepare = func(tracks []*models.Track) []*models.Track {
+			return rules.Apply(enricher.Enrich(ctx, tracks))
+		}
+	}
+	balance := cfg.DiscoveryCfg.Balance
+	if strings.HasPrefix(cfg.Flags.Playlist, "custom-") {
+		balance = config.BalanceConfig{Interleave: balance.Interleave}
+	}
+	size := len(tracks)
+	tracks = prepare(tracks)
+	return discovery.Balance(tracks, size, overflow, prepare, balance), nil
+}
+
+// exitIfCancelled stops the run once a termination signal was received
+func exitIfCancelled(ctx context.Context, rep *report.Report) {
+	if ctx.Err() == nil {
+		return
+	}
+	slog.Warn("run cancelled", "notify", true)
+	rep.Finish(report.StatusCancelled, nil)
+	metrics.Flush()
+	os.Exit(130)
+}
+
+// exitWithError ends a failed run, saving its report
+func exitWithError(rep *report.Report, err error) {
+	slog.Error(err.Error(), "notify", true)
+	rep.Finish(report.StatusFailed, err)
+	metrics.Flush()
+	os.Exit(1)
+}
+
+// runSyncJobs runs the ListenBrainz sync jobs selected by --sync-feedback and --sync-listens
+func runSyncJobs(ctx context.Context, cfg *config.Config) error {
+	c, err := client.NewClient(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("client setup: %w", err)
+	}
+	var failed []string
+	if cfg.Flags.SyncFeedback {
+		if err := lbsync.SyncFeedback(ctx, cfg, c); err != nil {
+			failed = append(failed, "feedback sync failed: "+err.Error())
+		}
+	}
+	if cfg.Flags.SyncListens && ctx.Err() == nil {
+		if err := lbsync.SyncListens(ctx, cfg, c); err != nil {
+			failed = append(failed, "listen sync failed: "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// publishToListenBrainz writes the tracks that made it into the playlist to a playlist on the
+// user's ListenBrainz account. Every run of a playlist replaces the same LB playlist.
+func publishToListenBrainz(ctx context.Context, cfg *config.Config, tracks []*models.Track) {
+	lbCfg := cfg.DiscoveryCfg.Listenbrainz
+	if !lbCfg.Publish {
+		return
+	}
+	if lbCfg.Token == "" {
+		slog.Warn("LISTENBRAINZ_PUBLISH requires LISTENBRAINZ_TOKEN, not publishing playlist", "notify", true)
+		return
+	}
+	var present []*models.Track
+	for _, t := range tracks {
+		if t.Present {
+			present = append(present, t)
+		}
+	}
+
+	statePath := listenbrainz.PublishedPath(cfg.ServerCfg.WebDataDir)
+	published := listenbrainz.LoadPublished(statePath)
+	key := listenbrainz.PublishedKey(lbCfg.User, cfg.Flags.Playlist)
+	info := listenbrainz.PlaylistInfo{
+		Title:      cfg.ClientCfg.PlaylistName,
+		Annotation: cfg.ClientCfg.PlaylistDescr,
+		Public:     lbCfg.PublishPublic,
+	}
+	mbid, err := listenbrainz.NewClient(lbCfg.Token).PublishPlaylist(ctx, published[key], info, present)
+	if mbid != "" && mbid != published[key] {
+		published[key] = mbid
+		if err := published.Save(statePath); err != nil {
+			slog.Warn("failed to save published ListenBrainz playlists", "err", err.Error())
+		}
+	}
+	if err != nil {
+		slog.Warn("failed to publish playlist to ListenBrainz", "err", err.Error(), "notify", true)
+		return
+	}
+	slog.Info("playlist published to ListenBrainz", "url", "https://listenbrainz.org/playlist/"+mbid)
+}
+
+// exportPlaylist writes the playlist in the EXPORT_FORMATS (or --export) formats, and in every
+// format to WEB_DATA_PATH/exports so the web UI can offer it for download
+func exportPlaylist(cfg *config.Config, tracks []*models.Track) {
+	name := cfg.ClientCfg.PlaylistName
+	formats, err := export.ParseFormats(cfg.ExportCfg.Formats)
+	if err != nil {
+		slog.Warn(err.Error())
+	}
+	if len(formats) > 0 {
+		dir := cfg.ExportCfg.Dir
+		if dir == "" {
+			dir = cfg.ClientCfg.PlaylistDir
+		}
+		if dir == "" {
+			dir = cfg.DownloadCfg.DownloadDir
+		}
+		var opts export.Options
+		if cfg.ExportCfg.RelativePaths {
+			opts.RelativeTo = dir
+		}
+		for _, path := range export.WriteFiles(dir, name, name, tracks, formats, opts) {
+			slog.Info("playlist exported", "path", path)
+		}
 	}
+	export.WriteFiles(backend.ExportsDir(cfg.ServerCfg.WebDataDir), backend.PlaylistBase(cfg.Flags.Playlist, cfg.Flags.Profile), name, tracks, export.Formats, export.Options{})
 }
 
 // uploadCustomPlaylistArtwork pushes a custom playlist's cached artwork to the music app
 // after first successful creation. No-op for non-custom playlists, playlists without
 // artwork, or clients that don't support artwork upload (Subsonic, MPD).
-func uploadCustomPlaylistArtwork(cfg *config.Config, c *client.Client) {
+func uploadCustomPlaylistArtwork(ctx context.Context, cfg *config.Config, c *client.Client) {
 	if !strings.HasPrefix(cfg.Flags.Playlist, "custom-") {
 		return
 	}
@@ -253,7 +480,7 @@ func uploadCustomPlaylistArtwork(cfg *config.Config, c *client.Client) {
 		slog.Warn("custom-playlists: artwork not cached locally, skipping upload", "id", cp.ID, "path", path)
 		return
 	}
-	if err := uploader.SetPlaylistArtwork(path); err != nil {
+	if err := uploader.SetPlaylistArtwork(ctx, path); err != nil {
 		slog.Warn("custom-playlists: failed to upload playlist artwork", "id", cp.ID, "err", err.Error())
 		return
 	}
@@ -0,0 +1,54 @@
+package metrics
+
+// Prometheus metrics. The CLI records a run into its own registry and hands it to the web UI
+// at exit (see Flush), which adds it to totals kept on disk so counters keep growing across runs.
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Default histogram buckets, in seconds
+var (
+	requestBuckets  = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	downloadBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+	runBuckets      = []float64{30, 60, 300, 600, 1800, 3600, 7200, 14400}
+)
+
+// Web UI collectors, registered by the web UI itself
+var (
+	Runs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "explo_runs_total", Help: "Finished playlist runs.",
+	}, []string{"playlist", "exit_code"})
+	RunDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "explo_run_duration_seconds", Help: "Playlist run duration.", Buckets: runBuckets,
+	}, []string{"playlist"})
+)
+
+// run holds the metrics of a single CLI run
+var run = prometheus.NewRegistry()
+
+// CLI collectors, handed to the web UI at exit
+var (
+	TracksDiscovered = newCounter("explo_tracks_discovered_total", "Tracks returned by discovery.", "playlist")
+	TracksMatched    = newCounter("explo_tracks_matched_total", "Discovered tracks already in the music library.", "client")
+	TracksDownloaded = newCounter("explo_tracks_downloaded_total", "Tracks downloaded.", "service")
+	TracksFailed     = newCounter("explo_tracks_failed_total", "Tracks no download service could get, by the last service tried.", "service")
+
+	SearchDuration   = newHistogram("explo_download_search_duration_seconds", "Time spent searching a download service for a track.", downloadBuckets, "service")
+	TransferDuration = newHistogram("explo_download_transfer_duration_seconds", "Time from requesting a download until the file was complete.", downloadBuckets, "service")
+
+	APIRequests      = newHistogram("explo_media_server_request_duration_seconds", "Media server API request latency.", requestBuckets, "client")
+	APIRequestErrors = newCounter("explo_media_server_request_errors_total", "Failed media server API requests.", "client")
+)
+
+func newCounter(name, help string, labels ...string) *prometheus.CounterVec {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	run.MustRegister(c)
+	return c
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels)
+	run.MustRegister(h)
+	return h
+}
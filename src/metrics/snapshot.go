@@ -0,0 +1,227 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"explo/src/progress"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+var textfile string
+
+// Init sets the textfile collector path Flush writes to, empty disables it
+func Init(path string) {
+	textfile = path
+}
+
+// Take returns the run's metrics in the Prometheus text format
+func Take() (string, error) {
+	families, err := run.Gather()
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(&b, mf); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// Flush hands the run's metrics over at exit: to the web UI over the progress stream
+// and, if configured, to the textfile collector file
+func Flush() {
+	text, err := Take()
+	if err != nil {
+		slog.Warn("failed to gather run metrics", "err", err.Error())
+		return
+	}
+	progress.Metrics(text)
+	if textfile == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(textfile), 0755); err != nil {
+		slog.Warn("failed to write metrics textfile", "path", textfile, "err", err.Error())
+		return
+	}
+	if err := prometheus.WriteToTextfile(textfile, run); err != nil {
+		slog.Warn("failed to write metrics textfile", "path", textfile, "err", err.Error())
+	}
+}
+
+// ── Totals ──────────────────────────────────────────────────────────────────
+
+// Totals adds up the metrics of every run in a file and collects them on each scrape.
+// It's an unchecked collector: the metrics it reports depend on what runs handed over.
+type Totals struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewTotals(path string) *Totals {
+	return &Totals{path: path}
+}
+
+// Merge adds a run's metrics, as returned by Take, to the totals. Series whose type or
+// buckets don't match the totals are skipped.
+func (t *Totals) Merge(text string) error {
+	handed, err := parse(strings.NewReader(text))
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	totals, err := t.load()
+	if err != nil {
+		return err
+	}
+	for name, mf := range handed {
+		if totals[name] == nil {
+			totals[name] = mf
+		} else if totals[name].GetType() == mf.GetType() {
+			addFamily(totals[name], mf)
+		}
+	}
+
+	var b bytes.Buffer
+	for _, mf := range totals {
+		if _, err := expfmt.MetricFamilyToText(&b, mf); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+func (t *Totals) load() (map[string]*dto.MetricFamily, error) {
+	f, err := os.Open(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]*dto.MetricFamily{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse(f)
+}
+
+// parse reads counters and histograms in the text format, other types are dropped
+func parse(r io.Reader) (map[string]*dto.MetricFamily, error) {
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing metrics: %w", err)
+	}
+	for name, mf := range families {
+		if mf.GetType() != dto.MetricType_COUNTER && mf.GetType() != dto.MetricType_HISTOGRAM {
+			delete(families, name)
+		}
+	}
+	return families, nil
+}
+
+func addFamily(dst, src *dto.MetricFamily) {
+	series := make(map[string]*dto.Metric, len(dst.Metric))
+	for _, m := range dst.Metric {
+		series[labelKey(m)] = m
+	}
+	for _, m := range src.Metric {
+		d := series[labelKey(m)]
+		if d == nil {
+			dst.Metric = append(dst.Metric, m)
+			continue
+		}
+		if c := d.GetCounter(); c != nil {
+			v := c.GetValue() + m.GetCounter().GetValue()
+			c.Value = &v
+		} else if h := d.GetHistogram(); h != nil {
+			addHistogram(h, m.GetHistogram())
+		}
+	}
+}
+
+func addHistogram(dst, src *dto.Histogram) {
+	if len(dst.Bucket) != len(src.Bucket) {
+		return
+	}
+	for i, b := range dst.Bucket {
+		if b.GetUpperBound() != src.Bucket[i].GetUpperBound() {
+			return
+		}
+	}
+	for i, b := range dst.Bucket {
+		n := b.GetCumulativeCount() + src.Bucket[i].GetCumulativeCount()
+		b.CumulativeCount = &n
+	}
+	count, sum := dst.GetSampleCount()+src.GetSampleCount(), dst.GetSampleSum()+src.GetSampleSum()
+	dst.SampleCount, dst.SampleSum = &count, &sum
+}
+
+func labelKey(m *dto.Metric) string {
+	pairs := make([]string, len(m.Label))
+	for i, l := range m.Label {
+		pairs[i] = l.GetName() + "=" + l.GetValue()
+	}
+	return strings.Join(pairs, "\xff")
+}
+
+// Describe sends nothing, which makes Totals an unchecked collector
+func (t *Totals) Describe(chan<- *prometheus.Desc) {}
+
+// Collect reports the totals from the file
+func (t *Totals) Collect(ch chan<- prometheus.Metric) {
+	t.mu.Lock()
+	totals, err := t.load()
+	t.mu.Unlock()
+	if err != nil {
+		slog.Warn("failed to read metrics totals", "path", t.path, "err", err.Error())
+		return
+	}
+	for name, mf := range totals {
+		for _, m := range mf.Metric {
+			names := make([]string, len(m.Label))
+			values := make([]string, len(m.Label))
+			for i, l := range m.Label {
+				names[i], values[i] = l.GetName(), l.GetValue()
+			}
+			desc := prometheus.NewDesc(name, mf.GetHelp(), names, nil)
+
+			var metric prometheus.Metric
+			if h := m.GetHistogram(); h != nil {
+				buckets := make(map[float64]uint64, len(h.Bucket))
+				for _, b := range h.Bucket {
+					if !math.IsInf(b.GetUpperBound(), 1) {
+						buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+					}
+				}
+				metric, err = prometheus.NewConstHistogram(desc, h.GetSampleCount(), h.GetSampleSum(), buckets, values...)
+			} else {
+				metric, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, m.GetCounter().GetValue(), values...)
+			}
+			if err != nil {
+				metric = prometheus.NewInvalidMetric(desc, err)
+			}
+			ch <- metric
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestTotalsMerge(t *testing.T) {
+	first := `# HELP explo_tracks_downloaded_total Tracks downloaded.
+# TYPE explo_tracks_downloaded_total counter
+explo_tracks_downloaded_total{service="youtube"} 3
+# HELP explo_download_search_duration_seconds Search time.
+# TYPE explo_download_search_duration_seconds histogram
+explo_download_search_duration_seconds_bucket{service="youtube",le="1"} 1
+explo_download_search_duration_seconds_bucket{service="youtube",le="5"} 2
+explo_download_search_duration_seconds_bucket{service="youtube",le="+Inf"} 2
+explo_download_search_duration_seconds_sum{service="youtube"} 3.5
+explo_download_search_duration_seconds_count{service="youtube"} 2
+`
+	second := `# TYPE explo_tracks_downloaded_total counter
+explo_tracks_downloaded_total{service="youtube"} 2
+explo_tracks_downloaded_total{service="slskd"} 4
+# TYPE explo_download_search_duration_seconds histogram
+explo_download_search_duration_seconds_bucket{service="youtube",le="1"} 0
+explo_download_search_duration_seconds_bucket{service="youtube",le="5"} 1
+explo_download_search_duration_seconds_bucket{service="youtube",le="+Inf"} 1
+explo_download_search_duration_seconds_sum{service="youtube"} 2
+explo_download_search_duration_seconds_count{service="youtube"} 1
+# TYPE explo_tracks_discovered_total gauge
+explo_tracks_discovered_total 7
+`
+	changedBuckets := `# TYPE explo_download_search_duration_seconds histogram
+explo_download_search_duration_seconds_bucket{service="youtube",le="10"} 1
+explo_download_search_duration_seconds_bucket{service="youtube",le="+Inf"} 1
+explo_download_search_duration_seconds_sum{service="youtube"} 8
+explo_download_search_duration_seconds_count{service="youtube"} 1
+`
+
+	totals := NewTotals(filepath.Join(t.TempDir(), "metrics", "totals.prom"))
+	for _, text := range []string{first, second, changedBuckets} {
+		if err := totals.Merge(text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := totals.Merge("not metrics {"); err == nil {
+		t.Error("expected an error for invalid metrics")
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(totals)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]*dto.MetricFamily{}
+	for _, mf := range families {
+		got[mf.GetName()] = mf
+	}
+	if got["explo_tracks_discovered_total"] != nil {
+		t.Error("gauges shouldn't be added to the totals")
+	}
+
+	downloaded := map[string]float64{}
+	for _, m := range got["explo_tracks_downloaded_total"].GetMetric() {
+		downloaded[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+	}
+	if downloaded["youtube"] != 5 || downloaded["slskd"] != 4 {
+		t.Errorf("downloaded = %v, want youtube 5 and slskd 4", downloaded)
+	}
+
+	search := got["explo_download_search_duration_seconds"].GetMetric()
+	if len(search) != 1 {
+		t.Fatalf("got %d search series, want 1", len(search))
+	}
+	h := search[0].GetHistogram()
+	if h.GetSampleCount() != 3 || h.GetSampleSum() != 5.5 {
+		t.Errorf("count, sum = %d, %v, want 3, 5.5", h.GetSampleCount(), h.GetSampleSum())
+	}
+	if b := h.GetBucket(); len(b) != 2 || b[0].GetCumulativeCount() != 1 || b[1].GetCumulativeCount() != 3 {
+		t.Errorf("buckets = %v, want 1 and 3", b)
+	}
+}
@@ -17,6 +17,7 @@ type Track struct {
 	CleanTitle                string // Title as returned by LB
 	Title                     string // Title as built in listenbrainz.go
 	File                      string // File name
+	Path                      string // Full path of the downloaded file, empty if the track wasn't downloaded
 	Size                      int    // File size
 	Present                   bool   // is track present in the system or not
 	Duration                  int    // Track duration in milliseconds (not available for every track)
@@ -26,6 +27,7 @@ type Track struct {
 	OriginalYear              int
 	Genres                    string
 	ISRCs                     []string
+	VideoID                   string // YouTube video ID from the playlist source, the YouTube downloader skips its search when set
 	Media                     string // Media format (e.g., CD, Digital Media)
 	TrackNumber               int    // Track position in media
 	TrackTotal                int    // Total tracks in media
@@ -37,4 +39,6 @@ type Track struct {
 	MusicBrainzAlbumID        string
 	MusicBrainzReleaseTrackID string
 	MusicBrainzArtistID       string
+	DownloadService           string // Service the track was downloaded with, empty if it wasn't downloaded
+	DownloadError             string // Why the last download attempt failed
 }
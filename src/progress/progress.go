@@ -0,0 +1,156 @@
+package progress
+
+// Machine-readable run progress. The CLI writes one JSON event per line to the file
+// descriptor in EXPLO_PROGRESS_FD (set by the web UI), which forwards them as SSE events.
+// Without it every call is a no-op.
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"explo/src/models"
+)
+
+// EnvFD names the environment variable holding the progress file descriptor
+const EnvFD = "EXPLO_PROGRESS_FD"
+
+// Event types
+const (
+	TypePhase    = "phase"    // run moved to a new phase
+	TypeTrack    = "track"    // a track changed state
+	TypeProgress = "progress" // bytes transferred for a download
+	TypeMetrics  = "metrics"  // the run's metrics, sent once at exit
+)
+
+// Run phases
+const (
+	PhaseDiscovery = "discovery"
+	PhaseEnrich    = "enrich"
+	PhaseLibrary   = "library_check"
+	PhaseDownload  = "download"
+	PhasePlaylist  = "playlist"
+)
+
+// Track states
+const (
+	StateDiscovered = "discovered"
+	StateInLibrary  = "in_library"
+	StateSearching  = "searching"
+	StateQueued     = "queued"
+	StateDownloaded = "downloaded"
+	StateFailed     = "failed"
+	StateAdded      = "added" // added to the playlist
+)
+
+type TrackRef struct {
+	Key    string `json:"key"` // stable for the whole run
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+}
+
+type Event struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Phase   string    `json:"phase,omitempty"`
+	Track   *TrackRef `json:"track,omitempty"`
+	State   string    `json:"state,omitempty"`
+	Service string    `json:"service,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Bytes   int       `json:"bytes,omitempty"`
+	Total   int       `json:"total,omitempty"`
+	Percent float64   `json:"percent,omitempty"`
+
+	Metrics json.RawMessage `json:"metrics,omitempty"`
+}
+
+var (
+	mu  sync.Mutex
+	enc *json.Encoder
+)
+
+// Init opens the progress stream if the parent process passed one
+func Init() {
+	v := os.Getenv(EnvFD)
+	if v == "" {
+		return
+	}
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		slog.Warn("invalid progress fd", "value", v)
+		return
+	}
+	f := os.NewFile(uintptr(fd), "progress")
+	if f == nil {
+		slog.Warn("progress fd is not open", "fd", fd)
+		return
+	}
+	// inherited fds stay open across exec, keep it out of yt-dlp, ffmpeg and hooks so the
+	// web UI sees EOF when this process exits
+	syscall.CloseOnExec(fd)
+	mu.Lock()
+	enc = json.NewEncoder(f)
+	mu.Unlock()
+}
+
+// Key identifies a track in progress events
+func Key(t *models.Track) string {
+	if t.MusicBrainzTrackID != "" {
+		return t.MusicBrainzTrackID
+	}
+	return strings.ToLower(t.CleanTitle + "|" + t.MainArtist)
+}
+
+func emit(e Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	if enc == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+	if err := enc.Encode(e); err != nil {
+		slog.Debug("failed to write progress event", "err", err.Error())
+		enc = nil // reader went away, stop trying
+	}
+}
+
+func ref(t *models.Track) *TrackRef {
+	return &TrackRef{Key: Key(t), Title: t.CleanTitle, Artist: t.Artist}
+}
+
+// Phase reports the run entered a new phase
+func Phase(phase string) {
+	emit(Event{Type: TypePhase, Phase: phase})
+}
+
+// Track reports a track state change. message is optional (e.g. why a download failed).
+func Track(t *models.Track, state, service, message string) {
+	emit(Event{Type: TypeTrack, Track: ref(t), State: state, Service: service, Message: message})
+}
+
+// Tracks reports the same state for several tracks
+func Tracks(tracks []*models.Track, state string) {
+	for _, t := range tracks {
+		Track(t, state, "", "")
+	}
+}
+
+// Bytes reports download progress for a track
+func Bytes(t *models.Track, service string, transferred, total int, percent float64) {
+	emit(Event{Type: TypeProgress, Track: ref(t), Service: service, Bytes: transferred, Total: total, Percent: percent})
+}
+
+// Metrics hands the run's metrics to the web UI
+func Metrics(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Debug("failed to encode run metrics", "err", err.Error())
+		return
+	}
+	emit(Event{Type: TypeMetrics, Metrics: data})
+}
@@ -0,0 +1,326 @@
+package report
+
+// Structured per-run reports, written by the CLI and served by the web UI
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"explo/src/models"
+)
+
+// Run statuses
+const (
+	StatusRunning   = "running"
+	StatusSuccess   = "success"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// Track outcomes
+const (
+	OutcomeInLibrary  = "in_library" // found in the music system before downloading
+	OutcomeDownloaded = "downloaded"
+	OutcomeFailed     = "failed"
+	OutcomeSkipped    = "skipped" // not in library, downloads disabled for the run
+	OutcomePending    = "pending" // run ended before the track was processed
+)
+
+// maxReports is how many reports are kept on disk, older ones are removed on save
+const maxReports = 200
+
+var (
+	validID    = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	unsafeChar = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+type TrackResult struct {
+	Title     string `json:"title"`
+	Artist    string `json:"artist"`
+	Album     string `json:"album,omitempty"`
+	MBID      string `json:"mbid,omitempty"`
+	Outcome   string `json:"outcome"`
+	Service   string `json:"service,omitempty"`    // download service, for downloaded tracks
+	Reason    string `json:"reason,omitempty"`     // why the track failed
+	LibraryID string `json:"library_id,omitempty"` // music system ID the track was matched to
+}
+
+// Summary is a report without its track list, used when listing runs
+type Summary struct {
+	ID           string     `json:"id"`
+	Playlist     string     `json:"playlist"`
+	PlaylistName string     `json:"playlist_name,omitempty"`
+	System       string     `json:"system,omitempty"`
+	DownloadMode string     `json:"download_mode,omitempty"`
+	Profile      string     `json:"profile,omitempty"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	Discovered   int        `json:"discovered"`
+	InLibrary    int        `json:"in_library"`
+	Downloaded   int        `json:"downloaded"`
+	Failed       int        `json:"failed"`
+	PlaylistID   string     `json:"playlist_id,omitempty"`
+}
+
+type Report struct {
+	Summary
+	Tracks []*TrackResult `json:"tracks"`
+
+	mu      sync.Mutex
+	results map[*models.Track]*TrackResult
+	dir     string
+}
+
+// NewID returns a sortable report ID for a run of playlist started at t. The time has
+// nanoseconds so runs started in the same second don't overwrite each other's report.
+func NewID(playlist string, t time.Time) string {
+	name := strings.Trim(unsafeChar.ReplaceAllString(playlist, "-"), "-")
+	return fmt.Sprintf("%s-%s", t.UTC().Format("20060102T150405.000000000Z"), name)
+}
+
+// New starts a report that's saved to dir, an empty dir disables saving
+func New(dir, id, playlist string) *Report {
+	now := time.Now().UTC()
+	if id == "" || !validID.MatchString(id) {
+		id = NewID(playlist, now)
+	}
+	return &Report{
+		Summary: Summary{
+			ID:        id,
+			Playlist:  playlist,
+			Status:    StatusRunning,
+			StartedAt: now,
+		},
+		Tracks:  []*TrackResult{},
+		results: make(map[*models.Track]*TrackResult),
+		dir:     dir,
+	}
+}
+
+// SetTracks records the discovered tracks, in playlist order
+func (r *Report) SetTracks(tracks []*models.Track) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Tracks = make([]*TrackResult, 0, len(tracks))
+	clear(r.results)
+	for _, t := range tracks {
+		res := &TrackResult{
+			Title:   t.CleanTitle,
+			Artist:  t.Artist,
+			Album:   t.Album,
+			MBID:    t.MusicBrainzTrackID,
+			Outcome: OutcomePending,
+		}
+		r.Tracks = append(r.Tracks, res)
+		r.results[t] = res
+	}
+	r.Discovered = len(tracks)
+}
+
+// MarkLibrary records which tracks were found in the music system before downloading
+func (r *Report) MarkLibrary(tracks []*models.Track) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range tracks {
+		if res, ok := r.results[t]; ok && t.Present {
+			res.Outcome = OutcomeInLibrary
+			res.LibraryID = t.ID
+		}
+	}
+}
+
+// MarkDownloads records download results for tracks that weren't in the library.
+// skipped is true when downloads were disabled for the run.
+func (r *Report) MarkDownloads(skipped bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for t, res := range r.results {
+		if res.Outcome != OutcomePending {
+			continue
+		}
+		switch {
+		case skipped:
+			res.Outcome = OutcomeSkipped
+		case t.DownloadService != "":
+			res.Outcome = OutcomeDownloaded
+			res.Service = t.DownloadService
+		default:
+			res.Outcome = OutcomeFailed
+			res.Reason = t.DownloadError
+			if res.Reason == "" {
+				res.Reason = "no download service found the track"
+			}
+		}
+	}
+}
+
+// MarkPlaylist records the music system IDs tracks were matched to and the playlist's ID
+func (r *Report) MarkPlaylist(tracks []*models.Track, playlistID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range tracks {
+		if res, ok := r.results[t]; ok && t.Present && t.ID != "" {
+			res.LibraryID = t.ID
+		}
+	}
+	r.PlaylistID = playlistID
+}
+
+// Finish sets the final status and saves the report
+func (r *Report) Finish(status string, err error) {
+	r.mu.Lock()
+	now := time.Now().UTC()
+	r.FinishedAt = &now
+	r.Status = status
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.InLibrary, r.Downloaded, r.Failed = 0, 0, 0
+	for _, res := range r.Tracks {
+		switch res.Outcome {
+		case OutcomeInLibrary:
+			r.InLibrary++
+		case OutcomeDownloaded:
+			r.Downloaded++
+		case OutcomeFailed:
+			r.Failed++
+		}
+	}
+	r.mu.Unlock()
+
+	if err := r.Save(); err != nil {
+		slog.Warn("failed to save run report", "id", r.ID, "err", err.Error())
+	}
+}
+
+// Save writes the report to <dir>/<id>.json and removes the oldest reports over maxReports
+func (r *Report) Save() error {
+	if r.dir == "" {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(r.dir, r.ID+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(r.dir, r.ID+".json")); err != nil {
+		return err
+	}
+	return prune(r.dir, maxReports)
+}
+
+// Load reads a single report
+func Load(dir, id string) (*Report, error) {
+	if !validID.MatchString(id) {
+		return nil, fmt.Errorf("invalid report id %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", id, err)
+	}
+	return &r, nil
+}
+
+// List returns summaries of all stored reports, newest first
+func List(dir string) ([]Summary, error) {
+	ids, err := reportIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Summary, 0, len(ids))
+	for _, id := range slices.Backward(ids) {
+		r, err := Load(dir, id)
+		if err != nil {
+			continue
+		}
+		out = append(out, r.Summary)
+	}
+	return out, nil
+}
+
+// AddedTrack is a track a run put into a playlist
+type AddedTrack struct {
+	TrackResult
+	Playlist string
+	AddedAt  time.Time // when the run finished
+}
+
+// Added returns the tracks successful runs on system put into playlists, newest run first.
+// Only runs of profile (empty for the main config) count, as profiles can use other accounts.
+func Added(dir, system, profile string) ([]AddedTrack, error) {
+	ids, err := reportIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []AddedTrack
+	for _, id := range slices.Backward(ids) {
+		r, err := Load(dir, id)
+		if err != nil || r.Status != StatusSuccess || r.System != system || r.Profile != profile || r.FinishedAt == nil {
+			continue
+		}
+		for _, t := range r.Tracks {
+			if t.LibraryID != "" {
+				out = append(out, AddedTrack{TrackResult: *t, Playlist: r.Playlist, AddedAt: *r.FinishedAt})
+			}
+		}
+	}
+	return out, nil
+}
+
+// reportIDs returns stored report IDs, oldest first (IDs start with their start time)
+func reportIDs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func prune(dir string, keep int) error {
+	ids, err := reportIDs(dir)
+	if err != nil || len(ids) <= keep {
+		return err
+	}
+	for _, id := range ids[:len(ids)-keep] {
+		if err := os.Remove(filepath.Join(dir, id+".json")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,16 +1,48 @@
-package backend
+package sources
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"regexp"
 	"strings"
 
 	"golang.org/x/net/html"
 )
 
+func init() { Register(appleMusic{}) }
+
+// appleMusic imports public Apple Music playlists by scraping the playlist page
+type appleMusic struct{}
+
+func (appleMusic) Name() string { return "apple_music" }
+
+func (appleMusic) Match(url string) bool { return appleMusicURLRe.MatchString(strings.TrimSpace(url)) }
+
+func (appleMusic) ID(url string) (string, error) { return extractAppleMusicID(url) }
+
+func (appleMusic) Fetch(_ context.Context, url string) (Playlist, error) {
+	name, artwork, tracks, err := fetchAppleMusicPlaylist(url)
+	return Playlist{Name: name, ArtworkURL: artwork, Tracks: tracks}, err
+}
+
+var appleMusicURLRe = regexp.MustCompile(
+	`^https?://music\.apple\.com/[a-z]{2}/playlist/[^/]+/(pl\.[a-zA-Z0-9-]+)`,
+)
+
+// extractAppleMusicID pulls the playlist ID (pl.xxx) from an Apple Music URL.
+func extractAppleMusicID(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	m := appleMusicURLRe.FindStringSubmatch(raw)
+	if len(m) < 2 {
+		return "", fmt.Errorf("not a valid Apple Music playlist URL")
+	}
+	return m[1], nil
+}
+
 // appleServerData mirrors the top-level shape of the
 // <script id="serialized-server-data"> JSON blob on Apple Music pages.
 type appleServerData struct {
@@ -53,7 +85,7 @@ func resolveArtworkURL(tpl string) string {
 // fetchAppleMusicPlaylist scrapes a public Apple Music playlist page and extracts
 // track info from the embedded server data.
 // Returns (playlistName, artworkURL, tracks, error) where tracks are [title, artist, album, coverURL].
-func fetchAppleMusicPlaylist(pageURL string) (string, string, []PlaylistTrack, error) {
+func fetchAppleMusicPlaylist(pageURL string) (string, string, []Track, error) {
 	req, err := http.NewRequest("GET", pageURL, nil)
 	if err != nil {
 		return "", "", nil, fmt.Errorf("apple music: invalid URL: %w", err)
@@ -104,7 +136,7 @@ func fetchAppleMusicPlaylist(pageURL string) (string, string, []PlaylistTrack, e
 
 // extractServerData parses the <script id="serialized-server-data"> blob for
 // the playlist name, playlist artwork URL (from the header section), and tracks with artwork.
-func extractServerData(htmlStr string) (string, string, []PlaylistTrack, error) {
+func extractServerData(htmlStr string) (string, string, []Track, error) {
 	scripts := extractScriptByID(htmlStr, "serialized-server-data")
 	if len(scripts) == 0 {
 		return "", "", nil, fmt.Errorf("apple music: no serialized-server-data found in page")
@@ -117,7 +149,7 @@ func extractServerData(htmlStr string) (string, string, []PlaylistTrack, error)
 
 	var playlistName string
 	var artworkURL string
-	var tracks []PlaylistTrack
+	var tracks []Track
 
 	for _, outer := range ssd.Data {
 		for _, sec := range outer.Data.Sections {
@@ -144,7 +176,7 @@ func extractServerData(htmlStr string) (string, string, []PlaylistTrack, error)
 			}
 
 			// Track section.
-			tracks = make([]PlaylistTrack, 0, len(sec.Items))
+			tracks = make([]Track, 0, len(sec.Items))
 			for _, item := range sec.Items {
 				album := ""
 				if len(item.TertiaryLinks) > 0 {
@@ -154,7 +186,7 @@ func extractServerData(htmlStr string) (string, string, []PlaylistTrack, error)
 				if item.Artwork != nil {
 					coverURL = resolveArtworkURL(item.Artwork.Dictionary.URL)
 				}
-				tracks = append(tracks, PlaylistTrack{
+				tracks = append(tracks, Track{
 					Title:      item.Title,
 					Artist:     item.ArtistName,
 					MainArtist: item.ArtistName,
@@ -0,0 +1,191 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"explo/src/util"
+)
+
+func init() { Register(deezer{}) }
+
+const deezerAPIBase = "https://api.deezer.com"
+
+// deezerLookupBudget bounds the per-track lookups for ISRCs and featured artists, imports run
+// while the web UI waits. Tracks left over are matched by title and artist only.
+const deezerLookupBudget = 20 * time.Second
+
+// deezer imports public Deezer playlists through the public API, no account needed
+type deezer struct{}
+
+func (deezer) Name() string { return "deezer" }
+
+func (deezer) Match(url string) bool {
+	return deezerPlaylistURLRe.MatchString(strings.TrimSpace(url))
+}
+
+func (deezer) ID(url string) (string, error) { return extractDeezerID(url) }
+
+func (deezer) Fetch(ctx context.Context, url string) (Playlist, error) {
+	id, err := extractDeezerID(url)
+	if err != nil {
+		return Playlist{}, err
+	}
+	return fetchDeezerPlaylist(ctx, util.NewHttp(util.HttpClientConfig{Timeout: 30}), id)
+}
+
+var deezerPlaylistURLRe = regexp.MustCompile(
+	`^https?://(?:www\.)?deezer\.com/(?:[a-z]{2}(?:-[a-z]{2})?/)?playlist/(\d+)`,
+)
+
+func extractDeezerID(raw string) (string, error) {
+	m := deezerPlaylistURLRe.FindStringSubmatch(strings.TrimSpace(raw))
+	if len(m) < 2 {
+		return "", fmt.Errorf("not a valid Deezer playlist URL")
+	}
+	return m[1], nil
+}
+
+// deezerError is returned with a 200 status, e.g. for private or deleted playlists
+type deezerError struct {
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+func (e deezerError) err() error {
+	if e.Error == nil {
+		return nil
+	}
+	return fmt.Errorf("deezer: %s (%d)", e.Error.Message, e.Error.Code)
+}
+
+type deezerPlaylist struct {
+	deezerError
+	Title     string `json:"title"`
+	PictureXL string `json:"picture_xl"`
+}
+
+type deezerTrack struct {
+	ID     int64  `json:"id"`
+	Title  string `json:"title"`
+	ISRC   string `json:"isrc"` // only in full track objects, not playlist listings
+	Artist struct {
+		Name string `json:"name"`
+	} `json:"artist"`
+	Album struct {
+		Title       string `json:"title"`
+		CoverMedium string `json:"cover_medium"` // 250x250
+	} `json:"album"`
+	Contributors []struct {
+		Name string `json:"name"`
+	} `json:"contributors"`
+}
+
+type deezerTracksPage struct {
+	deezerError
+	Data  []deezerTrack `json:"data"`
+	Total int           `json:"total"`
+	Next  string        `json:"next"`
+}
+
+func deezerGet[T any](ctx context.Context, httpClient *util.HttpClient, url string, target *T) error {
+	body, err := httpClient.MakeRequest(ctx, http.MethodGet, url, nil, nil)
+	if err != nil {
+		return fmt.Errorf("deezer: request failed: %w", err)
+	}
+	return util.ParseResp(body, target)
+}
+
+// fetchDeezerPlaylist reads the playlist, pages through its tracks and looks up each
+// track's ISRC, which the playlist listing doesn't include.
+func fetchDeezerPlaylist(ctx context.Context, httpClient *util.HttpClient, id string) (Playlist, error) {
+	var pl deezerPlaylist
+	if err := deezerGet(ctx, httpClient, deezerAPIBase+"/playlist/"+id, &pl); err != nil {
+		return Playlist{}, err
+	}
+	if err := pl.err(); err != nil {
+		return Playlist{}, err
+	}
+
+	var listed []deezerTrack
+	next := fmt.Sprintf("%s/playlist/%s/tracks?limit=100", deezerAPIBase, id)
+	for next != "" {
+		var page deezerTracksPage
+		if err := deezerGet(ctx, httpClient, next, &page); err != nil {
+			return Playlist{}, err
+		}
+		if err := page.err(); err != nil {
+			return Playlist{}, err
+		}
+		listed = append(listed, page.Data...)
+		next = page.Next
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, deezerLookupBudget)
+	defer cancel()
+	var skipped int
+
+	tracks := make([]Track, 0, len(listed))
+	for _, t := range listed {
+		if t.Title == "" {
+			continue
+		}
+		track := Track{
+			Title:      t.Title,
+			Artist:     t.Artist.Name,
+			MainArtist: t.Artist.Name,
+			Album:      t.Album.Title,
+			CoverURL:   t.Album.CoverMedium,
+		}
+
+		if lookupCtx.Err() != nil {
+			if ctx.Err() != nil {
+				return Playlist{}, ctx.Err()
+			}
+			skipped++
+			tracks = append(tracks, track)
+			continue
+		}
+
+		// best effort, tracks without an ISRC are still matched by title and artist
+		var full deezerTrack
+		err := deezerGet(lookupCtx, httpClient, fmt.Sprintf("%s/track/%d", deezerAPIBase, t.ID), &full)
+		if ctx.Err() != nil {
+			return Playlist{}, ctx.Err()
+		}
+		if err != nil {
+			slog.Debug("deezer: track lookup failed", "id", t.ID, "err", err.Error())
+		} else {
+			if full.ISRC != "" {
+				track.ISRCs = []string{full.ISRC}
+			}
+			if len(full.Contributors) > 1 {
+				names := make([]string, len(full.Contributors))
+				for i, c := range full.Contributors {
+					names[i] = c.Name
+				}
+				track.Artist = strings.Join(names, ", ")
+			}
+		}
+		tracks = append(tracks, track)
+	}
+
+	if skipped > 0 {
+		slog.Info("deezer: track lookups took too long, remaining tracks are matched without ISRC", "tracks", skipped)
+	}
+
+	name := pl.Title
+	if name == "" {
+		name = "Deezer Playlist"
+	}
+	slog.Info("deezer: parsed playlist", "name", name, "tracks", len(tracks), "artwork", pl.PictureXL != "")
+	return Playlist{Name: name, ArtworkURL: pl.PictureXL, Tracks: tracks}, nil
+}
@@ -0,0 +1,308 @@
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"explo/src/discovery"
+)
+
+// FileSource is stored as the source of playlists imported from an uploaded file. Uploads
+// have nothing to refresh from, so it isn't a registered PlaylistSource.
+const FileSource = "file"
+
+// FileFormats lists the extensions ParseFile understands
+var FileFormats = []string{".m3u", ".m3u8", ".xspf", ".jspf", ".json", ".csv", ".tsv"}
+
+// CSVColumns maps playlist fields to CSV columns, by header name or 1-based column number.
+// Empty fields are guessed from common header names.
+type CSVColumns struct {
+	Title  string
+	Artist string
+	Album  string
+	ISRC   string
+}
+
+// ParseFile reads an uploaded playlist, the format is picked by the file extension.
+// The returned name is empty if the file doesn't have one.
+func ParseFile(filename string, data []byte, cols CSVColumns) (Playlist, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 BOM
+	var (
+		pl  Playlist
+		err error
+	)
+	switch ext := strings.ToLower(path.Ext(filename)); ext {
+	case ".m3u", ".m3u8":
+		pl, err = parseM3U(data)
+	case ".xspf":
+		pl, err = parseXSPF(data)
+	case ".jspf", ".json":
+		pl, err = parseJSPF(data)
+	case ".csv", ".tsv":
+		pl, err = parseCSV(data, cols)
+	default:
+		return Playlist{}, fmt.Errorf("unsupported playlist format %q, use one of %s", ext, strings.Join(FileFormats, ", "))
+	}
+	if err != nil {
+		return Playlist{}, err
+	}
+	if len(pl.Tracks) == 0 {
+		return Playlist{}, fmt.Errorf("no tracks found in %s", filename)
+	}
+	return pl, nil
+}
+
+// ── M3U ──────────────────────────────────────────────────────────────────────
+
+func parseM3U(data []byte) (Playlist, error) {
+	var (
+		pl     Playlist
+		extinf string // display text of the #EXTINF line before the current entry
+	)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			pl.Name = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#EXTINF:"):
+			extinf = extinfTitle(strings.TrimPrefix(line, "#EXTINF:"))
+		case strings.HasPrefix(line, "#"):
+		default:
+			t := trackFromPath(line)
+			if artist, title := splitArtistTitle(extinf); artist != "" && title != "" {
+				t.Artist, t.MainArtist, t.Title = artist, artist, title
+			}
+			if t.Title != "" {
+				pl.Tracks = append(pl.Tracks, t)
+			}
+			extinf = ""
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Playlist{}, fmt.Errorf("failed to read M3U: %w", err)
+	}
+	return pl, nil
+}
+
+// extinfTitle returns the display text of an #EXTINF line: everything after the first
+// comma that isn't inside a quoted attribute, e.g. `123 tvg-id="a,b",Artist - Title`
+func extinfTitle(s string) string {
+	quoted := false
+	for i, r := range s {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				return strings.TrimSpace(s[i+1:])
+			}
+		}
+	}
+	return ""
+}
+
+func splitArtistTitle(s string) (artist, title string) {
+	for _, sep := range []string{" - ", " – ", " — "} {
+		if a, t, ok := strings.Cut(s, sep); ok {
+			return strings.TrimSpace(a), strings.TrimSpace(t)
+		}
+	}
+	return "", strings.TrimSpace(s)
+}
+
+var trackNumberRe = regexp.MustCompile(`^\d{1,3}(?:[\s._-]+|$)`)
+
+// trackFromPath guesses a track from a file path or file:// URL, either "Artist - Title.ext"
+// or the common Artist/Album/01 - Title.ext layout
+func trackFromPath(p string) Track {
+	if rest, ok := strings.CutPrefix(p, "file://"); ok {
+		if unescaped, err := url.PathUnescape(rest); err == nil {
+			p = unescaped
+		}
+	}
+	p = strings.ReplaceAll(p, `\`, "/")
+	base := strings.TrimSuffix(path.Base(p), path.Ext(p))
+	base = trackNumberRe.ReplaceAllString(base, "")
+	artist, title := splitArtistTitle(base)
+	t := Track{Title: title, Artist: artist}
+	if dir := path.Dir(p); t.Artist == "" && dir != "." && dir != "/" {
+		t.Album = path.Base(dir)
+		if grand := path.Base(path.Dir(dir)); grand != "." && grand != "/" && !strings.HasSuffix(grand, ":") {
+			t.Artist = grand
+		}
+	}
+	t.MainArtist = t.Artist
+	return t
+}
+
+// ── XSPF ─────────────────────────────────────────────────────────────────────
+
+type xspfPlaylist struct {
+	Title  string `xml:"title"`
+	Image  string `xml:"image"`
+	Tracks []struct {
+		Location string `xml:"location"`
+		Title    string `xml:"title"`
+		Creator  string `xml:"creator"`
+		Album    string `xml:"album"`
+		Image    string `xml:"image"`
+	} `xml:"trackList>track"`
+}
+
+func parseXSPF(data []byte) (Playlist, error) {
+	var x xspfPlaylist
+	if err := xml.Unmarshal(data, &x); err != nil {
+		return Playlist{}, fmt.Errorf("invalid XSPF: %w", err)
+	}
+	pl := Playlist{Name: strings.TrimSpace(x.Title)}
+	if strings.HasPrefix(x.Image, "http") {
+		pl.ArtworkURL = x.Image
+	}
+	for _, xt := range x.Tracks {
+		t := Track{
+			Title:      strings.TrimSpace(xt.Title),
+			Artist:     strings.TrimSpace(xt.Creator),
+			MainArtist: strings.TrimSpace(xt.Creator),
+			Album:      strings.TrimSpace(xt.Album),
+		}
+		if strings.HasPrefix(xt.Image, "http") {
+			t.CoverURL = xt.Image
+		}
+		if t.Title == "" && xt.Location != "" {
+			t = trackFromPath(xt.Location)
+		}
+		if t.Title != "" {
+			pl.Tracks = append(pl.Tracks, t)
+		}
+	}
+	return pl, nil
+}
+
+// ── JSPF ─────────────────────────────────────────────────────────────────────
+
+func parseJSPF(data []byte) (Playlist, error) {
+	name, tracks, err := discovery.ParseJSPF(data)
+	if err != nil {
+		return Playlist{}, err
+	}
+	return Playlist{Name: name, Tracks: FromModels(tracks)}, nil
+}
+
+// ── CSV ──────────────────────────────────────────────────────────────────────
+
+// csvHeaderGuesses are header names tried for columns that weren't mapped, covering the
+// exports of common tools (Exportify, TuneMyMusic, Soundiiz, ...)
+var csvHeaderGuesses = map[string][]string{
+	"title":  {"title", "track name", "track", "song", "song name", "name"},
+	"artist": {"artist", "artist name(s)", "artist name", "artists", "creator"},
+	"album":  {"album", "album name", "release"},
+	"isrc":   {"isrc"},
+}
+
+func parseCSV(data []byte, cols CSVColumns) (Playlist, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = sniffDelimiter(data)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return Playlist{}, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(rows) < 2 {
+		return Playlist{}, fmt.Errorf("CSV needs a header row and at least one track")
+	}
+	header := rows[0]
+
+	title, err := csvColumn(header, cols.Title, "title")
+	if err != nil {
+		return Playlist{}, err
+	}
+	artist, err := csvColumn(header, cols.Artist, "artist")
+	if err != nil {
+		return Playlist{}, err
+	}
+	if title < 0 || artist < 0 {
+		return Playlist{}, fmt.Errorf("CSV needs title and artist columns, found %s", strings.Join(header, ", "))
+	}
+	album, err := csvColumn(header, cols.Album, "album")
+	if err != nil {
+		return Playlist{}, err
+	}
+	isrc, err := csvColumn(header, cols.ISRC, "isrc")
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	cell := func(row []string, i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	var pl Playlist
+	for _, row := range rows[1:] {
+		t := Track{Title: cell(row, title), Artist: cell(row, artist), Album: cell(row, album)}
+		if t.Title == "" {
+			continue
+		}
+		t.MainArtist = t.Artist
+		if first, _, ok := strings.Cut(t.Artist, ","); ok { // Exportify joins artists with commas
+			t.MainArtist = strings.TrimSpace(first)
+		}
+		if v := cell(row, isrc); v != "" {
+			t.ISRCs = []string{strings.ToUpper(v)}
+		}
+		pl.Tracks = append(pl.Tracks, t)
+	}
+	return pl, nil
+}
+
+// csvColumn returns the index of a mapped column (header name or 1-based number), or of the
+// first header matching the field's guesses. -1 means the column isn't there.
+func csvColumn(header []string, mapped, field string) (int, error) {
+	if mapped != "" {
+		if n, err := strconv.Atoi(mapped); err == nil {
+			if n < 1 || n > len(header) {
+				return -1, fmt.Errorf("%s column %d is out of range, the CSV has %d columns", field, n, len(header))
+			}
+			return n - 1, nil
+		}
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), mapped) {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("%s column %q not found in CSV header", field, mapped)
+	}
+	for _, guess := range csvHeaderGuesses[field] {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), guess) {
+				return i, nil
+			}
+		}
+	}
+	return -1, nil
+}
+
+// sniffDelimiter picks the most common of , ; and tab in the header line
+func sniffDelimiter(data []byte) rune {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	best, bestCount := ',', bytes.Count(line, []byte(","))
+	for _, d := range []rune{';', '\t'} {
+		if n := bytes.Count(line, []byte(string(d))); n > bestCount {
+			best, bestCount = d, n
+		}
+	}
+	return best
+}
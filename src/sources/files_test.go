@@ -0,0 +1,225 @@
+package sources
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseM3U(t *testing.T) {
+	data := strings.Join([]string{
+		"#EXTM3U",
+		"#PLAYLIST:Road Trip",
+		`#EXTINF:215 tvg-id="a,b",Daft Punk - Harder, Better, Faster, Stronger`,
+		"/music/Daft Punk/Discovery/04.mp3", // #EXTINF wins, the album comes from the path
+		"",
+		"# a comment",
+		"/music/Radiohead/OK Computer/02 - Paranoid Android.flac",
+		`C:\Music\Björk - Jóga.mp3`,
+		"file:///music/Portishead%20-%20Roads.mp3",
+		"#EXTINF:-1,no separator here",
+		"/music/Air/Moon Safari/01.flac", // nothing left of the name once the number is gone
+	}, "\n")
+
+	pl, err := parseM3U([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pl.Name != "Road Trip" {
+		t.Errorf("name = %q, want Road Trip", pl.Name)
+	}
+	want := []Track{
+		{Title: "Harder, Better, Faster, Stronger", Artist: "Daft Punk", MainArtist: "Daft Punk", Album: "Discovery"},
+		{Title: "Paranoid Android", Artist: "Radiohead", MainArtist: "Radiohead", Album: "OK Computer"},
+		{Title: "Jóga", Artist: "Björk", MainArtist: "Björk"},
+		{Title: "Roads", Artist: "Portishead", MainArtist: "Portishead"},
+	}
+	if !reflect.DeepEqual(pl.Tracks, want) {
+		t.Errorf("tracks =\n%+v\nwant\n%+v", pl.Tracks, want)
+	}
+}
+
+func TestExtinfTitle(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"123,Artist - Title", "Artist - Title"},
+		{`-1 tvg-id="a,b" tvg-name="x",Artist - Title`, "Artist - Title"},
+		{"123, padded ", "padded"},
+		{"123", ""},
+	}
+	for _, tt := range tests {
+		if got := extinfTitle(tt.in); got != tt.want {
+			t.Errorf("extinfTitle(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseXSPF(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <title>Evening</title>
+  <image>https://example.com/cover.jpg</image>
+  <trackList>
+    <track>
+      <title>Teardrop</title>
+      <creator>Massive Attack</creator>
+      <album>Mezzanine</album>
+      <image>https://example.com/mezzanine.jpg</image>
+    </track>
+    <track>
+      <location>file:///music/Bonobo%20-%20Kerala.flac</location>
+    </track>
+    <track>
+      <creator>No Title</creator>
+      <image>/local/cover.jpg</image>
+    </track>
+  </trackList>
+</playlist>`
+
+	pl, err := parseXSPF([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pl.Name != "Evening" || pl.ArtworkURL != "https://example.com/cover.jpg" {
+		t.Errorf("name, artwork = %q, %q", pl.Name, pl.ArtworkURL)
+	}
+	want := []Track{
+		{Title: "Teardrop", Artist: "Massive Attack", MainArtist: "Massive Attack", Album: "Mezzanine", CoverURL: "https://example.com/mezzanine.jpg"},
+		{Title: "Kerala", Artist: "Bonobo", MainArtist: "Bonobo"},
+	}
+	if !reflect.DeepEqual(pl.Tracks, want) {
+		t.Errorf("tracks =\n%+v\nwant\n%+v", pl.Tracks, want)
+	}
+
+	if _, err := parseXSPF([]byte("<playlist><title>broken")); err == nil {
+		t.Error("expected an error for invalid XML")
+	}
+}
+
+func TestParseJSPF(t *testing.T) {
+	data := `{"playlist": {
+		"title": "Weekly Jams",
+		"track": [
+			{"title": "Roygbiv", "creator": "Boards of Canada", "album": "Music Has the Right to Children",
+			 "identifier": ["https://musicbrainz.org/recording/8f3471b5-7e6a-48da-86a9-c1c07a0f47ae"]}
+		]
+	}}`
+
+	pl, err := parseJSPF([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pl.Name != "Weekly Jams" {
+		t.Errorf("name = %q, want Weekly Jams", pl.Name)
+	}
+	if len(pl.Tracks) != 1 {
+		t.Fatalf("got %d tracks, want 1", len(pl.Tracks))
+	}
+	if got := pl.Tracks[0]; got.Title != "Roygbiv" || got.Artist != "Boards of Canada" {
+		t.Errorf("track = %+v", got)
+	}
+
+	if _, err := parseJSPF([]byte("{not json")); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		cols    CSVColumns
+		want    []Track
+		wantErr string
+	}{
+		{
+			name: "exportify",
+			data: "Track URI,Track Name,Artist Name(s),Album Name,ISRC\n" +
+				`spotify:track:1,Get Lucky,"Daft Punk,Pharrell Williams",Random Access Memories,usqx91300108` + "\n" +
+				"spotify:track:2,,Nobody,Nothing,\n",
+			want: []Track{{Title: "Get Lucky", Artist: "Daft Punk,Pharrell Williams", MainArtist: "Daft Punk", Album: "Random Access Memories", ISRCs: []string{"USQX91300108"}}},
+		},
+		{
+			name: "semicolons",
+			data: "title;artist;album\nAround the World;Daft Punk;Homework\n",
+			want: []Track{{Title: "Around the World", Artist: "Daft Punk", MainArtist: "Daft Punk", Album: "Homework"}},
+		},
+		{
+			name: "tabs with mapped columns",
+			data: "a\tb\tc\nDa Funk\tHomework\tDaft Punk\n",
+			cols: CSVColumns{Title: "1", Artist: "C", Album: "b"},
+			want: []Track{{Title: "Da Funk", Artist: "Daft Punk", MainArtist: "Daft Punk", Album: "Homework"}},
+		},
+		{
+			name: "short row",
+			data: "title,artist,album\nVeridis Quo,Daft Punk\n",
+			want: []Track{{Title: "Veridis Quo", Artist: "Daft Punk", MainArtist: "Daft Punk"}},
+		},
+		{name: "header only", data: "title,artist\n", wantErr: "header row"},
+		{name: "no artist column", data: "title,duration\nOne More Time,320\n", wantErr: "title and artist columns"},
+		{name: "column out of range", data: "title,artist\nA,B\n", cols: CSVColumns{Album: "3"}, wantErr: "out of range"},
+		{name: "unknown column", data: "title,artist\nA,B\n", cols: CSVColumns{ISRC: "code"}, wantErr: "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl, err := parseCSV([]byte(tt.data), tt.cols)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pl.Tracks, tt.want) {
+				t.Errorf("tracks =\n%+v\nwant\n%+v", pl.Tracks, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffDelimiter(t *testing.T) {
+	tests := []struct {
+		data string
+		want rune
+	}{
+		{"title,artist,album\nA;B;C", ','},
+		{"title;artist;album\n", ';'},
+		{"title\tartist\talbum", '\t'},
+		{"Artist Name(s);Track Name,with comma;Album", ';'},
+		{"title", ','},
+		{"", ','},
+	}
+	for _, tt := range tests {
+		if got := sniffDelimiter([]byte(tt.data)); got != tt.want {
+			t.Errorf("sniffDelimiter(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	m3u := []byte("\xef\xbb\xbf#EXTM3U\n/music/Daft Punk - One More Time.mp3\n")
+	for _, name := range []string{"list.m3u", "LIST.M3U8"} {
+		pl, err := ParseFile(name, m3u, CSVColumns{})
+		if err != nil || len(pl.Tracks) != 1 {
+			t.Errorf("ParseFile(%s) = %+v, %v", name, pl, err)
+		}
+	}
+	csv := []byte("title\tartist\nOne More Time\tDaft Punk\n")
+	if pl, err := ParseFile("list.tsv", csv, CSVColumns{}); err != nil || len(pl.Tracks) != 1 {
+		t.Errorf("ParseFile(list.tsv) = %+v, %v", pl, err)
+	}
+
+	if _, err := ParseFile("list.m3u", []byte("#EXTM3U\n"), CSVColumns{}); err == nil || !strings.Contains(err.Error(), "no tracks") {
+		t.Errorf("empty playlist: err = %v", err)
+	}
+	_, err := ParseFile("list.pls", m3u, CSVColumns{})
+	if err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+	for _, ext := range FileFormats {
+		if !strings.Contains(err.Error(), ext) {
+			t.Errorf("error %q doesn't list %s", err, ext)
+		}
+	}
+}
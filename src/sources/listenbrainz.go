@@ -0,0 +1,47 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"explo/src/discovery"
+	"explo/src/util"
+)
+
+func init() { Register(listenBrainz{}) }
+
+var lbMBIDRe = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// listenBrainz imports ListenBrainz playlists by URL or bare MBID
+type listenBrainz struct{}
+
+func (listenBrainz) Name() string { return "listenbrainz" }
+
+func (listenBrainz) Match(url string) bool {
+	return strings.Contains(url, "listenbrainz.org/") || lbMBIDRe.MatchString(url) && !strings.Contains(url, "://")
+}
+
+// ID pulls the playlist UUID out of a ListenBrainz playlist URL or bare MBID string.
+func (listenBrainz) ID(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	m := lbMBIDRe.FindString(raw)
+	if m == "" {
+		return "", fmt.Errorf("no ListenBrainz playlist UUID found in %q", raw)
+	}
+	return m, nil
+}
+
+func (lb listenBrainz) Fetch(ctx context.Context, url string) (Playlist, error) {
+	mbid, err := lb.ID(url)
+	if err != nil {
+		return Playlist{}, err
+	}
+	httpClient := util.NewHttp(util.HttpClientConfig{Timeout: 30})
+	name, tracks, err := discovery.FetchPlaylistByMBID(ctx, httpClient, mbid)
+	if err != nil {
+		return Playlist{}, err
+	}
+	return Playlist{Name: name, Tracks: FromModels(tracks)}, nil
+}
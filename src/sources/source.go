@@ -0,0 +1,126 @@
+// Package sources fetches playlists from outside services (ListenBrainz, Spotify, Apple Music, ...)
+// so they can be imported as custom playlists. Each service is a PlaylistSource registered by
+// name; the web import and the CLI look sources up here instead of switching on the name.
+package sources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"explo/src/models"
+)
+
+// Track is a playlist entry as a source returns it
+type Track struct {
+	Title      string
+	Artist     string
+	MainArtist string
+	Album      string
+	CoverURL   string
+	ISRCs      []string // only set by sources that expose them (Spotify, Deezer, Tidal)
+	VideoID    string   // YouTube video ID, only set by youtube_music
+}
+
+// Playlist is a fetched playlist
+type Playlist struct {
+	Name       string
+	ArtworkURL string // playlist cover, empty if the source has none
+	Tracks     []Track
+}
+
+// PlaylistSource is a service playlists can be imported from
+type PlaylistSource interface {
+	// Name is the key stored with imported playlists, e.g. "spotify"
+	Name() string
+	// Match reports whether url looks like one of the source's playlists
+	Match(url string) bool
+	// ID validates url and returns the playlist's canonical ID, used to spot duplicates
+	ID(url string) (string, error)
+	// Fetch returns the playlist's name, artwork and tracks
+	Fetch(ctx context.Context, url string) (Playlist, error)
+}
+
+// DefaultSource is used for playlists saved without a source (older imports were ListenBrainz only)
+const DefaultSource = "listenbrainz"
+
+var registry []PlaylistSource
+
+// Register adds a source, later registrations replace sources of the same name
+func Register(src PlaylistSource) {
+	for i, s := range registry {
+		if s.Name() == src.Name() {
+			registry[i] = src
+			return
+		}
+	}
+	registry = append(registry, src)
+}
+
+// Get returns the source called name, "" means DefaultSource
+func Get(name string) (PlaylistSource, error) {
+	if name == "" {
+		name = DefaultSource
+	}
+	for _, s := range registry {
+		if s.Name() == name {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown playlist source %q", name)
+}
+
+// Detect returns the first source that recognises url
+func Detect(url string) (PlaylistSource, bool) {
+	url = strings.TrimSpace(url)
+	for _, s := range registry {
+		if s.Match(url) {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Names lists the registered sources in registration order
+func Names() []string {
+	out := make([]string, len(registry))
+	for i, s := range registry {
+		out[i] = s.Name()
+	}
+	return out
+}
+
+// FromModels converts discovery tracks to source tracks
+func FromModels(tracks []*models.Track) []Track {
+	out := make([]Track, len(tracks))
+	for i, t := range tracks {
+		out[i] = Track{
+			Title:      t.CleanTitle,
+			Artist:     t.Artist,
+			MainArtist: t.MainArtist,
+			Album:      t.Album,
+			CoverURL:   t.CoverURL,
+			ISRCs:      t.ISRCs,
+			VideoID:    t.VideoID,
+		}
+	}
+	return out
+}
+
+// Model converts a source track to the track the downloaders and clients work with
+func (t Track) Model() *models.Track {
+	mainArtist := t.MainArtist
+	if mainArtist == "" {
+		mainArtist = t.Artist
+	}
+	return &models.Track{
+		CleanTitle: t.Title,
+		Title:      t.Title,
+		Artist:     t.Artist,
+		MainArtist: mainArtist,
+		Album:      t.Album,
+		CoverURL:   t.CoverURL,
+		ISRCs:      t.ISRCs,
+		VideoID:    t.VideoID,
+	}
+}
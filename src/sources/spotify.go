@@ -1,7 +1,8 @@
-package backend
+package sources
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha1"
 	"encoding/base32"
@@ -21,6 +22,24 @@ import (
 	"time"
 )
 
+func init() { Register(spotify{}) }
+
+// spotify imports public Spotify playlists through the web player's partner API
+type spotify struct{}
+
+func (spotify) Name() string { return "spotify" }
+
+func (spotify) Match(url string) bool {
+	return spotifyPlaylistURLRe.MatchString(strings.TrimSpace(url))
+}
+
+func (spotify) ID(url string) (string, error) { return extractSpotifyID(url) }
+
+func (spotify) Fetch(_ context.Context, url string) (Playlist, error) {
+	name, artwork, tracks, err := fetchSpotifyPlaylist(url)
+	return Playlist{Name: name, ArtworkURL: artwork, Tracks: tracks}, err
+}
+
 // ── URL parsing ──────────────────────────────────────────────────────────────
 
 var spotifyPlaylistURLRe = regexp.MustCompile(
@@ -554,6 +573,12 @@ type partnerTrackData struct {
 			Sources []spotifyImageSource `json:"sources"`
 		} `json:"coverArt"`
 	} `json:"albumOfTrack"`
+	ExternalIDs struct {
+		Items []struct {
+			Type string `json:"type"`
+			ID   string `json:"id"`
+		} `json:"items"`
+	} `json:"externalIds"`
 }
 
 // ── Playlist fetching ───────────────────────────────────────────────────────
@@ -561,7 +586,7 @@ type partnerTrackData struct {
 // fetchSpotifyPlaylist fetches a public Spotify playlist via the internal
 // partner API (api-partner.spotify.com). Retries once with a fresh session
 // on failure. Returns playlist name, artwork URL, and normalized tracks.
-func fetchSpotifyPlaylist(playlistURL string) (string, string, []PlaylistTrack, error) {
+func fetchSpotifyPlaylist(playlistURL string) (string, string, []Track, error) {
 	id, err := extractSpotifyID(playlistURL)
 	if err != nil {
 		return "", "", nil, err
@@ -584,7 +609,7 @@ func fetchSpotifyPlaylist(playlistURL string) (string, string, []PlaylistTrack,
 	return name, artwork, tracks, nil
 }
 
-func fetchPlaylistByID(id string) (string, string, []PlaylistTrack, error) {
+func fetchPlaylistByID(id string) (string, string, []Track, error) {
 	if err := spSession.ensure(); err != nil {
 		return "", "", nil, err
 	}
@@ -674,8 +699,8 @@ func queryPartnerAPI(playlistID string, offset, limit int) (*partnerPlaylistResp
 
 // ── Track extraction helpers ────────────────────────────────────────────────
 
-func extractTracks(items []partnerItem) []PlaylistTrack {
-	tracks := make([]PlaylistTrack, 0, len(items))
+func extractTracks(items []partnerItem) []Track {
+	tracks := make([]Track, 0, len(items))
 	for _, item := range items {
 		t := item.ItemV2.Data
 		if t.Name == "" {
@@ -700,12 +725,20 @@ func extractTracks(items []partnerItem) []PlaylistTrack {
 			coverURL = pickBestSource(t.AlbumOfTrack.CoverArt.Sources, 300)
 		}
 
-		tracks = append(tracks, PlaylistTrack{
+		var isrcs []string
+		for _, ext := range t.ExternalIDs.Items {
+			if strings.EqualFold(ext.Type, "isrc") && ext.ID != "" {
+				isrcs = append(isrcs, ext.ID)
+			}
+		}
+
+		tracks = append(tracks, Track{
 			Title:      t.Name,
 			Artist:     fullArtist,
 			MainArtist: mainArtist,
 			Album:      t.AlbumOfTrack.Name,
 			CoverURL:   coverURL,
+			ISRCs:      isrcs,
 		})
 	}
 	return tracks
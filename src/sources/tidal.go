@@ -0,0 +1,158 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"explo/src/util"
+)
+
+func init() { Register(tidal{}) }
+
+const (
+	tidalAPIBase = "https://api.tidal.com/v1"
+	// tidalWebToken is the client token the Tidal web player sends for anonymous requests
+	tidalWebToken = "CzET4vdadNUFQ5JU"
+	// tidalCountry only affects availability info, playlist contents are the same everywhere
+	tidalCountry  = "US"
+	tidalPageSize = 100
+)
+
+// tidal imports public Tidal playlists through the endpoints the web player uses
+type tidal struct{}
+
+func (tidal) Name() string { return "tidal" }
+
+func (tidal) Match(url string) bool {
+	return tidalPlaylistURLRe.MatchString(strings.TrimSpace(url))
+}
+
+func (tidal) ID(url string) (string, error) { return extractTidalID(url) }
+
+func (tidal) Fetch(ctx context.Context, url string) (Playlist, error) {
+	id, err := extractTidalID(url)
+	if err != nil {
+		return Playlist{}, err
+	}
+	return fetchTidalPlaylist(ctx, util.NewHttp(util.HttpClientConfig{Timeout: 30}), id)
+}
+
+var tidalPlaylistURLRe = regexp.MustCompile(
+	`^https?://(?:www\.|listen\.)?tidal\.com/(?:browse/)?playlist/([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})`,
+)
+
+func extractTidalID(raw string) (string, error) {
+	m := tidalPlaylistURLRe.FindStringSubmatch(strings.TrimSpace(raw))
+	if len(m) < 2 {
+		return "", fmt.Errorf("not a valid Tidal playlist URL")
+	}
+	return m[1], nil
+}
+
+// tidalImageURL builds a resources.tidal.com URL from an image UUID
+func tidalImageURL(id string, size int) string {
+	if id == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://resources.tidal.com/images/%s/%dx%d.jpg", strings.ReplaceAll(id, "-", "/"), size, size)
+}
+
+type tidalPlaylist struct {
+	Title       string `json:"title"`
+	SquareImage string `json:"squareImage"`
+}
+
+type tidalItemsPage struct {
+	TotalNumberOfItems int `json:"totalNumberOfItems"`
+	Items              []struct {
+		Type string `json:"type"` // "track" or "video"
+		Item struct {
+			Title   string `json:"title"`
+			Version string `json:"version"` // e.g. "Remastered", shown in brackets after the title
+			ISRC    string `json:"isrc"`
+			Artists []struct {
+				Name string `json:"name"`
+				Type string `json:"type"` // "MAIN" or "FEATURED"
+			} `json:"artists"`
+			Album struct {
+				Title string `json:"title"`
+				Cover string `json:"cover"`
+			} `json:"album"`
+		} `json:"item"`
+	} `json:"items"`
+}
+
+func tidalGet[T any](ctx context.Context, httpClient *util.HttpClient, url string, target *T) error {
+	body, err := httpClient.MakeRequest(ctx, http.MethodGet, url, nil, map[string]string{
+		"x-tidal-token": tidalWebToken,
+	})
+	if err != nil {
+		return fmt.Errorf("tidal: request failed: %w", err)
+	}
+	return util.ParseResp(body, target)
+}
+
+// fetchTidalPlaylist reads the playlist and pages through its tracks, videos are skipped.
+func fetchTidalPlaylist(ctx context.Context, httpClient *util.HttpClient, id string) (Playlist, error) {
+	var pl tidalPlaylist
+	if err := tidalGet(ctx, httpClient, fmt.Sprintf("%s/playlists/%s?countryCode=%s", tidalAPIBase, id, tidalCountry), &pl); err != nil {
+		return Playlist{}, err
+	}
+
+	var tracks []Track
+	for offset := 0; ; offset += tidalPageSize {
+		var page tidalItemsPage
+		url := fmt.Sprintf("%s/playlists/%s/items?countryCode=%s&limit=%d&offset=%d", tidalAPIBase, id, tidalCountry, tidalPageSize, offset)
+		if err := tidalGet(ctx, httpClient, url, &page); err != nil {
+			return Playlist{}, err
+		}
+		for _, it := range page.Items {
+			t := it.Item
+			if it.Type != "track" || t.Title == "" {
+				continue
+			}
+			title := t.Title
+			if t.Version != "" {
+				title += " (" + t.Version + ")"
+			}
+			var artists []string
+			mainArtist := ""
+			for _, a := range t.Artists {
+				artists = append(artists, a.Name)
+				if mainArtist == "" && a.Type == "MAIN" {
+					mainArtist = a.Name
+				}
+			}
+			if mainArtist == "" && len(artists) > 0 {
+				mainArtist = artists[0]
+			}
+			var isrcs []string
+			if t.ISRC != "" {
+				isrcs = []string{t.ISRC}
+			}
+			tracks = append(tracks, Track{
+				Title:      title,
+				Artist:     strings.Join(artists, ", "),
+				MainArtist: mainArtist,
+				Album:      t.Album.Title,
+				CoverURL:   tidalImageURL(t.Album.Cover, 320),
+				ISRCs:      isrcs,
+			})
+		}
+		if len(page.Items) == 0 || offset+tidalPageSize >= page.TotalNumberOfItems {
+			break
+		}
+	}
+
+	name := pl.Title
+	if name == "" {
+		name = "Tidal Playlist"
+	}
+	artwork := tidalImageURL(pl.SquareImage, 640)
+	slog.Info("tidal: parsed playlist", "name", name, "tracks", len(tracks), "artwork", artwork != "")
+	return Playlist{Name: name, ArtworkURL: artwork, Tracks: tracks}, nil
+}
@@ -0,0 +1,159 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/wader/goutubedl"
+)
+
+func init() { Register(youtubeMusic{}) }
+
+// youtubeMusic imports YouTube Music and YouTube playlists with yt-dlp. Tracks keep
+// their video IDs, so the YouTube downloader can skip its search.
+type youtubeMusic struct{}
+
+func (youtubeMusic) Name() string { return "youtube_music" }
+
+func (youtubeMusic) Match(raw string) bool {
+	raw = strings.TrimSpace(raw)
+	if ytPlaylistIDRe.MatchString(raw) {
+		return true
+	}
+	u, err := url.Parse(raw)
+	return err == nil && ytHosts[strings.ToLower(u.Hostname())] && u.Query().Get("list") != ""
+}
+
+func (youtubeMusic) ID(raw string) (string, error) { return extractYoutubePlaylistID(raw) }
+
+func (youtubeMusic) Fetch(ctx context.Context, raw string) (Playlist, error) {
+	id, err := extractYoutubePlaylistID(raw)
+	if err != nil {
+		return Playlist{}, err
+	}
+	return fetchYoutubePlaylist(ctx, id)
+}
+
+// ── URL parsing ──────────────────────────────────────────────────────────────
+
+var ytHosts = map[string]bool{
+	"youtube.com":       true,
+	"www.youtube.com":   true,
+	"m.youtube.com":     true,
+	"music.youtube.com": true,
+	"youtu.be":          true,
+}
+
+// ytPlaylistIDRe matches bare playlist IDs: user playlists (PL), albums (OLAK5uy_),
+// YouTube Music mixes (RDCLAK5uy_) and the browse form with a VL prefix
+var ytPlaylistIDRe = regexp.MustCompile(`^(?:VL)?((?:PL|OLAK5uy_|RDCLAK5uy_)[A-Za-z0-9_-]{10,})$`)
+
+// extractYoutubePlaylistID returns the list= parameter of a playlist or watch URL, or a bare ID.
+func extractYoutubePlaylistID(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if m := ytPlaylistIDRe.FindStringSubmatch(raw); m != nil {
+		return m[1], nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !ytHosts[strings.ToLower(u.Hostname())] {
+		return "", fmt.Errorf("not a valid YouTube playlist URL")
+	}
+	id := strings.TrimPrefix(u.Query().Get("list"), "VL")
+	if id == "" {
+		return "", fmt.Errorf("YouTube URL has no playlist (list=) parameter")
+	}
+	return id, nil
+}
+
+// ── Fetching ─────────────────────────────────────────────────────────────────
+
+// ConfigureYtdlp sets the yt-dlp binary used to read playlists (YTDLP_PATH). Imports run before
+// any downloader exists, so this is set at startup for both the web UI and the CLI.
+func ConfigureYtdlp(path string) {
+	if path != "" {
+		goutubedl.Path = path
+	}
+}
+
+func fetchYoutubePlaylist(ctx context.Context, id string) (Playlist, error) {
+	result, err := goutubedl.New(ctx, "https://www.youtube.com/playlist?list="+id, goutubedl.Options{
+		Type:         goutubedl.TypePlaylist,
+		FlatPlaylist: true,
+	})
+	if err != nil {
+		return Playlist{}, fmt.Errorf("youtube: failed to read playlist: %w", err)
+	}
+
+	tracks := make([]Track, 0, len(result.Info.Entries))
+	for _, e := range result.Info.Entries {
+		if e.ID == "" || e.Title == "" || e.Title == "[Private video]" || e.Title == "[Deleted video]" {
+			continue
+		}
+		channel := e.Channel
+		if channel == "" {
+			channel = e.Uploader
+		}
+		title, artist := cleanVideoTitle(e.Title, channel)
+		tracks = append(tracks, Track{
+			Title:      title,
+			Artist:     artist,
+			MainArtist: mainArtistOf(artist),
+			CoverURL:   fmt.Sprintf("https://i.ytimg.com/vi/%s/hqdefault.jpg", e.ID),
+			VideoID:    e.ID,
+		})
+	}
+
+	name := result.Info.Title
+	if name == "" {
+		name = "YouTube Playlist"
+	}
+	var artwork string
+	if n := len(result.Info.Thumbnails); n > 0 {
+		artwork = result.Info.Thumbnails[n-1].URL // yt-dlp sorts thumbnails smallest first
+	}
+	slog.Info("youtube: parsed playlist", "name", name, "tracks", len(tracks), "artwork", artwork != "")
+	return Playlist{Name: name, ArtworkURL: artwork, Tracks: tracks}, nil
+}
+
+// ── Title cleaning ───────────────────────────────────────────────────────────
+
+var (
+	// ytNoiseRe matches bracketed video decorations like "(Official Music Video)" or "[Lyrics]"
+	ytNoiseRe = regexp.MustCompile(`(?i)\s*[(\[][^)\]]*\b(?:official|video|audio|lyrics?|visuali[sz]er|hd|hq|4k|mv|m/v|explicit|clip officiel)\b[^)\]]*[)\]]`)
+	// ytSuffixRe matches trailing decorations after a pipe, e.g. "Song | Official Video"
+	ytSuffixRe = regexp.MustCompile(`\s+(?:\||//)\s+.*$`)
+	// ytChannelRe matches channel name suffixes that aren't part of the artist name
+	ytChannelRe = regexp.MustCompile(`(?i)(?:\s+-\s+topic|vevo|\s+official)$`)
+	// ytFeatRe splits featured artists off the main artist. "&", "x" and "," are left alone,
+	// they're part of names like "Simon & Garfunkel" as often as they join two artists.
+	ytFeatRe = regexp.MustCompile(`(?i)\s+\(?(?:feat\.?|ft\.?|featuring)\s+`)
+)
+
+// cleanVideoTitle turns a video title like "Artist - Song (Official Video)" into title and
+// artist. Titles without an "Artist - " prefix use the channel name as artist, which is what
+// YouTube Music's auto-generated "Artist - Topic" channels need.
+func cleanVideoTitle(videoTitle, channel string) (title, artist string) {
+	title = ytSuffixRe.ReplaceAllString(ytNoiseRe.ReplaceAllString(videoTitle, ""), "")
+	for _, sep := range []string{" - ", " – ", " — "} {
+		if a, t, ok := strings.Cut(title, sep); ok {
+			artist, title = a, t
+			break
+		}
+	}
+	if artist == "" {
+		artist = ytChannelRe.ReplaceAllString(strings.TrimSpace(channel), "")
+	}
+	return strings.Trim(strings.TrimSpace(title), `"`), strings.TrimSpace(artist)
+}
+
+// mainArtistOf drops featured artists, anything else is kept as the channel or title named it
+func mainArtistOf(artist string) string {
+	if parts := ytFeatRe.Split(artist, 2); parts[0] != "" {
+		return parts[0]
+	}
+	return artist
+}
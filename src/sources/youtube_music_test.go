@@ -0,0 +1,49 @@
+package sources
+
+import "testing"
+
+func TestCleanVideoTitle(t *testing.T) {
+	tests := []struct {
+		videoTitle, channel string
+		title, artist       string
+	}{
+		{"Daft Punk - Get Lucky (Official Video)", "DaftPunkVEVO", "Get Lucky", "Daft Punk"},
+		{"Radiohead - Creep [Lyrics]", "Lyric Channel", "Creep", "Radiohead"},
+		{"Massive Attack – Teardrop (Official Audio) [HD]", "Massive Attack", "Teardrop", "Massive Attack"},
+		{"Portishead — Roads | Live at Roseland", "PortisheadVEVO", "Roads", "Portishead"},
+		{"Björk - Jóga (Remastered)", "björk", "Jóga (Remastered)", "Björk"}, // not a video decoration
+		{"Teardrop", "Massive Attack - Topic", "Teardrop", "Massive Attack"},
+		{`"Heroes"`, "David Bowie - Topic", "Heroes", "David Bowie"},
+		{"Paranoid Android", "RadioheadVEVO", "Paranoid Android", "Radiohead"},
+		{"Song (Official Music Video)", "Some Band Official", "Song", "Some Band"},
+		{"A - B - C", "", "B - C", "A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.videoTitle, func(t *testing.T) {
+			title, artist := cleanVideoTitle(tt.videoTitle, tt.channel)
+			if title != tt.title || artist != tt.artist {
+				t.Errorf("cleanVideoTitle(%q, %q) = %q, %q, want %q, %q", tt.videoTitle, tt.channel, title, artist, tt.title, tt.artist)
+			}
+		})
+	}
+}
+
+func TestMainArtistOf(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Daft Punk", "Daft Punk"},
+		{"Daft Punk feat. Pharrell Williams", "Daft Punk"},
+		{"Daft Punk ft Pharrell Williams", "Daft Punk"},
+		{"Eminem FEATURING Dido", "Eminem"},
+		{"Eminem (feat. Dido)", "Eminem"},
+		{"Simon & Garfunkel", "Simon & Garfunkel"},
+		{"Crosby, Stills, Nash & Young", "Crosby, Stills, Nash & Young"},
+		{"Kaytranada x Kali Uchis", "Kaytranada x Kali Uchis"},
+		{"Featurecast", "Featurecast"},
+		{"feat. Nobody", "feat. Nobody"},
+	}
+	for _, tt := range tests {
+		if got := mainArtistOf(tt.in); got != tt.want {
+			t.Errorf("mainArtistOf(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// cacheRule caches GET responses for URLs on host whose path starts with prefix
+type cacheRule struct {
+	host, prefix string
+	ttl          time.Duration
+}
+
+// Metadata endpoints whose GET responses are cached and for how long, everything else is
+// never cached. ListenBrainz playlists and recommendations aren't listed on purpose: they're
+// regenerated, and runs and refreshes must see the new ones right away.
+var cacheRules = []cacheRule{
+	{"musicbrainz.org", "/ws/2/", 7 * 24 * time.Hour},            // recording metadata rarely changes
+	{"api.listenbrainz.org", "/1/metadata/", 7 * 24 * time.Hour}, // same, served by ListenBrainz
+}
+
+// ResponseCache stores API responses on disk, one directory per host, keyed by URL and the
+// request's credentials, so a response fetched with one token is never served for another
+type ResponseCache struct {
+	Dir      string
+	MaxBytes int64
+}
+
+var defaultCache *ResponseCache
+
+// ConfigureCache enables the response cache for clients created afterwards, maxBytes <= 0 disables it
+func ConfigureCache(dir string, maxBytes int64) {
+	if dir == "" || maxBytes <= 0 {
+		defaultCache = nil
+		return
+	}
+	defaultCache = &ResponseCache{Dir: dir, MaxBytes: maxBytes}
+}
+
+// DefaultCache returns the cache set by ConfigureCache, or nil if caching is disabled
+func DefaultCache() *ResponseCache {
+	return defaultCache
+}
+
+// SetCacheTTL sets how long responses for URLs on host under pathPrefix are kept, 0 disables
+// caching them
+func SetCacheTTL(host, pathPrefix string, ttl time.Duration) {
+	host = strings.ToLower(host)
+	for i, r := range cacheRules {
+		if r.host == host && r.prefix == pathPrefix {
+			cacheRules[i].ttl = ttl
+			return
+		}
+	}
+	cacheRules = append(cacheRules, cacheRule{host, pathPrefix, ttl})
+}
+
+// cacheTTL returns how long the response for u is kept, 0 if it isn't cached
+func cacheTTL(u *url.URL) time.Duration {
+	host := strings.ToLower(u.Hostname())
+	for _, r := range cacheRules {
+		if r.host == host && strings.HasPrefix(u.Path, r.prefix) {
+			return r.ttl
+		}
+	}
+	return 0
+}
+
+// hostTTL returns the longest a response from host is kept, for pruning its cache directory
+func hostTTL(host string) time.Duration {
+	var ttl time.Duration
+	for _, r := range cacheRules {
+		if r.host == host {
+			ttl = max(ttl, r.ttl)
+		}
+	}
+	return ttl
+}
+
+// authHeader returns the request's Authorization header, whatever case it's set in
+func authHeader(headers map[string]string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, "Authorization") {
+			return v
+		}
+	}
+	return ""
+}
+
+func (c *ResponseCache) entryPath(rawURL string, headers map[string]string) (string, time.Duration, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", 0, false
+	}
+	host := strings.ToLower(u.Hostname())
+	ttl := cacheTTL(u)
+	if ttl <= 0 {
+		return "", 0, false
+	}
+	key := rawURL
+	if auth := authHeader(headers); auth != "" {
+		key += "\x00" + auth
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, host, hex.EncodeToString(sum[:])), ttl, true
+}
+
+// Get returns a cached response for rawURL requested with headers if one exists and hasn't
+// expired
+func (c *ResponseCache) Get(rawURL string, headers map[string]string) ([]byte, bool) {
+	path, ttl, ok := c.entryPath(rawURL, headers)
+	if !ok {
+		return nil, false
+	}
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > ttl {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Put stores a response for rawURL requested with headers, no-op for hosts that aren't cached
+func (c *ResponseCache) Put(rawURL string, headers map[string]string, body []byte) {
+	path, _, ok := c.entryPath(rawURL, headers)
+	if !ok {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		slog.Debug("failed to create cache directory", "context", err.Error())
+		return
+	}
+	// write to a temp file first, the CLI and web UI may access the cache at the same time
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, body, 0644); err != nil {
+		slog.Debug("failed to write cache entry", "context", err.Error())
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		slog.Debug("failed to save cache entry", "context", err.Error())
+		_ = os.Remove(tmp)
+	}
+}
+
+// Prune removes expired entries, then the oldest ones until the cache fits in MaxBytes
+func (c *ResponseCache) Prune() {
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []entry
+	var total int64
+	var expired int
+
+	hosts, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return
+	}
+	for _, h := range hosts {
+		if !h.IsDir() {
+			continue
+		}
+		ttl := hostTTL(h.Name())
+		files, err := os.ReadDir(filepath.Join(c.Dir, h.Name()))
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			info, err := f.Info()
+			if err != nil || info.IsDir() {
+				continue
+			}
+			path := filepath.Join(c.Dir, h.Name(), f.Name())
+			if ttl <= 0 || time.Since(info.ModTime()) > ttl {
+				if err := os.Remove(path); err == nil {
+					expired++
+				}
+				continue
+			}
+			entries = append(entries, entry{path: path, size: info.Size(), modTime: info.ModTime()})
+			total += info.Size()
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	var trimmed int
+	for _, e := range entries {
+		if total <= c.MaxBytes {
+			break
+		}
+		if err := os.Remove(e.path); err == nil {
+			total -= e.size
+			trimmed++
+		}
+	}
+	slog.Debug("http cache pruned", "expired", expired, "trimmed", trimmed, "size_bytes", total)
+}
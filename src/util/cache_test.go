@@ -0,0 +1,52 @@
+package util
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCacheTTL(t *testing.T) {
+	week := 7 * 24 * time.Hour
+	tests := []struct {
+		url  string
+		want time.Duration
+	}{
+		{"https://musicbrainz.org/ws/2/recording/abc?fmt=json", week},
+		{"https://MusicBrainz.org/ws/2/recording/abc", week},
+		{"https://api.listenbrainz.org/1/metadata/recording/?recording_mbids=abc", week},
+		{"https://api.listenbrainz.org/1/user/alice/playlists/createdfor?offset=0", 0},
+		{"https://api.listenbrainz.org/1/playlist/abc", 0},
+		{"https://api.listenbrainz.org/1/cf/recommendation/user/alice/recording?count=100", 0},
+		{"https://api.deezer.com/track/1", 0},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cacheTTL(u); got != tt.want {
+			t.Errorf("cacheTTL(%s) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestCacheKeyedByCredentials(t *testing.T) {
+	c := &ResponseCache{Dir: t.TempDir(), MaxBytes: 1 << 20}
+	const u = "https://musicbrainz.org/ws/2/recording/abc"
+	alice := map[string]string{"Authorization": "Token alice"}
+
+	c.Put(u, alice, []byte("alice"))
+	if got, ok := c.Get(u, map[string]string{"authorization": "Token alice"}); !ok || string(got) != "alice" {
+		t.Errorf("Get with the same token = %q, %v", got, ok)
+	}
+	if _, ok := c.Get(u, map[string]string{"Authorization": "Token bob"}); ok {
+		t.Error("response cached for one token was served for another")
+	}
+	if _, ok := c.Get(u, nil); ok {
+		t.Error("response cached for a token was served without one")
+	}
+	if _, ok := c.Get("https://api.listenbrainz.org/1/playlist/abc", nil); ok {
+		t.Error("uncached endpoint returned a response")
+	}
+}
@@ -0,0 +1,20 @@
+package util
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep pauses for d or until ctx is cancelled, whichever comes first.
+// Returns ctx.Err() if the wait was cut short.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -1,7 +1,10 @@
 package util
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -16,24 +19,92 @@ import (
 
 type HttpClientConfig struct {
 	Timeout int
+	Retry   *RetryPolicy // nil uses DefaultRetryPolicy
 }
 
 type HttpClient struct {
 	Client    *http.Client
 	UserAgent string
+	Retry     RetryPolicy
+	Cache     *ResponseCache                   // GET responses from cacheable hosts, nil disables caching
+	Observe   func(d time.Duration, err error) // called after every request attempt, for metrics
 }
 
 func NewHttp(cfg HttpClientConfig) *HttpClient {
+	retry := DefaultRetryPolicy
+	if cfg.Retry != nil {
+		retry = *cfg.Retry
+	}
 	return &HttpClient{
 		Client: &http.Client{
 			Timeout: time.Duration(cfg.Timeout) * time.Second,
 		},
 		UserAgent: "Explo (+https://github.com/LumePart/explo))",
+		Retry:     retry,
+		Cache:     DefaultCache(),
 	}
 }
 
-func (c *HttpClient) MakeRequest(method, url string, payload io.Reader, headers map[string]string) ([]byte, error) {
-	req, err := http.NewRequest(method, url, payload)
+// MakeRequest sends a request and returns the response body. Failed requests are retried
+// according to the client's RetryPolicy, and per-host rate limits are respected
+func (c *HttpClient) MakeRequest(ctx context.Context, method, url string, payload io.Reader, headers map[string]string) ([]byte, error) {
+	cacheable := c.Cache != nil && method == http.MethodGet
+	if cacheable {
+		if body, ok := c.Cache.Get(url, headers); ok {
+			return body, nil
+		}
+	}
+
+	var data []byte
+	if payload != nil { // buffer payload so it can be resent on retries
+		var err error
+		if data, err = io.ReadAll(payload); err != nil {
+			return nil, fmt.Errorf("failed to read request payload: %s", err.Error())
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := c.newRequest(ctx, method, url, data, headers)
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		body, err := c.doRequest(req)
+		if c.Observe != nil {
+			c.Observe(time.Since(start), err)
+		}
+		if err == nil {
+			if cacheable {
+				c.Cache.Put(url, headers, body)
+			}
+			return body, nil
+		}
+
+		if attempt >= c.Retry.MaxRetries || !shouldRetry(method, err) {
+			return nil, err
+		}
+
+		wait := c.Retry.backoff(attempt)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			wait = min(statusErr.RetryAfter, c.Retry.MaxDelay)
+		}
+		slog.Debug("request failed, retrying", "host", req.URL.Host, "attempt", attempt+1, "wait", wait.String(), "err", err.Error())
+
+		if err := Sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *HttpClient) newRequest(ctx context.Context, method, url string, data []byte, headers map[string]string) (*http.Request, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize request: %s", err.Error())
 	}
@@ -44,10 +115,17 @@ func (c *HttpClient) MakeRequest(method, url string, payload io.Reader, headers
 	for key, value := range headers {
 		req.Header.Add(key, value)
 	}
+	return req, nil
+}
+
+func (c *HttpClient) doRequest(req *http.Request) ([]byte, error) {
+	if err := waitForHost(req.Context(), req.URL.Hostname()); err != nil {
+		return nil, err
+	}
 
 	resp, err := c.Client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %s", err.Error())
+		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 
 	defer func() {
@@ -58,12 +136,16 @@ func (c *HttpClient) MakeRequest(method, url string, payload io.Reader, headers
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %s", err.Error())
+		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		slog.Debug("response info", logging.RuntimeAttr(string(body)))
-		return nil, fmt.Errorf("got %d from %s", resp.StatusCode, url)
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			URL:        req.URL.String(),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
 	return body, nil
@@ -0,0 +1,134 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RetryPolicy controls how MakeRequest retries failed requests
+type RetryPolicy struct {
+	MaxRetries int           // retries after the first attempt, 0 disables retrying
+	BaseDelay  time.Duration // delay before the first retry, doubled on every attempt
+	MaxDelay   time.Duration // upper bound for a single wait, also caps Retry-After
+}
+
+// DefaultRetryPolicy is used by clients created without an explicit policy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  time.Second,
+	MaxDelay:   time.Minute,
+}
+
+// ConfigureRetries overrides the default retry policy, should be called before any clients are created
+func ConfigureRetries(maxRetries int, maxDelay time.Duration) {
+	if maxRetries >= 0 {
+		DefaultRetryPolicy.MaxRetries = maxRetries
+	}
+	if maxDelay > 0 {
+		DefaultRetryPolicy.MaxDelay = maxDelay
+	}
+}
+
+// backoff returns the wait before retry number attempt (starting at 0), exponential with jitter
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << attempt
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// StatusError is returned by MakeRequest when the server responds with a non-2xx status
+type StatusError struct {
+	StatusCode int
+	URL        string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("got %d from %s", e.StatusCode, e.URL)
+}
+
+// shouldRetry reports if a failed request is worth another attempt.
+// 429 and 503 mean the request wasn't processed, so they're retried for every method,
+// other server and network errors only for idempotent methods.
+func shouldRetry(method string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true
+		case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+			return idempotent(method)
+		default:
+			return false
+		}
+	}
+	return idempotent(method)
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header, either in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// Per-host rate limits, shared by every HttpClient in the process
+var (
+	hostLimitersMu sync.Mutex
+	hostLimiters   = map[string]*rate.Limiter{
+		"musicbrainz.org": rate.NewLimiter(rate.Every(time.Second), 1),          // https://musicbrainz.org/doc/MusicBrainz_API/Rate_Limiting
+		"api.deezer.com":  rate.NewLimiter(rate.Every(110*time.Millisecond), 5), // 50 requests per 5 seconds
+	}
+)
+
+// SetHostRateLimit limits requests to host to r per second (burst allows short spikes)
+func SetHostRateLimit(host string, r rate.Limit, burst int) {
+	hostLimitersMu.Lock()
+	defer hostLimitersMu.Unlock()
+	hostLimiters[strings.ToLower(host)] = rate.NewLimiter(r, burst)
+}
+
+// waitForHost blocks until the host's rate limiter allows another request
+func waitForHost(ctx context.Context, host string) error {
+	hostLimitersMu.Lock()
+	limiter, ok := hostLimiters[strings.ToLower(host)]
+	hostLimitersMu.Unlock()
+	if !ok {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShouldRetry(t *testing.T) {
+	status := func(code int) error { return &StatusError{StatusCode: code, URL: "https://example.com"} }
+	netErr := errors.New("connection reset by peer")
+
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		want   bool
+	}{
+		{"429 get", http.MethodGet, status(http.StatusTooManyRequests), true},
+		{"429 post", http.MethodPost, status(http.StatusTooManyRequests), true},
+		{"503 post", http.MethodPost, status(http.StatusServiceUnavailable), true},
+		{"500 get", http.MethodGet, status(http.StatusInternalServerError), true},
+		{"500 post", http.MethodPost, status(http.StatusInternalServerError), false},
+		{"502 put", http.MethodPut, status(http.StatusBadGateway), true},
+		{"504 delete", http.MethodDelete, status(http.StatusGatewayTimeout), true},
+		{"404 get", http.MethodGet, status(http.StatusNotFound), false},
+		{"401 get", http.MethodGet, status(http.StatusUnauthorized), false},
+		{"wrapped 429", http.MethodPost, fmt.Errorf("lookup: %w", status(http.StatusTooManyRequests)), true},
+		{"network get", http.MethodGet, netErr, true},
+		{"network post", http.MethodPost, netErr, false},
+		{"canceled", http.MethodGet, context.Canceled, false},
+		{"deadline", http.MethodGet, fmt.Errorf("request: %w", context.DeadlineExceeded), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldRetry(tt.method, tt.err); got != tt.want {
+				t.Errorf("shouldRetry(%s, %v) = %v, want %v", tt.method, tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"empty", "", 0},
+		{"seconds", "120", 2 * time.Minute},
+		{"padded", " 5 ", 5 * time.Second},
+		{"zero", "0", 0},
+		{"negative", "-3", 0},
+		{"garbage", "soon", 0},
+		{"past date", "Wed, 21 Oct 2015 07:28:00 GMT", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("future date", func(t *testing.T) {
+		value := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+		got := parseRetryAfter(value)
+		if got < 59*time.Minute || got > time.Hour {
+			t.Errorf("parseRetryAfter(%q) = %v, want about an hour", value, got)
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 500 * time.Millisecond, time.Second},
+		{1, time.Second, 2 * time.Second},
+		{2, 2 * time.Second, 4 * time.Second},
+		{3, 4 * time.Second, 8 * time.Second},
+		{4, 5 * time.Second, 10 * time.Second},   // capped at MaxDelay
+		{63, 5 * time.Second, 10 * time.Second},  // shift overflow
+		{100, 5 * time.Second, 10 * time.Second}, // shifted out entirely
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.attempt), func(t *testing.T) {
+			for range 50 { // jittered, check the bounds hold every time
+				if got := p.backoff(tt.attempt); got < tt.min || got > tt.max {
+					t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempt, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
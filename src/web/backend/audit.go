@@ -0,0 +1,259 @@
+package backend
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ── Audit log ──────────────────────────────────────────────────────────────
+
+// Audit actions
+const (
+	auditLogin        = "login"
+	auditLoginFailed  = "login_failed"
+	auditLoginLocked  = "login_locked"
+	auditLogout       = "logout"
+	auditConfigSave   = "config_save"
+	auditConfigReset  = "config_reset"
+	auditScheduleSave = "schedule_save"
+	auditRunStart     = "run_start"
+	auditTokenCreate  = "token_create"
+	auditTokenRevoke  = "token_revoke"
+	auditProfileSave  = "profile_save"
+)
+
+// auditMaxBytes is the size at which the audit log is rotated to audit.log.1
+const auditMaxBytes = 5 << 20
+
+// AuditEvent is one line of the audit log
+type AuditEvent struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	User   string    `json:"user,omitempty"` // username, or "token:<name>" for API tokens
+	IP     string    `json:"ip,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// AuditLog appends security relevant events to <WEB_DATA_PATH>/audit.log as JSON lines
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewAuditLog(dataDir string) *AuditLog {
+	return &AuditLog{path: filepath.Join(dataDir, "audit.log")}
+}
+
+func (a *AuditLog) Record(ev AuditEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		slog.Warn("failed encoding audit event", "err", err.Error())
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if info, err := os.Stat(a.path); err == nil && info.Size() > auditMaxBytes {
+		if err := os.Rename(a.path, a.path+".1"); err != nil {
+			slog.Warn("failed rotating audit log", "err", err.Error())
+		}
+	}
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		slog.Warn("failed opening audit log", "err", err.Error())
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		slog.Warn("failed writing audit log", "err", err.Error())
+	}
+}
+
+// Recent returns up to limit events, newest first
+func (a *AuditLog) Recent(limit int) ([]AuditEvent, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var events []AuditEvent
+	for _, path := range []string{a.path + ".1", a.path} {
+		f, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			var ev AuditEvent
+			if json.Unmarshal(sc.Bytes(), &ev) == nil {
+				events = append(events, ev)
+			}
+		}
+		f.Close()
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+	slices.Reverse(events)
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// audit records an action done by the request's user
+func (s *Server) audit(r *http.Request, action, detail string) {
+	user, _ := s.sessionManager.GetSession(r).Get("username").(string)
+	s.auditLog.Record(AuditEvent{
+		Action: action,
+		User:   user,
+		IP:     s.authStore.trusted.clientIP(r),
+		Detail: detail,
+	})
+}
+
+// handleGetAudit returns the newest audit events, ?limit= caps the count (default 200).
+func (s *Server) handleGetAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 200
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	events, err := s.auditLog.Recent(limit)
+	if err != nil {
+		slog.Error("failed to read audit log", "err", err.Error())
+		http.Error(w, "failed to read audit log", http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []AuditEvent{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		slog.Warn("failed encoding audit log to response", "err", err.Error())
+	}
+}
+
+// ── Login rate limiting ────────────────────────────────────────────────────
+
+// lockoutMax caps the exponential lockout, failures are forgotten once it has passed
+const lockoutMax = time.Hour
+
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// loginLimiter locks out a client IP or a username after too many failed logins. Every
+// failure past the limit doubles the lockout, starting at base. A username takes
+// userAttemptsFactor times more failures than an IP, across all IPs: enough to slow down
+// guessing one account's password from many addresses, without a few wrong passwords from
+// elsewhere locking the real user out.
+type loginLimiter struct {
+	mu       sync.Mutex
+	attempts int
+	base     time.Duration
+	failures map[string]*loginFailures
+}
+
+// userAttemptsFactor multiplies LOGIN_MAX_ATTEMPTS for the per-username limit
+const userAttemptsFactor = 4
+
+func newLoginLimiter(attempts int, base time.Duration) *loginLimiter {
+	return &loginLimiter{attempts: attempts, base: base, failures: make(map[string]*loginFailures)}
+}
+
+type limiterKey struct {
+	key      string
+	attempts int // failures before the key is locked out
+}
+
+func (l *loginLimiter) keys(ip, user string) []limiterKey {
+	return []limiterKey{
+		{"ip:" + ip, l.attempts},
+		{"user:" + user, l.attempts * userAttemptsFactor},
+	}
+}
+
+// locked returns how long the ip or the username is still locked out
+func (l *loginLimiter) locked(ip, user string) time.Duration {
+	if l.attempts <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var wait time.Duration
+	for _, k := range l.keys(ip, user) {
+		if f, ok := l.failures[k.key]; ok {
+			wait = max(wait, time.Until(f.lockedUntil))
+		}
+	}
+	return wait
+}
+
+// fail counts a failed login, returns true if it caused a lockout
+func (l *loginLimiter) fail(ip, user string) bool {
+	if l.attempts <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	var lockout bool
+	for _, k := range l.keys(ip, user) {
+		f, ok := l.failures[k.key]
+		if !ok || now.Sub(f.last) > lockoutMax {
+			f = &loginFailures{}
+			l.failures[k.key] = f
+		}
+		f.count++
+		f.last = now
+		if over := f.count - k.attempts; over >= 0 {
+			d := time.Duration(float64(l.base) * math.Pow(2, float64(min(over, 16))))
+			f.lockedUntil = now.Add(min(d, lockoutMax))
+			lockout = true
+		}
+	}
+	l.prune(now)
+	return lockout
+}
+
+// succeed forgets the failures of a successful login
+func (l *loginLimiter) succeed(ip, user string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, k := range l.keys(ip, user) {
+		delete(l.failures, k.key)
+	}
+}
+
+// prune drops stale entries so random usernames can't grow the map forever, callers hold l.mu
+func (l *loginLimiter) prune(now time.Time) {
+	for k, f := range l.failures {
+		if now.Sub(f.last) > lockoutMax && now.After(f.lockedUntil) {
+			delete(l.failures, k)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package backend
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	type attempt struct {
+		ip, user string
+		ok       bool // successful login, otherwise a failure
+	}
+	fails := func(n int, ip, user string) []attempt {
+		out := make([]attempt, n)
+		for i := range out {
+			out[i] = attempt{ip: ip, user: user}
+		}
+		return out
+	}
+	// spread fails from a new IP every time, like a botnet would
+	spread := func(n int, user string) []attempt {
+		out := make([]attempt, n)
+		for i := range out {
+			out[i] = attempt{ip: fmt.Sprintf("10.0.0.%d", i), user: user}
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		attempts int
+		history  []attempt
+		ip, user string
+		locked   bool
+	}{
+		{"under the limit", 3, fails(2, "1.1.1.1", "admin"), "1.1.1.1", "admin", false},
+		{"at the limit", 3, fails(3, "1.1.1.1", "admin"), "1.1.1.1", "admin", true},
+		{"ip locked for every user", 3, fails(3, "1.1.1.1", "admin"), "1.1.1.1", "someone", true},
+		{"other ip unaffected", 3, fails(3, "1.1.1.1", "admin"), "2.2.2.2", "admin", false},
+		{"a few failures elsewhere don't lock the username", 3, spread(3*userAttemptsFactor-1, "admin"), "3.3.3.3", "admin", false},
+		{"username locked from every ip", 3, spread(3*userAttemptsFactor, "admin"), "3.3.3.3", "admin", true},
+		{"other usernames unaffected", 3, spread(3*userAttemptsFactor, "admin"), "3.3.3.3", "someone", false},
+		{
+			name:     "success forgets failures",
+			attempts: 3,
+			history:  append(fails(2, "1.1.1.1", "admin"), attempt{ip: "1.1.1.1", user: "admin", ok: true}, attempt{ip: "1.1.1.1", user: "admin"}),
+			ip:       "1.1.1.1", user: "admin",
+			locked: false,
+		},
+		{"disabled", 0, fails(10, "1.1.1.1", "admin"), "1.1.1.1", "admin", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLoginLimiter(tt.attempts, time.Minute)
+			for _, a := range tt.history {
+				if a.ok {
+					l.succeed(a.ip, a.user)
+				} else {
+					l.fail(a.ip, a.user)
+				}
+			}
+			if got := l.locked(tt.ip, tt.user) > 0; got != tt.locked {
+				t.Errorf("locked(%s, %s) = %v, want %v", tt.ip, tt.user, got, tt.locked)
+			}
+		})
+	}
+}
+
+func TestLoginLimiterBackoff(t *testing.T) {
+	l := newLoginLimiter(2, time.Minute)
+	if l.fail("1.1.1.1", "admin") {
+		t.Fatal("first failure caused a lockout")
+	}
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute}
+	for i, w := range want {
+		if !l.fail("1.1.1.1", "admin") {
+			t.Fatalf("failure %d didn't cause a lockout", i+2)
+		}
+		if got := l.locked("1.1.1.1", "admin"); got > w || got < w-time.Second {
+			t.Errorf("after failure %d locked for %v, want %v", i+2, got, w)
+		}
+	}
+
+	for range 20 {
+		l.fail("1.1.1.1", "admin")
+	}
+	if got := l.locked("1.1.1.1", "admin"); got > lockoutMax {
+		t.Errorf("locked for %v, more than lockoutMax", got)
+	}
+}
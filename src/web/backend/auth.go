@@ -1,6 +1,8 @@
 package backend
 
 import (
+	"fmt"
+	"log/slog"
 	"net/http"
 
 	"golang.org/x/crypto/bcrypt"
@@ -14,9 +16,15 @@ type AuthStore struct {
 	Username string
 	Hash string
 	sessionManager *SessionManager
+	tokens *TokenStore
+	proxy *proxyAuth // nil unless AUTH_PROXY_HEADER and AUTH_TRUSTED_PROXIES are set
+	oidc *oidcAuth   // nil unless OIDC is configured
+	trusted trustedProxies
+	limiter *loginLimiter
+	audit *AuditLog
 }
 
-func NewAuthStore(user, password string, sessionManager *SessionManager) *AuthStore{
+func NewAuthStore(user, password string, sessionManager *SessionManager, tokens *TokenStore) *AuthStore{
 	hashPass, err := hashPassword(password)
 	if err != nil {
 		panic("failed to hash password")
@@ -26,23 +34,40 @@ func NewAuthStore(user, password string, sessionManager *SessionManager) *AuthSt
 		Username: user,
 		Hash: hashPass,
 		sessionManager: sessionManager,
+		tokens: tokens,
 	}
 }
 
 
 func (a *AuthStore) CompareCreds(formUser, formPass string) bool {
+	if a.Username == "" { // password login is disabled (SSO only)
+		return false
+	}
 	if formUser != a.Username || bcrypt.CompareHashAndPassword([]byte(a.Hash), []byte(formPass)) != nil {
 		return false
 	}
 	return true
 }
 
+// RequireAuth accepts a logged in session or an API token with enough scope for the request
 func (a *AuthStore) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		sess := a.sessionManager.GetSession(r)
+		if raw, ok := bearerToken(r); ok {
+			tok := a.tokens.Authenticate(raw)
+			if tok == nil {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if need := requiredScope(r); !scopeAllows(tok.Scope, need) {
+				http.Error(w, fmt.Sprintf("token scope %q can't access this route, needs %q", tok.Scope, need), http.StatusForbidden)
+				return
+			}
+			a.sessionManager.GetSession(r).Put("username", "token:"+tok.Name) // for the audit log
+			next.ServeHTTP(w, r)
+			return
+		}
 
-		auth, _ := sess.Get("authenticated").(bool)
-		if !auth {
+		if !a.Authenticated(r) {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
@@ -51,6 +76,35 @@ func (a *AuthStore) RequireAuth(next http.Handler) http.Handler {
 	})
 }
 
+// Authenticated reports whether the request's session is logged in. Requests from a
+// trusted proxy carrying a username header log the session in on the way.
+func (a *AuthStore) Authenticated(r *http.Request) bool {
+	sess := a.sessionManager.GetSession(r)
+	auth, _ := sess.Get("authenticated").(bool)
+
+	user := a.proxy.user(r)
+	if user == "" {
+		return auth
+	}
+	if current, _ := sess.Get("username").(string); !auth || current != user {
+		a.login(r, sess, user, "proxy")
+	}
+	return true
+}
+
+// login marks a session as authenticated for user, under a new ID so a session
+// planted before login can't be reused
+func (a *AuthStore) login(r *http.Request, sess *Session, user, method string) {
+	if err := a.sessionManager.Migrate(sess); err != nil {
+		slog.Error("failed to renew session ID", "msg", err.Error())
+	}
+	sess.Put("authenticated", true)
+	sess.Put("username", user)
+	ip := a.trusted.clientIP(r)
+	slog.Info("successful login", "user", user, "method", method, "ip", ip)
+	a.audit.Record(AuditEvent{Action: auditLogin, User: user, IP: ip, Detail: method})
+}
+
 func hashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 10)
 	return string(bytes), err
@@ -1,18 +1,21 @@
 package backend
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math/rand/v2"
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 
-	"explo/src/discovery"
+	"explo/src/config"
+	"explo/src/sources"
 	"explo/src/util"
 	"explo/src/web"
 
@@ -24,10 +27,10 @@ import (
 type CustomPlaylist struct {
 	ID              string    `json:"id"`
 	Name            string    `json:"name"`
-	Source          string    `json:"source"`                     // "listenbrainz" | "apple_music" | "spotify"
-	SourceURL       string    `json:"source_url,omitempty"`       // original URL for dedup + refresh
+	Source          string    `json:"source"`                     // name of a registered sources.PlaylistSource
+	SourceURL       string    `json:"source_url,omitempty"`       // original URL for dedup + refresh, file name for uploads
 	LBMBID          string    `json:"lb_mbid,omitempty"`          // ListenBrainz MBID (backward compat)
-	ArtworkURL      string    `json:"artwork_url,omitempty"`      // playlist cover image (Apple Music)
+	ArtworkURL      string    `json:"artwork_url,omitempty"`      // playlist cover image, if the source has one
 	ArtworkUploaded bool      `json:"artwork_uploaded,omitempty"` // true after artwork has been pushed to the music app
 	RefreshDays     int       `json:"refresh_days"`
 	ColorIndex      int       `json:"color_index"`
@@ -66,55 +69,10 @@ func MarkCustomPlaylistArtworkUploaded(cfgDir, id string) error {
 	return nil
 }
 
-var lbMBIDRe = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
-
-var appleMusicURLRe = regexp.MustCompile(
-	`^https?://music\.apple\.com/[a-z]{2}/playlist/[^/]+/(pl\.[a-zA-Z0-9-]+)`,
-)
-
-// extractAppleMusicID pulls the playlist ID (pl.xxx) from an Apple Music URL.
-func extractAppleMusicID(raw string) (string, error) {
-	raw = strings.TrimSpace(raw)
-	m := appleMusicURLRe.FindStringSubmatch(raw)
-	if len(m) < 2 {
-		return "", fmt.Errorf("not a valid Apple Music playlist URL")
-	}
-	return m[1], nil
-}
-
-// extractLBMBID pulls the playlist UUID out of a ListenBrainz playlist URL or bare MBID string.
-func extractLBMBID(raw string) (string, error) {
-	raw = strings.TrimSpace(raw)
-	m := lbMBIDRe.FindString(raw)
-	if m == "" {
-		return "", fmt.Errorf("no ListenBrainz playlist UUID found in %q", raw)
-	}
-	return m, nil
-}
-
 func customPlaylistsPath(cfgDir string) string {
 	return filepath.Join(cfgDir, "custom-playlists.json")
 }
 
-// customEnvPrefix converts a playlist name like "Today's Hits"
-// to an env-var prefix like "CUSTOM_TODAYS_HITS".
-// Non-alphanumeric characters are collapsed into underscores.
-func customEnvPrefix(name string) string {
-	var b strings.Builder
-	prevUnderscore := true // start true so leading separators are skipped
-	for _, r := range strings.ToUpper(name) {
-		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
-			b.WriteRune(r)
-			prevUnderscore = false
-		} else if !prevUnderscore {
-			b.WriteRune('_')
-			prevUnderscore = true
-		}
-	}
-	return "CUSTOM_" + strings.TrimRight(b.String(), "_")
-}
-
-
 func loadCustomPlaylists(cfgDir string) []CustomPlaylist {
 	data, err := os.ReadFile(customPlaylistsPath(cfgDir))
 	if err != nil {
@@ -136,71 +94,42 @@ func saveCustomPlaylists(cfgDir string, playlists []CustomPlaylist) error {
 	return os.WriteFile(customPlaylistsPath(cfgDir), raw, 0644)
 }
 
-// FetchResult is the uniform return type for fetching playlist data from any source.
-type FetchResult struct {
-	Name       string
-	ArtworkURL string
-	Tracks     []PlaylistTrack
-}
-
-// fetchCustomPlaylistTracks dispatches to the appropriate source fetcher.
-// This is the single point where source-specific logic lives for fetching.
-func fetchCustomPlaylistTracks(p CustomPlaylist) (FetchResult, error) {
-	switch p.Source {
-	case "apple_music":
-		name, art, tracks, err := fetchAppleMusicPlaylist(p.SourceURL)
-		return FetchResult{name, art, tracks}, err
-	case "spotify":
-		name, art, tracks, err := fetchSpotifyPlaylist(p.SourceURL)
-		return FetchResult{name, art, tracks}, err
-	default: // "listenbrainz" or legacy empty
-		mbid := p.LBMBID
-		if mbid == "" && p.SourceURL != "" {
-			var err error
-			mbid, err = extractLBMBID(p.SourceURL)
-			if err != nil {
-				return FetchResult{}, err
-			}
-		}
-		if mbid == "" {
-			return FetchResult{}, fmt.Errorf("no source data for playlist %s", p.ID)
-		}
-		httpClient := util.NewHttp(util.HttpClientConfig{Timeout: 30})
-		name, modelTracks, err := discovery.FetchPlaylistByMBID(httpClient, mbid)
-		if err != nil {
-			return FetchResult{}, err
-		}
-		tracks := modelTracksToPlaylistTracks(modelTracks)
-		return FetchResult{Name: name, Tracks: tracks}, nil
+// fetchCustomPlaylistTracks fetches a saved playlist from its source.
+func fetchCustomPlaylistTracks(ctx context.Context, p CustomPlaylist) (sources.Playlist, error) {
+	src, err := sources.Get(p.Source)
+	if err != nil {
+		return sources.Playlist{}, err
+	}
+	url := p.SourceURL
+	if url == "" {
+		url = p.LBMBID // imports from before SourceURL was saved
 	}
+	if url == "" {
+		return sources.Playlist{}, fmt.Errorf("no source data for playlist %s", p.ID)
+	}
+	return src.Fetch(ctx, url)
 }
 
-// extractSourceID validates a URL and returns the canonical ID for the given source.
-func extractSourceID(source, url string) (string, error) {
-	switch source {
-	case "apple_music":
-		return extractAppleMusicID(url)
-	case "spotify":
-		return extractSpotifyID(url)
-	default:
-		return extractLBMBID(url)
+// sourceID returns the canonical ID of a saved playlist, "" if it can't be worked out
+func sourceID(p CustomPlaylist) string {
+	src, err := sources.Get(p.Source)
+	if err != nil {
+		return ""
 	}
+	id, _ := src.ID(p.SourceURL)
+	if id == "" {
+		id = p.LBMBID
+	}
+	return id
 }
 
 // isDuplicate checks whether a playlist with the same source and source ID already exists.
-func isDuplicate(source, sourceID string, existing []CustomPlaylist) (string, bool) {
+func isDuplicate(source, id string, existing []CustomPlaylist) (string, bool) {
 	for _, p := range existing {
-		if p.Source != source && p.Source != "" {
-			continue
-		}
-		existID, _ := extractSourceID(p.Source, p.SourceURL)
-		if existID == "" && p.LBMBID != "" {
-			existID = p.LBMBID
-		}
-		if existID == "" {
+		if p.Source != source && !(p.Source == "" && source == sources.DefaultSource) {
 			continue
 		}
-		if existID == sourceID {
+		if existID := sourceID(p); existID != "" && existID == id {
 			return p.ID, true
 		}
 	}
@@ -229,7 +158,7 @@ func (s *Server) handleGetCustomPlaylists(w http.ResponseWriter, r *http.Request
 	items := make([]respItem, 0, len(playlists))
 	for _, p := range playlists {
 		count := customPlaylistTrackCount(s.cfg.WebDataDir, p.ID)
-		prefix := customEnvPrefix(p.Name)
+		prefix := config.CustomEnvPrefix(p.Name)
 		sched := envValues[prefix+"_SCHEDULE"]
 		flags := envValues[prefix+"_FLAGS"]
 		items = append(items, respItem{CustomPlaylist: p, TrackCount: count, Schedule: sched, Flags: flags})
@@ -241,12 +170,12 @@ func (s *Server) handleGetCustomPlaylists(w http.ResponseWriter, r *http.Request
 	}
 }
 
-// handleImportCustomPlaylist imports a playlist by URL (ListenBrainz or Apple Music),
+// handleImportCustomPlaylist imports a playlist by URL from any registered source,
 // writes a cache, and returns the playlist name/tracks to the frontend for the import animation.
 func (s *Server) handleImportCustomPlaylist(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		URL         string `json:"url"`
-		Source      string `json:"source"` // "listenbrainz" | "apple_music"
+		Source      string `json:"source"` // a registered source name, detected from the URL if empty
 		RefreshDays int    `json:"refresh_days"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -256,11 +185,24 @@ func (s *Server) handleImportCustomPlaylist(w http.ResponseWriter, r *http.Reque
 
 	existing := loadCustomPlaylists(s.cfg.WebDataDir)
 
+	var src sources.PlaylistSource
 	if body.Source == "" {
-		body.Source = "listenbrainz"
+		detected, ok := sources.Detect(body.URL)
+		if !ok {
+			http.Error(w, "unrecognised playlist URL", http.StatusBadRequest)
+			return
+		}
+		src = detected
+	} else {
+		var err error
+		if src, err = sources.Get(body.Source); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
+	body.Source = src.Name()
 
-	sourceID, err := extractSourceID(body.Source, body.URL)
+	sourceID, err := src.ID(body.URL)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -273,20 +215,37 @@ func (s *Server) handleImportCustomPlaylist(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	result, err := fetchCustomPlaylistTracks(CustomPlaylist{Source: body.Source, SourceURL: body.URL})
+	result, err := src.Fetch(r.Context(), body.URL)
 	if err != nil {
 		slog.Error("custom-playlists: fetch failed", "source", body.Source, "err", err)
 		http.Error(w, "failed to fetch playlist: "+err.Error(), http.StatusBadGateway)
 		return
 	}
 
+	// Derive LBMBID for backward compatibility (LB playlists only)
+	var lbMBID string
+	if body.Source == "listenbrainz" {
+		lbMBID = sourceID
+	}
+	s.storeCustomPlaylist(w, r, existing, CustomPlaylist{
+		Source:      body.Source,
+		SourceURL:   body.URL,
+		LBMBID:      lbMBID, // empty for other sources
+		RefreshDays: body.RefreshDays,
+	}, result)
+}
+
+// storeCustomPlaylist saves a fetched or uploaded playlist: it writes the track cache,
+// caches artwork, saves the metadata and FLAGS/SCHEDULE, and answers with the data the
+// frontend shows in its import animation. cp carries the source fields, the rest is filled in.
+func (s *Server) storeCustomPlaylist(w http.ResponseWriter, r *http.Request, existing []CustomPlaylist, cp CustomPlaylist, result sources.Playlist) {
 	name := result.Name
 	tracks := result.Tracks
 	artworkURL := result.ArtworkURL
 	if name == "" {
 		name = "Imported Playlist"
 	}
-	slog.Info("custom-playlists: fetched", "source", body.Source, "name", name, "tracks", len(tracks))
+	slog.Info("custom-playlists: fetched", "source", cp.Source, "name", name, "tracks", len(tracks))
 
 	// Ensure data directories exist before writing anything
 	if err := os.MkdirAll(filepath.Join(s.cfg.WebDataDir, "cache"), 0755); err != nil {
@@ -308,8 +267,7 @@ func (s *Server) handleImportCustomPlaylist(w http.ResponseWriter, r *http.Reque
 	go downloadAndCacheCovers(s.cfg.WebDataDir, id, tracks)
 
 	// Cache the playlist's own artwork locally so we can later push it to the
-	// music app on first playlist creation. Apple Music imports have artwork;
-	// ListenBrainz don't.
+	// music app on first playlist creation. Only some sources have artwork.
 	if artworkURL != "" {
 		go func() {
 			if _, err := util.DownloadFile(artworkURL, CustomPlaylistArtworkPath(s.cfg.WebDataDir, id)); err != nil {
@@ -319,23 +277,11 @@ func (s *Server) handleImportCustomPlaylist(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Save metadata
-	// Derive LBMBID for backward compatibility (LB playlists only)
-	var lbMBID string
-	if body.Source != "apple_music" && body.Source != "spotify" {
-		lbMBID = sourceID
-	}
-
-	cp := CustomPlaylist{
-		ID:          id,
-		Name:        name,
-		Source:      body.Source,
-		SourceURL:   body.URL,
-		LBMBID:      lbMBID,     // empty for apple_music
-		ArtworkURL:  artworkURL, // empty for listenbrainz
-		RefreshDays: body.RefreshDays,
-		ColorIndex:  len(existing),
-		LastFetched: time.Now().UTC(),
-	}
+	cp.ID = id
+	cp.Name = name
+	cp.ArtworkURL = artworkURL // empty if the source has none
+	cp.ColorIndex = len(existing)
+	cp.LastFetched = time.Now().UTC()
 	existing = append(existing, cp)
 	if err := saveCustomPlaylists(s.cfg.WebDataDir, existing); err != nil {
 		slog.Error("custom-playlists: failed to save metadata", "err", err)
@@ -347,14 +293,17 @@ func (s *Server) handleImportCustomPlaylist(w http.ResponseWriter, r *http.Reque
 	// a daily poll SCHEDULE — RefreshDays in the JSON gates the actual refresh interval
 	// inside the cron task body. "Never" imports get FLAGS only so the card is usable
 	// for manual runs while the schedule editor pre-selects "Never".
-	prefix := customEnvPrefix(name)
+	prefix := config.CustomEnvPrefix(name)
 	envUpdates := map[string]string{
 		prefix + "_FLAGS": "--playlist " + id,
 	}
-	if body.RefreshDays > 0 {
+	if cp.RefreshDays > 0 {
 		envUpdates[prefix+"_SCHEDULE"] = "0 4 * * *"
 	}
-	_ = updateEnvKeys(s.cfg.WebEnvPath, envUpdates, web.SampleEnv)
+	if err := updateEnvKeys(s.cfg.WebEnvPath, envUpdates, web.SampleEnv); err == nil {
+		s.audit(r, auditConfigSave, "custom playlist schedule: "+name)
+	}
+	s.reloadSchedules()
 
 	slog.Info("custom-playlists: import complete", "id", id, "name", name)
 
@@ -384,6 +333,66 @@ func (s *Server) handleImportCustomPlaylist(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// maxPlaylistUpload caps the size of uploaded playlist files
+const maxPlaylistUpload = 10 << 20
+
+// handleUploadCustomPlaylist imports a playlist from an uploaded M3U/M3U8, XSPF, JSPF (.jspf
+// or .json) or CSV (.csv or .tsv) file (multipart field "file"). Optional fields: "name" overrides the playlist name, and
+// "title_column", "artist_column", "album_column" and "isrc_column" map CSV columns by header
+// name or 1-based number. Uploads have nothing to refresh from, so they never refresh.
+func (s *Server) handleUploadCustomPlaylist(w http.ResponseWriter, r *http.Request) {
+	// the file plus room for the other form fields, larger bodies fail while parsing
+	r.Body = http.MaxBytesReader(w, r.Body, maxPlaylistUpload+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "playlist file is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "expected a multipart form with a playlist file", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "missing playlist file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	if header.Size > maxPlaylistUpload {
+		http.Error(w, "playlist file is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxPlaylistUpload))
+	if err != nil {
+		http.Error(w, "failed to read playlist file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := sources.ParseFile(header.Filename, data, sources.CSVColumns{
+		Title:  strings.TrimSpace(r.FormValue("title_column")),
+		Artist: strings.TrimSpace(r.FormValue("artist_column")),
+		Album:  strings.TrimSpace(r.FormValue("album_column")),
+		ISRC:   strings.TrimSpace(r.FormValue("isrc_column")),
+	})
+	if err != nil {
+		slog.Warn("custom-playlists: upload rejected", "file", header.Filename, "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if name := strings.TrimSpace(r.FormValue("name")); name != "" {
+		result.Name = name
+	}
+	if result.Name == "" {
+		result.Name = strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename))
+	}
+	slog.Info("custom-playlists: upload", "file", header.Filename, "tracks", len(result.Tracks))
+
+	s.storeCustomPlaylist(w, r, loadCustomPlaylists(s.cfg.WebDataDir), CustomPlaylist{
+		Source:    sources.FileSource,
+		SourceURL: filepath.Base(header.Filename),
+	}, result)
+}
+
 // handleRefreshCustomPlaylist re-fetches a custom playlist and updates the cache.
 // Equivalent to manually triggering the nightly refresh cron job for a single playlist.
 func (s *Server) handleRefreshCustomPlaylist(w http.ResponseWriter, r *http.Request) {
@@ -407,9 +416,13 @@ func (s *Server) handleRefreshCustomPlaylist(w http.ResponseWriter, r *http.Requ
 	}
 
 	p := playlists[idx]
+	if p.Source == sources.FileSource {
+		http.Error(w, "uploaded playlists can't be refreshed, upload the file again instead", http.StatusBadRequest)
+		return
+	}
 	slog.Info("custom-playlists: manual refresh", "id", id, "source", p.Source)
 
-	result, err := fetchCustomPlaylistTracks(p)
+	result, err := fetchCustomPlaylistTracks(r.Context(), p)
 	if err != nil {
 		slog.Error("custom-playlists: refresh fetch failed", "id", id, "err", err)
 		http.Error(w, "failed to fetch playlist: "+err.Error(), http.StatusBadGateway)
@@ -475,11 +488,14 @@ func (s *Server) handleDeleteCustomPlaylist(w http.ResponseWriter, r *http.Reque
 	_ = os.Remove(cachePath)
 
 	// Remove schedule env vars from .env
-	prefix := customEnvPrefix(deletedName)
-	_ = updateEnvKeys(s.cfg.WebEnvPath, map[string]string{
+	prefix := config.CustomEnvPrefix(deletedName)
+	if err := updateEnvKeys(s.cfg.WebEnvPath, map[string]string{
 		prefix + "_SCHEDULE": "",
 		prefix + "_FLAGS":    "",
-	}, web.SampleEnv)
+	}, web.SampleEnv); err == nil {
+		s.audit(r, auditConfigSave, "custom playlist schedule removed: "+deletedName)
+	}
+	s.reloadSchedules()
 
 	if deleteTracks {
 		if data, err := os.ReadFile(s.cfg.WebEnvPath); err == nil {
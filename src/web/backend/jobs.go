@@ -1,20 +1,32 @@
 package backend
 
-// Jobs running on a schedule go here i.e cache cleanups (and playlist imports in the future)
+// Jobs running on a schedule go here i.e cache cleanups and playlist generations
 
 import (
+	"context"
 	"path/filepath"
 	"log/slog"
 	"os"
 	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"explo/src/util"
+
 	"github.com/go-co-op/gocron/v2"
 )
 
 
+const playlistRunTag = "playlist-run"
+const syncJobTag = "listenbrainz-sync"
+
 type Jobs struct {
 	scheduler gocron.Scheduler
+
+	mu       sync.Mutex
+	runs     []PlaylistRun        // registered playlist generations
+	lastRuns map[string]time.Time // keyed by job name, kept across reloads
 }
 
 type fileInfo struct {
@@ -29,7 +41,7 @@ func NewJobs() (*Jobs) {
 		slog.Error("failed creating cron scheduler")
 	}
 
-	return &Jobs{ scheduler: scheduler}
+	return &Jobs{ scheduler: scheduler, lastRuns: make(map[string]time.Time)}
 }
 
 func (j *Jobs) Start() {
@@ -49,65 +61,156 @@ func (j *Jobs) RegisterCoverCleanup(schedule, coversDir string, maxBytes int64)
 	return err
 }
 
-// RegisterCustomPlaylistRefresh registers a cache-refresh job for each custom playlist
-// using its stored schedule. Falls back to daily at 4 AM if no schedule is set.
-func (j *Jobs) RegisterCustomPlaylistRefresh(cfgDir, envPath string) error {
-	playlists := loadCustomPlaylists(cfgDir)
-	if len(playlists) == 0 {
+// RegisterHTTPCacheCleanup removes expired API responses and keeps the cache under its size limit
+func (j *Jobs) RegisterHTTPCacheCleanup(schedule string, cache *util.ResponseCache) error {
+	if cache == nil {
 		return nil
 	}
+	_, err := j.scheduler.NewJob(
+		gocron.CronJob(schedule, false),
+		gocron.NewTask(func() {
+			slog.Info("running http cache cleanup")
+
+			cache.Prune()
+		}),
+	)
+
+	return err
+}
+
+// SyncPlaylistRuns replaces the scheduled playlist generations with runs. Called on startup
+// and whenever schedules change, so edits take effect without a restart.
+func (j *Jobs) SyncPlaylistRuns(runs []PlaylistRun, task func(PlaylistRun)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.scheduler.RemoveByTags(playlistRunTag)
+	j.runs = nil
+	for _, run := range runs {
+		_, err := j.scheduler.NewJob(
+			gocron.CronJob(run.Schedule, false),
+			gocron.NewTask(func() {
+				j.mu.Lock()
+				j.lastRuns[run.Name] = time.Now()
+				j.mu.Unlock()
 
-	var envValues map[string]string
-	if data, err := os.ReadFile(envPath); err == nil {
-		envValues = parseEnvText(string(data))
-	} else {
-		envValues = map[string]string{}
+				task(run)
+			}),
+			gocron.WithName(run.Name),
+			gocron.WithTags(playlistRunTag),
+			gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		)
+		if err != nil {
+			slog.Warn("failed to register playlist schedule", "job", run.Name, "schedule", run.Schedule, "err", err.Error())
+			continue
+		}
+		j.runs = append(j.runs, run)
+		slog.Info("registered playlist schedule", "job", run.Name, "schedule", run.Schedule, "flags", strings.Join(run.Flags, " "))
 	}
+}
 
-	for _, p := range playlists {
-		p := p
-		prefix := customEnvPrefix(p.Name)
-		flags := envValues[prefix+"_FLAGS"]
-		if flags == "" {
-			continue // disabled
+// SyncListenBrainzJobs replaces the scheduled ListenBrainz syncs with jobs
+func (j *Jobs) SyncListenBrainzJobs(jobs []SyncJob, task func(SyncJob)) {
+	j.scheduler.RemoveByTags(syncJobTag)
+	for _, job := range jobs {
+		_, err := j.scheduler.NewJob(
+			gocron.CronJob(job.Schedule, false),
+			gocron.NewTask(func() { task(job) }),
+			gocron.WithName(job.Name),
+			gocron.WithTags(syncJobTag),
+			gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		)
+		if err != nil {
+			slog.Warn("failed to register listenbrainz sync", "job", job.Name, "schedule", job.Schedule, "err", err.Error())
+			continue
 		}
-		schedule := envValues[prefix+"_SCHEDULE"]
-		if p.RefreshDays <= 0 && schedule == "" {
+		slog.Info("registered listenbrainz sync", "job", job.Name, "schedule", job.Schedule)
+	}
+}
+
+// PlaylistRuns returns the registered playlist generations with their next and last run times
+func (j *Jobs) PlaylistRuns() []ScheduledRun {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	next := make(map[string]time.Time)
+	for _, job := range j.scheduler.Jobs() {
+		if !slices.Contains(job.Tags(), playlistRunTag) {
 			continue
 		}
-		if schedule == "" {
-			schedule = "0 4 * * *"
+		if t, err := job.NextRun(); err == nil && !t.IsZero() {
+			next[job.Name()] = t
 		}
-		_, err := j.scheduler.NewJob(
-			gocron.CronJob(schedule, false),
-			gocron.NewTask(func() {
-				if time.Since(p.LastFetched) < time.Duration(p.RefreshDays)*24*time.Hour {
-					return
-				}
-				slog.Info("custom-playlists: refreshing", "id", p.ID, "name", p.Name, "source", p.Source)
-				result, err := fetchCustomPlaylistTracks(p)
-				if err != nil {
-					slog.Warn("custom-playlists: refresh fetch failed", "id", p.ID, "err", err)
-					return
-				}
-				writePrefetchCache(cfgDir, p.ID, result.Tracks)
-				playlists := loadCustomPlaylists(cfgDir)
-				for i, pl := range playlists {
-					if pl.ID == p.ID {
-						playlists[i].LastFetched = time.Now().UTC()
-						break
-					}
-				}
-				if err := saveCustomPlaylists(cfgDir, playlists); err != nil {
-					slog.Error("custom-playlists: failed to save after refresh", "err", err)
+	}
+
+	out := make([]ScheduledRun, 0, len(j.runs))
+	for _, run := range j.runs {
+		sr := ScheduledRun{
+			Name:     run.Name,
+			Playlist: run.Playlist(),
+			Schedule: run.Schedule,
+			Flags:    strings.Join(run.Flags, " "),
+			Priority: run.Priority,
+			Profile:  run.Profile,
+		}
+		if t, ok := next[run.Name]; ok {
+			sr.NextRun = &t
+		}
+		if t, ok := j.lastRuns[run.Name]; ok {
+			sr.LastRun = &t
+		}
+		out = append(out, sr)
+	}
+	return out
+}
+
+// customRefreshTimeout bounds one pass over all custom playlists
+const customRefreshTimeout = 30 * time.Minute
+
+// RegisterCustomPlaylistRefresh refreshes every custom playlist with RefreshDays set once its
+// interval has passed, whether or not the playlist has a run schedule. Playlists are read on
+// every pass, so imports and deletes need no re-registering.
+func (j *Jobs) RegisterCustomPlaylistRefresh(schedule, cfgDir string) error {
+	_, err := j.scheduler.NewJob(
+		gocron.CronJob(schedule, false),
+		gocron.NewTask(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), customRefreshTimeout)
+			defer cancel()
+			for _, p := range loadCustomPlaylists(cfgDir) {
+				if p.RefreshDays <= 0 {
+					continue
 				}
-			}),
-		)
-		if err != nil {
-			slog.Warn("custom-playlists: failed to register refresh job", "id", p.ID, "err", err)
+				refreshCustomPlaylist(ctx, cfgDir, p)
+			}
+		}),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+
+	return err
+}
+
+// refreshCustomPlaylist re-fetches an imported playlist's tracks once its refresh interval has passed
+func refreshCustomPlaylist(ctx context.Context, cfgDir string, p CustomPlaylist) {
+	if time.Since(p.LastFetched) < time.Duration(p.RefreshDays)*24*time.Hour {
+		return
+	}
+	slog.Info("custom-playlists: refreshing", "id", p.ID, "name", p.Name, "source", p.Source)
+	result, err := fetchCustomPlaylistTracks(ctx, p)
+	if err != nil {
+		slog.Warn("custom-playlists: refresh fetch failed", "id", p.ID, "err", err)
+		return
+	}
+	writePrefetchCache(cfgDir, p.ID, result.Tracks)
+	playlists := loadCustomPlaylists(cfgDir)
+	for i, pl := range playlists {
+		if pl.ID == p.ID {
+			playlists[i].LastFetched = time.Now().UTC()
+			break
 		}
 	}
-	return nil
+	if err := saveCustomPlaylists(cfgDir, playlists); err != nil {
+		slog.Error("custom-playlists: failed to save after refresh", "err", err)
+	}
 }
 
 func trimCacheDir(dataDir string, maxBytes int64) {
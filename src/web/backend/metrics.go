@@ -0,0 +1,124 @@
+package backend
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"explo/src/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registerMetrics registers the run totals, the web UI's own collectors and the gauges
+// computed from server state on every scrape
+func (s *Server) registerMetrics() {
+	coversDir := filepath.Join(s.cfg.WebDataDir, "cache", "covers")
+	s.registry.MustRegister(
+		s.runTotals,
+		metrics.Runs,
+		metrics.RunDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "explo_cover_cache_bytes", Help: "Size of the cover art cache.",
+		}, func() float64 {
+			size, _ := dirStats(coversDir)
+			return float64(size)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "explo_cover_cache_files", Help: "Files in the cover art cache.",
+		}, func() float64 {
+			_, files := dirStats(coversDir)
+			return float64(files)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "explo_runs_queued", Help: "Runs waiting for the run lock.",
+		}, func() float64 {
+			s.manualRun.mu.Lock()
+			defer s.manualRun.mu.Unlock()
+			return float64(len(s.manualRun.queue))
+		}),
+		scheduleCollector{s.cronJobs},
+	)
+}
+
+var (
+	nextRunDesc = prometheus.NewDesc("explo_schedule_next_run_timestamp_seconds", "Unix time of the next scheduled run.", []string{"job"}, nil)
+	lastRunDesc = prometheus.NewDesc("explo_schedule_last_run_timestamp_seconds", "Unix time a scheduled run was last started.", []string{"job"}, nil)
+)
+
+// scheduleCollector reports when each scheduled playlist run last ran and runs next
+type scheduleCollector struct{ jobs *Jobs }
+
+func (c scheduleCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nextRunDesc
+	ch <- lastRunDesc
+}
+
+func (c scheduleCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, run := range c.jobs.PlaylistRuns() {
+		if run.NextRun != nil {
+			ch <- prometheus.MustNewConstMetric(nextRunDesc, prometheus.GaugeValue, float64(run.NextRun.Unix()), run.Name)
+		}
+		if run.LastRun != nil {
+			ch <- prometheus.MustNewConstMetric(lastRunDesc, prometheus.GaugeValue, float64(run.LastRun.Unix()), run.Name)
+		}
+	}
+}
+
+// recordRun counts a finished run
+func recordRun(run *QueuedRun, code int) {
+	if run == nil {
+		return
+	}
+	metrics.Runs.WithLabelValues(run.Label, strconv.Itoa(code)).Inc()
+	if run.StartedAt != nil {
+		metrics.RunDuration.WithLabelValues(run.Label).Observe(time.Since(*run.StartedAt).Seconds())
+	}
+}
+
+func dirStats(dir string) (size int64, files int) {
+	_ = filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		size += info.Size()
+		files++
+		return nil
+	})
+	return size, files
+}
+
+// metricsAuth lets scrapers in with METRICS_TOKEN or an API token with read scope, and
+// everyone with METRICS_PUBLIC
+func (s *Server) metricsAuth(next http.Handler) http.Handler {
+	authed := s.authStore.RequireAuth(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.MetricsPublic {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if raw, ok := bearerToken(r); ok && s.cfg.MetricsToken != "" &&
+			subtle.ConstantTimeCompare([]byte(raw), []byte(s.cfg.MetricsToken)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		authed.ServeHTTP(w, r)
+	})
+}
+
+// handleMetrics serves Prometheus metrics, enabled with METRICS_ENABLED
+func (s *Server) handleMetrics() http.Handler {
+	promHandler := promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn)})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		promHandler.ServeHTTP(w, r)
+	})
+}
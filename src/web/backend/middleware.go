@@ -1,7 +1,7 @@
 package backend
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"strings"
 	"sync"
@@ -61,7 +61,7 @@ func (s *InMemorySessionStore) read(id string) (*Session, error) {
 
 	session, ok := s.sessions[id]
 	if !ok {
-		return nil, fmt.Errorf("session not found")
+		return nil, errSessionNotFound
 	}
 
 	return session, nil
@@ -71,6 +71,7 @@ func (s *InMemorySessionStore) write(session *Session) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	session.dirty = false
 	s.sessions[session.id] = session
 
 	return nil
@@ -85,12 +86,12 @@ func (s *InMemorySessionStore) destroy(id string) error {
 	return nil
 }
 
-func (s *InMemorySessionStore) gc(absoluteExpiration time.Duration) error {
+func (s *InMemorySessionStore) gc(expired func(*Session) bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	for id, session := range s.sessions {
-		if time.Since(session.createdAt) > absoluteExpiration {
+		if expired(session) {
 			delete(s.sessions, id)
 		}
 	}
@@ -99,6 +100,14 @@ func (s *InMemorySessionStore) gc(absoluteExpiration time.Duration) error {
 }
 func (m *SessionManager) Handle(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// API token requests get a throwaway session: no cookie, and no CSRF check since
+		// browsers never attach an Authorization header on their own
+		if _, ok := bearerToken(r); ok {
+			ctx := context.WithValue(r.Context(), sessionContextKey{}, newSession())
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		// Start the session
 		session, rws := m.start(r)
 
@@ -127,6 +136,9 @@ func (m *SessionManager) Handle(next http.Handler) http.Handler {
 
 		// Write the session cookie to the response if not already written
 		writeCookieIfNecessary(sw)
+
+		// Persist changes the handler made to the session
+		m.save(session)
 	})
 }
 
@@ -150,8 +162,11 @@ func writeCookieIfNecessary(w *sessionResponseWriter) {
 		HttpOnly: true,
 		Path:     "/",
 		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(w.sessionManager.absoluteExpiration),
-		MaxAge:   int(w.sessionManager.absoluteExpiration / time.Second),
+	}
+	// "remember me" sessions survive closing the browser, others use a session cookie
+	if session.remember {
+		cookie.Expires = session.createdAt.Add(w.sessionManager.rememberExpiration)
+		cookie.MaxAge = int(time.Until(cookie.Expires) / time.Second)
 	}
 	if w.request.TLS != nil || strings.EqualFold(w.request.Header.Get("X-Forwarded-Proto"), "https") {
 		cookie.Secure = true
@@ -1,10 +1,14 @@
 package backend
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
+	"explo/src/config"
 	"explo/src/discovery"
+	"explo/src/export"
 	"explo/src/models"
+	"explo/src/sources"
 	"explo/src/util"
 	"fmt"
 	"image"
@@ -24,13 +28,7 @@ const lbAPIBase = "https://api.listenbrainz.org/1"
 // PlaylistTrack represents a single track fetched from any playlist source.
 // Replaces the raw [][4]string{title, artist, album, coverURL} pattern
 // with named fields and adds MainArtist for accurate matching in music servers.
-type PlaylistTrack struct {
-	Title      string
-	Artist     string
-	MainArtist string
-	Album      string
-	CoverURL   string
-}
+type PlaylistTrack = sources.Track
 
 // validPlaylistTypes is derived from playlistDefs — no manual sync needed.
 var validPlaylistTypes = func() map[string]bool {
@@ -48,16 +46,21 @@ func isValidPlaylistID(t string) bool {
 	return validPlaylistTypes[t] || customIDRe.MatchString(t)
 }
 
-// handleGetPlaylist serves the tracklist cache written by explo during its last run.
-// Returns an empty track list if no cache exists yet.
+// handleGetPlaylist serves the tracklist cache written by explo during its last run, for the
+// profile in the optional "profile" parameter. Returns an empty track list if no cache exists yet.
 func (s *Server) handleGetPlaylist(w http.ResponseWriter, r *http.Request) {
 	playlistType := r.URL.Query().Get("type")
 	if !isValidPlaylistID(playlistType) {
 		http.Error(w, "unknown playlist type", http.StatusBadRequest)
 		return
 	}
+	profile := r.URL.Query().Get("profile")
+	if profile != "" && !config.ValidProfileID(profile) {
+		http.Error(w, "invalid profile", http.StatusBadRequest)
+		return
+	}
 
-	cachePath := filepath.Join(s.cfg.WebDataDir, "cache", playlistType+".json")
+	cachePath := filepath.Join(s.cfg.WebDataDir, "cache", PlaylistBase(playlistType, profile)+".json")
 	if raw, err := os.ReadFile(cachePath); err == nil {
 		w.Header().Set("Content-Type", "application/json")
 		if _, err := w.Write(raw); err != nil {
@@ -74,36 +77,78 @@ func (s *Server) handleGetPlaylist(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// ── LB fallback ──────────────────────────────────────────────────────────────
+// ExportsDir is where runs write every playlist export for download from the web UI
+func ExportsDir(dataDir string) string {
+	return filepath.Join(dataDir, "exports")
+}
+
+// PlaylistBase names the files a run writes for a playlist (exports, the web UI's tracklist
+// cache), runs for a profile get their own so they don't overwrite the main config's.
+// Profile IDs can't contain ".", so names can't clash.
+func PlaylistBase(playlist, profile string) string {
+	if profile == "" {
+		return playlist
+	}
+	return profile + "." + playlist
+}
 
-func fetchOnRepeatTracks(username string) ([]PlaylistTrack, error) {
-	tracks, err := discovery.FetchTopRecordings(util.NewHttp(util.HttpClientConfig{Timeout: 30}), username)
+// handleExportPlaylist serves the M3U8/XSPF/JSPF export written by the playlist's last run,
+// for the profile in the optional "profile" parameter.
+func (s *Server) handleExportPlaylist(w http.ResponseWriter, r *http.Request) {
+	playlistType := r.URL.Query().Get("type")
+	if !isValidPlaylistID(playlistType) {
+		http.Error(w, "unknown playlist type", http.StatusBadRequest)
+		return
+	}
+	profile := r.URL.Query().Get("profile")
+	if profile != "" && !config.ValidProfileID(profile) {
+		http.Error(w, "invalid profile", http.StatusBadRequest)
+		return
+	}
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	contentType, ok := export.ContentTypes[format]
+	if !ok {
+		http.Error(w, "unknown export format", http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(ExportsDir(s.cfg.WebDataDir), export.FileName(PlaylistBase(playlistType, profile), format)))
 	if err != nil {
-		return nil, err
+		http.Error(w, "no export yet, run the playlist first", http.StatusNotFound)
+		return
 	}
-	return modelTracksToPlaylistTracks(tracks), nil
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "failed to read export", http.StatusInternalServerError)
+		return
+	}
+
+	name := playlistType
+	if cp := GetCustomPlaylist(s.cfg.WebDataDir, playlistType); cp != nil && cp.Name != "" {
+		name = cp.Name
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.FileName(name, format)))
+	http.ServeContent(w, r, "", info.ModTime(), f)
 }
 
-func fetchMostRecentLBPlaylist(username, playlistType string) ([]PlaylistTrack, error) {
-	tracks, err := discovery.FetchMostRecentPlaylistByType(util.NewHttp(util.HttpClientConfig{Timeout: 30}), username, playlistType)
+// ── LB fallback ──────────────────────────────────────────────────────────────
+
+func fetchOnRepeatTracks(ctx context.Context, username string) ([]PlaylistTrack, error) {
+	tracks, err := discovery.FetchTopRecordings(ctx, util.NewHttp(util.HttpClientConfig{Timeout: 30}), username)
 	if err != nil {
 		return nil, err
 	}
-	return modelTracksToPlaylistTracks(tracks), nil
+	return sources.FromModels(tracks), nil
 }
 
-func modelTracksToPlaylistTracks(tracks []*models.Track) []PlaylistTrack {
-	out := make([]PlaylistTrack, len(tracks))
-	for i, t := range tracks {
-		out[i] = PlaylistTrack{
-			Title:      t.CleanTitle,
-			Artist:     t.Artist,
-			MainArtist: t.MainArtist,
-			Album:      t.Album,
-			CoverURL:   t.CoverURL,
-		}
+func fetchMostRecentLBPlaylist(ctx context.Context, username, playlistType string) ([]PlaylistTrack, error) {
+	tracks, err := discovery.FetchMostRecentPlaylistByType(ctx, util.NewHttp(util.HttpClientConfig{Timeout: 30}), username, playlistType)
+	if err != nil {
+		return nil, err
 	}
-	return out
+	return sources.FromModels(tracks), nil
 }
 
 // writePlaylistCache downloads cover art and writes a tracklist JSON for the web UI.
@@ -198,6 +243,7 @@ func (s *Server) handlePrefetchCovers(w http.ResponseWriter, r *http.Request) {
 
 	slog.Info("prefetch: starting", "user", body.User, "playlists", body.Playlists, "source", body.Source, "force_refresh", forceRefresh)
 	go func() {
+		ctx := context.Background() // outlives the request
 		for _, pt := range body.Playlists {
 			if !validPlaylistTypes[pt] {
 				slog.Warn("prefetch: unknown playlist type", "type", pt)
@@ -213,9 +259,9 @@ func (s *Server) handlePrefetchCovers(w http.ResponseWriter, r *http.Request) {
 			var tracks []PlaylistTrack
 			var err error
 			if pt == "on-repeat" {
-				tracks, err = fetchOnRepeatTracks(body.User)
+				tracks, err = fetchOnRepeatTracks(ctx, body.User)
 			} else {
-				tracks, err = fetchMostRecentLBPlaylist(body.User, pt)
+				tracks, err = fetchMostRecentLBPlaylist(ctx, body.User, pt)
 			}
 			if err != nil {
 				slog.Warn("prefetch: failed to fetch LB playlist", "type", pt, "err", err)
@@ -228,13 +274,15 @@ func (s *Server) handlePrefetchCovers(w http.ResponseWriter, r *http.Request) {
 }
 
 type cachedPrefetchTrack struct {
-	Rank       int    `json:"rank"`
-	Title      string `json:"title"`
-	Artist     string `json:"artist"`
-	MainArtist string `json:"mainArtist,omitempty"`
-	Release    string `json:"release"`
-	CoverURL   string `json:"coverUrl,omitempty"`
-	CoverPath  string `json:"coverPath,omitempty"`
+	Rank       int      `json:"rank"`
+	Title      string   `json:"title"`
+	Artist     string   `json:"artist"`
+	MainArtist string   `json:"mainArtist,omitempty"`
+	Release    string   `json:"release"`
+	CoverURL   string   `json:"coverUrl,omitempty"`
+	CoverPath  string   `json:"coverPath,omitempty"`
+	ISRCs      []string `json:"isrcs,omitempty"`
+	VideoID    string   `json:"videoId,omitempty"`
 }
 
 // writePreliminaryCache writes the track cache with remote cover URLs immediately.
@@ -242,7 +290,7 @@ type cachedPrefetchTrack struct {
 func writePreliminaryCache(cfgDir, playlistType string, tracks []PlaylistTrack) bool {
 	ct := make([]cachedPrefetchTrack, len(tracks))
 	for i, t := range tracks {
-		ct[i] = cachedPrefetchTrack{Rank: i + 1, Title: t.Title, Artist: t.Artist, MainArtist: t.MainArtist, Release: t.Album, CoverURL: t.CoverURL}
+		ct[i] = cachedPrefetchTrack{Rank: i + 1, Title: t.Title, Artist: t.Artist, MainArtist: t.MainArtist, Release: t.Album, CoverURL: t.CoverURL, ISRCs: t.ISRCs, VideoID: t.VideoID}
 	}
 	if !writeTrackCache(cfgDir, playlistType, ct) {
 		return false
@@ -262,7 +310,7 @@ func downloadAndCacheCovers(cfgDir, playlistType string, tracks []PlaylistTrack)
 	ct := make([]cachedPrefetchTrack, len(tracks))
 	for i, t := range tracks {
 		APIPath, coverPath := util.DownloadCover(t.CoverURL, coversDir)
-		ct[i] = cachedPrefetchTrack{Rank: i + 1, Title: t.Title, Artist: t.Artist, MainArtist: t.MainArtist, Release: t.Album, CoverURL: APIPath, CoverPath: coverPath}
+		ct[i] = cachedPrefetchTrack{Rank: i + 1, Title: t.Title, Artist: t.Artist, MainArtist: t.MainArtist, Release: t.Album, CoverURL: APIPath, CoverPath: coverPath, ISRCs: t.ISRCs, VideoID: t.VideoID}
 	}
 	if writeTrackCache(cfgDir, playlistType, ct) {
 		slog.Info("prefetch: cache updated", "playlist", playlistType, "covers", "local")
@@ -0,0 +1,196 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"explo/src/config"
+)
+
+// Profile is a person sharing this explo instance. Its .env file overrides keys of the
+// main config for its runs, so everyone gets playlists from their own ListenBrainz account
+// in their own media server account, while downloads are shared.
+type Profile struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`                        // PROFILE_NAME, falls back to the ID
+	ListenBrainzUser string `json:"listenbrainz_user,omitempty"` // LISTENBRAINZ_USER
+	SystemUser       string `json:"system_user,omitempty"`       // SYSTEM_USERNAME
+	Schedules        int    `json:"schedules"`                   // playlist schedules set in the profile
+}
+
+// profileTemplate is written for new profiles, keys left empty fall back to the main config
+const profileTemplate = `# Settings in this file override the main config for this profile's runs.
+# Empty keys use the value from the main config.
+
+PROFILE_NAME=
+
+# ListenBrainz account to get recommendations for
+LISTENBRAINZ_USER=
+
+# Media server account the playlists are created for
+SYSTEM_USERNAME=
+SYSTEM_PASSWORD=
+API_KEY=
+
+# Playlist schedules for this profile, same format as in the main config
+# WEEKLY_EXPLORATION_SCHEDULE=15 00 * * 2
+# WEEKLY_EXPLORATION_FLAGS=--playlist=weekly-exploration
+`
+
+// loadProfiles reads every profile in <WEB_DATA_PATH>/profiles, sorted by ID
+func loadProfiles(dataDir string) []Profile {
+	entries, err := os.ReadDir(config.ProfilesDir(dataDir))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("failed to read profiles", "err", err.Error())
+		}
+		return nil
+	}
+	var out []Profile
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".env")
+		if e.IsDir() || !ok || !config.ValidProfileID(id) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(config.ProfilesDir(dataDir), e.Name()))
+		if err != nil {
+			slog.Warn("failed to read profile", "profile", id, "err", err.Error())
+			continue
+		}
+		values := parseEnvText(string(data))
+		p := Profile{
+			ID:               id,
+			Name:             values["PROFILE_NAME"],
+			ListenBrainzUser: values["LISTENBRAINZ_USER"],
+			SystemUser:       values["SYSTEM_USERNAME"],
+			Schedules:        len(collectPlaylistRuns(values)),
+		}
+		if p.Name == "" {
+			p.Name = id
+		}
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func profileExists(dataDir, id string) bool {
+	if !config.ValidProfileID(id) {
+		return false
+	}
+	_, err := os.Stat(config.ProfilePath(dataDir, id))
+	return err == nil
+}
+
+// handleProfiles lists profiles (GET) or creates one from the template (POST).
+func (s *Server) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		profiles := loadProfiles(s.cfg.WebDataDir)
+		if profiles == nil {
+			profiles = []Profile{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(profiles); err != nil {
+			slog.Warn("failed encoding profiles to response", "err", err.Error())
+		}
+
+	case http.MethodPost:
+		var req struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		req.ID = strings.ToLower(strings.TrimSpace(req.ID))
+		if !config.ValidProfileID(req.ID) {
+			http.Error(w, "profile id must be lowercase letters, digits, - or _ (max 40)", http.StatusBadRequest)
+			return
+		}
+		if err := os.MkdirAll(config.ProfilesDir(s.cfg.WebDataDir), 0755); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		f, err := os.OpenFile(config.ProfilePath(s.cfg.WebDataDir, req.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			if errors.Is(err, os.ErrExist) {
+				http.Error(w, "profile already exists", http.StatusConflict)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, err = f.WriteString(profileTemplate)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		slog.Info("profile created", "profile", req.ID)
+		s.audit(r, auditProfileSave, "created "+req.ID)
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleProfile returns (GET), replaces (PUT) or deletes (DELETE) a profile's .env text.
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !profileExists(s.cfg.WebDataDir, id) {
+		http.Error(w, "profile not found", http.StatusNotFound)
+		return
+	}
+	path := config.ProfilePath(s.cfg.WebDataDir, id)
+
+	switch r.Method {
+	case http.MethodGet:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := w.Write(data); err != nil {
+			slog.Error("failed writing http response", "msg", err.Error())
+		}
+
+	case http.MethodPut:
+		data, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.audit(r, auditProfileSave, "updated "+id)
+		s.reloadSchedules()
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodDelete:
+		if err := os.Remove(path); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		slog.Info("profile deleted", "profile", id)
+		s.audit(r, auditProfileSave, "deleted "+id)
+		s.reloadSchedules()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,56 @@
+package backend
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"explo/src/report"
+)
+
+// reportsDir is where the CLI writes a JSON report for every run
+func (s *Server) reportsDir() string {
+	return filepath.Join(s.cfg.WebDataDir, "runs")
+}
+
+// handleListRuns returns summaries of past runs, newest first.
+func (s *Server) handleListRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	runs, err := report.List(s.reportsDir())
+	if err != nil {
+		http.Error(w, "failed to list runs: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(runs); err != nil {
+		slog.Warn("failed encoding runs to response", "err", err.Error())
+	}
+}
+
+// handleGetRun returns a single run report including per-track outcomes.
+func (s *Server) handleGetRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rep, err := report.Load(s.reportsDir(), r.PathValue("id"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "run not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rep); err != nil {
+		slog.Warn("failed encoding run report to response", "err", err.Error())
+	}
+}
@@ -0,0 +1,75 @@
+package backend
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+
+	"explo/src/progress"
+)
+
+// collectRunProgress forwards JSON progress events from the child's progress pipe to SSE
+// subscribers, typed by the event's type (phase, track or progress). The metrics event
+// sent at exit is added to the run totals instead.
+func (s *Server) collectRunProgress(pr *os.File, done chan<- struct{}) {
+	defer close(done)
+	defer func() {
+		if cerr := pr.Close(); cerr != nil {
+			slog.Error("failed to close progress reader", "err", cerr.Error())
+		}
+	}()
+
+	scanner := bufio.NewScanner(pr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var ev progress.Event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil || ev.Type == "" {
+			slog.Debug("ignoring malformed progress event", "line", line)
+			continue
+		}
+		if ev.Type == progress.TypeMetrics {
+			var text string
+			if err := json.Unmarshal(ev.Metrics, &text); err != nil {
+				slog.Warn("failed to parse run metrics", "err", err.Error())
+			} else if err := s.runTotals.Merge(text); err != nil {
+				slog.Warn("failed to add run metrics", "err", err.Error())
+			}
+			continue
+		}
+		s.appendRunEvent(runEvent{typ: ev.Type, data: line})
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Warn("failed to read run progress", "err", err.Error())
+	}
+}
+
+// appendRunEvent broadcasts a typed event. Phase and track events are kept so clients that
+// reconnect mid-run can rebuild track states; byte progress is only sent live.
+func (s *Server) appendRunEvent(event runEvent) {
+	s.manualRun.mu.Lock()
+	if event.typ != progress.TypeProgress {
+		s.manualRun.events = append(s.manualRun.events, event)
+	}
+	subscribers := make([]chan runEvent, 0, len(s.manualRun.subscribers))
+	for ch := range s.manualRun.subscribers {
+		subscribers = append(subscribers, ch)
+	}
+	s.manualRun.mu.Unlock()
+
+	for _, ch := range subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func closeProgressPipe(pr, pw *os.File) {
+	if err := pr.Close(); err != nil {
+		slog.Warn("failed to close progress reader", "err", err.Error())
+	}
+	if err := pw.Close(); err != nil {
+		slog.Warn("failed to close progress writer", "err", err.Error())
+	}
+}
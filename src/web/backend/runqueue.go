@@ -0,0 +1,176 @@
+package backend
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"explo/src/report"
+)
+
+// Runs from the UI default to a higher priority than scheduled ones, so a user
+// clicking "run" doesn't wait behind a backlog of cron jobs.
+const (
+	priorityScheduled = 0
+	priorityManual    = 10
+)
+
+const (
+	runSourceManual   = "manual"
+	runSourceSchedule = "schedule"
+)
+
+// QueuedRun is a run waiting for (or holding) the global run lock.
+// Scheduled and manual runs share one queue so they never overlap.
+type QueuedRun struct {
+	ID        string     `json:"id"`
+	Label     string     `json:"label"`  // playlist or job name
+	Source    string     `json:"source"` // manual | schedule
+	Priority  int        `json:"priority"`
+	Profile   string     `json:"profile,omitempty"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	ReportID  string     `json:"report_id,omitempty"` // set when the run starts, see /api/ui/runs/{id}
+
+	args   []string
+	job    string                    // scheduled job name, a job is only queued once
+	before func(ctx context.Context) // setup done once the run holds the lock (pre-run command, cache refresh)
+}
+
+// QueuedRunResponse is returned by POST /api/ui/run.
+type QueuedRunResponse struct {
+	Run      QueuedRun `json:"run"`
+	Position int       `json:"position"` // 0 if the run started right away
+}
+
+// RunQueueResponse is returned by GET /api/ui/run/queue.
+type RunQueueResponse struct {
+	Active  *QueuedRun  `json:"active,omitempty"`
+	Pending []QueuedRun `json:"pending"`
+}
+
+// enqueueRun adds run to the queue, ordered by priority then arrival, and starts it if
+// nothing else is running. Returns nil if run is a scheduled job that's already waiting.
+func (s *Server) enqueueRun(run *QueuedRun) *QueuedRunResponse {
+	s.manualRun.mu.Lock()
+	if run.job != "" {
+		for _, q := range s.manualRun.queue {
+			if q.job == run.job {
+				s.manualRun.mu.Unlock()
+				return nil
+			}
+		}
+	}
+	s.manualRun.nextID++
+	run.ID = fmt.Sprintf("%d", s.manualRun.nextID)
+	run.QueuedAt = time.Now()
+	s.manualRun.queue = append(s.manualRun.queue, run)
+	slices.SortStableFunc(s.manualRun.queue, func(a, b *QueuedRun) int {
+		if c := cmp.Compare(b.Priority, a.Priority); c != 0 {
+			return c
+		}
+		return a.QueuedAt.Compare(b.QueuedAt)
+	})
+	s.manualRun.mu.Unlock()
+
+	s.dispatchRuns()
+
+	s.manualRun.mu.Lock()
+	defer s.manualRun.mu.Unlock()
+	resp := &QueuedRunResponse{Run: *run}
+	if i := slices.Index(s.manualRun.queue, run); i >= 0 {
+		resp.Position = i + 1
+		slog.Info("run queued", "id", run.ID, "label", run.Label, "source", run.Source, "position", resp.Position)
+	}
+	return resp
+}
+
+// dispatchRuns starts the next queued run if the run lock is free. Called whenever a run
+// is queued or finishes.
+func (s *Server) dispatchRuns() {
+	s.manualRun.mu.Lock()
+	if s.manualRun.running || len(s.manualRun.queue) == 0 {
+		s.manualRun.mu.Unlock()
+		return
+	}
+	run := s.manualRun.queue[0]
+	s.manualRun.queue = s.manualRun.queue[1:]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	run.StartedAt = &now
+	run.ReportID = report.NewID(run.Label, now)
+	s.manualRun.running = true
+	s.manualRun.active = run
+	s.manualRun.cancel = cancel
+	s.manualRun.exitCode = nil
+	s.manualRun.logs = nil
+	s.manualRun.events = nil
+	s.manualRun.mu.Unlock()
+
+	go func() {
+		if run.before != nil {
+			run.before(ctx)
+		}
+		if ctx.Err() != nil {
+			s.finishRun(130) // stopped before the CLI started, same code the CLI exits with
+			return
+		}
+		args := append(slices.Clip(run.args), "--run-id", run.ReportID)
+		if err := s.startRun(ctx, args); err != nil {
+			slog.Error("run failed to start", "id", run.ID, "label", run.Label, "err", err.Error())
+		}
+	}()
+}
+
+// cancelQueuedRun removes a run that hasn't started yet
+func (s *Server) cancelQueuedRun(id string) bool {
+	s.manualRun.mu.Lock()
+	defer s.manualRun.mu.Unlock()
+
+	i := slices.IndexFunc(s.manualRun.queue, func(q *QueuedRun) bool { return q.ID == id })
+	if i < 0 {
+		return false
+	}
+	slog.Info("queued run cancelled", "id", id, "label", s.manualRun.queue[i].Label)
+	s.manualRun.queue = slices.Delete(s.manualRun.queue, i, i+1)
+	return true
+}
+
+// handleGetRunQueue returns the active run and the pending ones in the order they'll start.
+func (s *Server) handleGetRunQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.manualRun.mu.Lock()
+	resp := RunQueueResponse{Pending: make([]QueuedRun, 0, len(s.manualRun.queue))}
+	if s.manualRun.active != nil {
+		active := *s.manualRun.active
+		resp.Active = &active
+	}
+	for _, q := range s.manualRun.queue {
+		resp.Pending = append(resp.Pending, *q)
+	}
+	s.manualRun.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Warn("failed encoding run queue to response", "err", err.Error())
+	}
+}
+
+// handleCancelQueuedRun removes a pending run. The active run is stopped via /api/ui/run/stop.
+func (s *Server) handleCancelQueuedRun(w http.ResponseWriter, r *http.Request) {
+	if !s.cancelQueuedRun(r.PathValue("id")) {
+		http.Error(w, "run is not queued", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
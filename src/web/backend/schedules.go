@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"explo/src/config"
+)
+
+// PlaylistRun is a scheduled playlist generation, read from a *_SCHEDULE/*_FLAGS pair in .env
+type PlaylistRun struct {
+	Name     string   // env prefix, e.g. WEEKLY_EXPLORATION
+	Schedule string   // cron expression
+	Flags    []string // CLI flags for the run
+	Priority int      // from *_PRIORITY, higher runs first when runs are queued
+	Profile  string   // profile the run belongs to, empty for the main config
+}
+
+// Playlist returns the value of --playlist in the run's flags, if any
+func (r PlaylistRun) Playlist() string {
+	for i, f := range r.Flags {
+		if v, ok := strings.CutPrefix(f, "--playlist="); ok {
+			return v
+		}
+		if f == "--playlist" && i+1 < len(r.Flags) {
+			return r.Flags[i+1]
+		}
+	}
+	return ""
+}
+
+// ScheduledRun is returned by GET /api/ui/schedules.
+type ScheduledRun struct {
+	Name     string     `json:"name"`
+	Playlist string     `json:"playlist,omitempty"`
+	Schedule string     `json:"schedule"`
+	Flags    string     `json:"flags"`
+	Priority int        `json:"priority"`
+	Profile  string     `json:"profile,omitempty"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	LastRun  *time.Time `json:"last_run,omitempty"`
+}
+
+// preRunTimeout bounds PRE_RUN_COMMAND so a stuck package mirror can't block scheduled runs
+const preRunTimeout = 5 * time.Minute
+
+// launchEnv is the environment explo was started with. It's captured during package init,
+// before cleanenv copies the .env file into the process environment, so keys removed from
+// the file later don't linger.
+var launchEnv = func() map[string]string {
+	out := map[string]string{}
+	for _, e := range os.Environ() {
+		if k, v, ok := strings.Cut(e, "="); ok {
+			out[k] = v
+		}
+	}
+	return out
+}()
+
+// loadPlaylistRuns collects every *_SCHEDULE with a non-empty value from the .env file and
+// the profiles. Schedules set in the launch environment (e.g. docker-compose) take precedence
+// over the file, they don't apply to profiles.
+func (s *Server) loadPlaylistRuns() []PlaylistRun {
+	values := map[string]string{}
+	if data, err := os.ReadFile(s.cfg.WebEnvPath); err == nil {
+		values = parseEnvText(string(data))
+	}
+	for k, v := range launchEnv {
+		if v != "" && (strings.HasSuffix(k, "_SCHEDULE") || strings.HasSuffix(k, "_FLAGS") || strings.HasSuffix(k, "_PRIORITY")) {
+			values[k] = v
+		}
+	}
+	runs := collectPlaylistRuns(values)
+
+	for _, p := range loadProfiles(s.cfg.WebDataDir) {
+		data, err := os.ReadFile(config.ProfilePath(s.cfg.WebDataDir, p.ID))
+		if err != nil {
+			continue
+		}
+		for _, run := range collectPlaylistRuns(parseEnvText(string(data))) {
+			run.Name = p.ID + "/" + run.Name
+			run.Flags = append([]string{"--profile", p.ID}, run.Flags...)
+			run.Profile = p.ID
+			runs = append(runs, run)
+		}
+	}
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Name < runs[j].Name })
+	return runs
+}
+
+// collectPlaylistRuns returns the runs defined by *_SCHEDULE, *_FLAGS and *_PRIORITY keys
+func collectPlaylistRuns(values map[string]string) []PlaylistRun {
+	var runs []PlaylistRun
+	for key, schedule := range values {
+		job, ok := strings.CutSuffix(key, "_SCHEDULE")
+		if !ok || job == "" || strings.TrimSpace(schedule) == "" {
+			continue
+		}
+		priority := priorityScheduled
+		if v := values[job+"_PRIORITY"]; v != "" {
+			p, err := strconv.Atoi(v)
+			if err != nil {
+				slog.Warn("ignoring invalid schedule priority", "key", job+"_PRIORITY", "value", v)
+			} else {
+				priority = p
+			}
+		}
+		runs = append(runs, PlaylistRun{
+			Name:     job,
+			Schedule: strings.TrimSpace(schedule),
+			Flags:    strings.Fields(values[job+"_FLAGS"]),
+			Priority: priority,
+		})
+	}
+
+	// CRON_SCHEDULE was deprecated in v0.11.0, still honoured as a plain run without flags
+	if schedule := strings.TrimSpace(values["CRON_SCHEDULE"]); schedule != "" {
+		runs = append(runs, PlaylistRun{Name: "CRON", Schedule: schedule})
+	}
+	return runs
+}
+
+// reloadSchedules re-registers playlist generations and ListenBrainz syncs from the current .env
+func (s *Server) reloadSchedules() {
+	s.cronJobs.SyncPlaylistRuns(s.loadPlaylistRuns(), s.runScheduled)
+	s.cronJobs.SyncListenBrainzJobs(s.loadSyncJobs(), s.runSyncJob)
+}
+
+// runScheduled queues a playlist generation from the scheduler (or EXECUTE_ON_START).
+// Custom playlists get their track cache refreshed first, if it is due.
+func (s *Server) runScheduled(run PlaylistRun) {
+	label := run.Playlist()
+	if label == "" {
+		label = run.Name
+	}
+	queued := s.enqueueRun(&QueuedRun{
+		Label:    label,
+		Source:   runSourceSchedule,
+		Priority: run.Priority,
+		Profile:  run.Profile,
+		args:     append([]string{"--config", s.cfg.WebEnvPath}, run.Flags...),
+		job:      run.Name,
+		before: func(ctx context.Context) {
+			slog.Info("starting scheduled run", "job", run.Name, "flags", strings.Join(run.Flags, " "))
+			s.preRun(ctx)
+			if id := run.Playlist(); customIDRe.MatchString(id) {
+				if p := GetCustomPlaylist(s.cfg.WebDataDir, id); p != nil {
+					refreshCustomPlaylist(ctx, s.cfg.WebDataDir, *p)
+				}
+			}
+		},
+	})
+	if queued == nil {
+		slog.Warn("skipping scheduled run, it is already queued", "job", run.Name)
+	}
+}
+
+// preRun runs PRE_RUN_COMMAND (the Docker image uses it to upgrade yt-dlp before each run)
+func (s *Server) preRun(ctx context.Context) {
+	if s.cfg.PreRunCmd == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, preRunTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "sh", "-c", s.cfg.PreRunCmd).CombinedOutput()
+	if err != nil {
+		slog.Warn("pre-run command failed", "err", err.Error(), "output", string(out))
+	}
+}
+
+// runOnStart triggers EXECUTE_ON_START with START_FLAGS once the server is up
+func (s *Server) runOnStart() {
+	if !s.cfg.RunOnStart {
+		return
+	}
+	go s.runScheduled(PlaylistRun{Name: "START", Flags: strings.Fields(s.cfg.StartFlags)})
+}
+
+// handleGetSchedules returns scheduled playlist generations with their next and last run times.
+func (s *Server) handleGetSchedules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	runs := s.cronJobs.PlaylistRuns()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(runs); err != nil {
+		slog.Warn("failed encoding schedules to response", "err", err.Error())
+	}
+}
@@ -13,13 +13,19 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"explo/src/config"
+	"explo/src/metrics"
+	"explo/src/progress"
+	"explo/src/util"
 	"explo/src/web"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Option is a value/label pair for select-type fields.
@@ -51,8 +57,10 @@ type runEvent struct {
 
 // RunStatus is returned by GET /api/run/status.
 type RunStatus struct {
-	Running  bool `json:"running"`
-	ExitCode *int `json:"exit_code,omitempty"`
+	Running  bool       `json:"running"`
+	ExitCode *int       `json:"exit_code,omitempty"`
+	Active   *QueuedRun `json:"active,omitempty"`
+	Queued   int        `json:"queued"`
 }
 
 type manualRunState struct {
@@ -61,7 +69,11 @@ type manualRunState struct {
 	cancel      context.CancelFunc
 	exitCode    *int
 	logs        []string
+	events      []runEvent // typed progress events (phase, track) of the current run
 	subscribers map[chan runEvent]struct{}
+	active      *QueuedRun   // holds the run lock
+	queue       []*QueuedRun // waiting, in start order
+	nextID      int
 }
 
 func newManualRunState() manualRunState {
@@ -73,24 +85,37 @@ type Server struct {
 	mux            *http.ServeMux
 	server         *http.Server
 	authStore      *AuthStore
+	tokens         *TokenStore
+	auditLog       *AuditLog
 	cronJobs       *Jobs
 	sessionManager *SessionManager
 	manualRun      manualRunState
+	registry       *prometheus.Registry
+	runTotals      *metrics.Totals
 }
 
 func NewServer(cfg config.ServerConfig) *Server {
 	sessionManager := NewSessionManager(
-		NewInMemorySessionStore(),
+		newSessionStore(cfg),
 		1*time.Hour,
-		7*(24*time.Hour),
+		time.Duration(cfg.SessionIdleHours)*time.Hour,
+		time.Duration(cfg.SessionLifetimeDays)*(24*time.Hour),
+		time.Duration(cfg.SessionRememberDays)*(24*time.Hour),
 		"session",
 	)
 
+	tokens := NewTokenStore(cfg.WebDataDir)
 	authStore := NewAuthStore(
 		cfg.Username,
 		cfg.Password,
 		sessionManager,
+		tokens,
 	)
+	authStore.trusted = parseTrustedProxies(cfg.AuthTrustedProxies)
+	authStore.proxy = newProxyAuth(cfg.AuthProxyHeader, authStore.trusted)
+	authStore.limiter = newLoginLimiter(cfg.LoginMaxAttempts, time.Duration(cfg.LoginLockoutSeconds)*time.Second)
+	authStore.audit = NewAuditLog(cfg.WebDataDir)
+	authStore.oidc = newOIDCAuth(cfg)
 
 	cronJobs := NewJobs()
 
@@ -103,9 +128,13 @@ func NewServer(cfg config.ServerConfig) *Server {
 			Handler: sessionManager.Handle(mux),
 		},
 		authStore:      authStore,
+		tokens:         tokens,
+		auditLog:       authStore.audit,
 		cronJobs:       cronJobs,
 		sessionManager: sessionManager,
 		manualRun:      newManualRunState(),
+		registry:       prometheus.NewRegistry(),
+		runTotals:      metrics.NewTotals(filepath.Join(cfg.WebDataDir, "metrics.prom")),
 	}
 
 	s.registerRoutes()
@@ -115,6 +144,8 @@ func NewServer(cfg config.ServerConfig) *Server {
 func (s *Server) Start() error {
 	s.initServerLog()
 	s.startJobs()
+	s.registerMetrics()
+	s.runOnStart()
 	coversDir := filepath.Join(s.cfg.WebDataDir, "cache", "covers")
 	if _, err := os.Stat(coversDir); os.IsNotExist(err) {
 		s.PrefetchCovers()
@@ -179,10 +210,16 @@ func (s *Server) startJobs() {
 		slog.Warn("failed to register cover cleanup job", "err", err.Error())
 	}
 
-	if err := s.cronJobs.RegisterCustomPlaylistRefresh(s.cfg.WebDataDir, s.cfg.WebEnvPath); err != nil {
+	if err := s.cronJobs.RegisterHTTPCacheCleanup("0 3 * * *", util.DefaultCache()); err != nil {
+		slog.Warn("failed to register http cache cleanup job", "err", err.Error())
+	}
+
+	if err := s.cronJobs.RegisterCustomPlaylistRefresh("0 4 * * *", s.cfg.WebDataDir); err != nil {
 		slog.Warn("failed to register custom playlist refresh job", "err", err.Error())
 	}
 
+	s.reloadSchedules()
+
 	s.cronJobs.Start()
 }
 
@@ -243,6 +280,7 @@ func (s *Server) registerRoutes() {
 	s.mux.Handle("/api/ui/config/raw", s.authStore.RequireAuth(http.HandlerFunc(s.handleGetConfigRaw)))
 	s.mux.Handle("/api/ui/config/reset", s.authStore.RequireAuth(http.HandlerFunc(s.handleResetConfig)))
 	s.mux.Handle("/api/ui/config/schedules", s.authStore.RequireAuth(http.HandlerFunc(s.handleSaveSchedule)))
+	s.mux.Handle("/api/ui/schedules", s.authStore.RequireAuth(http.HandlerFunc(s.handleGetSchedules)))
 	s.mux.Handle("/api/ui/config/path-template", s.authStore.RequireAuth(http.HandlerFunc(s.handleSavePathTemplate)))
 	s.mux.Handle("/api/ui/config/enrich-metadata", s.authStore.RequireAuth(http.HandlerFunc(s.handleSaveEnrichMetadata)))
 
@@ -268,10 +306,28 @@ func (s *Server) registerRoutes() {
 	s.mux.Handle("/api/ui/run/events", s.authStore.RequireAuth(http.HandlerFunc(s.handleRunEvents)))
 	s.mux.Handle("/api/ui/run/stop", s.authStore.RequireAuth(http.HandlerFunc(s.handleStopRun)))
 	s.mux.Handle("/api/ui/run/status", s.authStore.RequireAuth(http.HandlerFunc(s.handleRunStatus)))
+	s.mux.Handle("/api/ui/run/queue", s.authStore.RequireAuth(http.HandlerFunc(s.handleGetRunQueue)))
+	s.mux.HandleFunc("/api/ui/run/queue/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.authStore.RequireAuth(http.HandlerFunc(s.handleCancelQueuedRun)).ServeHTTP(w, r)
+	})
+
+	s.mux.Handle("/api/ui/runs", s.authStore.RequireAuth(http.HandlerFunc(s.handleListRuns)))
+	s.mux.Handle("/api/ui/runs/{id}", s.authStore.RequireAuth(http.HandlerFunc(s.handleGetRun)))
 
 	s.mux.Handle("/api/ui/logs", s.authStore.RequireAuth(http.HandlerFunc(s.handleGetLog)))
 	s.mux.Handle("/api/ui/playlists", s.authStore.RequireAuth(http.HandlerFunc(s.handleGetPlaylist)))
 	s.mux.Handle("/api/ui/playlists/prefetch", s.authStore.RequireAuth(http.HandlerFunc(s.handlePrefetchCovers)))
+	s.mux.HandleFunc("/api/ui/playlists/export", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.authStore.RequireAuth(http.HandlerFunc(s.handleExportPlaylist)).ServeHTTP(w, r)
+	})
 
 	// TODO: Uncomment when jeffs branch is in
 	// custom playlists: GET list, POST import (same path); per-ID actions under prefix
@@ -285,6 +341,13 @@ func (s *Server) registerRoutes() {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		}
 	})
+	s.mux.HandleFunc("/api/ui/custom-playlists/upload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.authStore.RequireAuth(http.HandlerFunc(s.handleUploadCustomPlaylist)).ServeHTTP(w, r)
+	})
 	// ID-specific routes: DELETE /api/ui/custom-playlists/{id} and POST .../{id}/refresh
 	s.mux.HandleFunc("/api/ui/custom-playlists/{id}/refresh", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -301,14 +364,34 @@ func (s *Server) registerRoutes() {
 		s.authStore.RequireAuth(http.HandlerFunc(s.handleDeleteCustomPlaylist)).ServeHTTP(w, r)
 	})
 
+	s.mux.Handle("/api/ui/tokens", s.authStore.RequireAuth(http.HandlerFunc(s.handleTokens)))
+	s.mux.HandleFunc("/api/ui/tokens/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.authStore.RequireAuth(http.HandlerFunc(s.handleRevokeToken)).ServeHTTP(w, r)
+	})
+
+	s.mux.Handle("/api/ui/profiles", s.authStore.RequireAuth(http.HandlerFunc(s.handleProfiles)))
+	s.mux.Handle("/api/ui/profiles/{id}", s.authStore.RequireAuth(http.HandlerFunc(s.handleProfile)))
+
+	s.mux.Handle("/api/ui/audit", s.authStore.RequireAuth(http.HandlerFunc(s.handleGetAudit)))
+
 	s.mux.Handle("/api/ui/logout", s.authStore.RequireAuth(http.HandlerFunc(s.handleLogout)))
 
 	// public/special routes
 	s.mux.HandleFunc("/api/ui/csrf", s.csrfHandler)
 	s.mux.HandleFunc("/api/ui/login", s.handleLogin)
 	s.mux.HandleFunc("/api/ui/auth/status", s.handleAuthStatus)
+	s.mux.HandleFunc("/api/ui/auth/providers", s.handleAuthProviders)
+	s.mux.HandleFunc("/api/ui/oidc/login", s.handleOIDCLogin)
+	s.mux.HandleFunc("/api/ui/oidc/callback", s.handleOIDCCallback)
 	s.mux.HandleFunc("/api/ui/background-art", s.handleBackgroundArt)
 	s.mux.HandleFunc("/api/ui/setup-status", s.handleSetupStatus)
+	if s.cfg.Metrics {
+		s.mux.Handle("/metrics", s.metricsAuth(s.handleMetrics()))
+	}
 
 	coversDir := filepath.Join(s.cfg.WebDataDir, "cache", "covers")
 	s.mux.Handle("/api/covers/", http.StripPrefix("/api/covers/", http.FileServer(http.Dir(coversDir))))
@@ -354,9 +437,7 @@ func (s *Server) handleSetupStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleAuthStatus(w http.ResponseWriter, r *http.Request) {
-	sess := s.sessionManager.GetSession(r)
-	auth, _ := sess.Get("authenticated").(bool)
-	if !auth {
+	if !s.authStore.Authenticated(r) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -378,21 +459,36 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	username := r.FormValue("username")
 	password := r.FormValue("password")
 
+	ip := s.authStore.trusted.clientIP(r)
+	limiter := s.authStore.limiter
+	if wait := limiter.locked(ip, username); wait > 0 {
+		s.auditLog.Record(AuditEvent{Action: auditLoginLocked, User: username, IP: ip})
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, "too many failed logins, try again later", http.StatusTooManyRequests)
+		return
+	}
+
 	if !s.authStore.CompareCreds(username, password) {
+		slog.Warn("failed login", "user", username, "ip", ip)
+		s.auditLog.Record(AuditEvent{Action: auditLoginFailed, User: username, IP: ip})
+		if limiter.fail(ip, username) {
+			slog.Warn("login locked out after repeated failures", "user", username, "ip", ip)
+		}
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
+	limiter.succeed(ip, username)
 	sess := s.sessionManager.GetSession(r)
-	sess.Put("authenticated", true)
-	sess.Put("username", username)
-	//s.sessionManager.Migrate(sess)
-	slog.Info("successful login", "user", username)
+	s.authStore.login(r, sess, username, "password")
+	s.sessionManager.Remember(sess, r.FormValue("remember") == "true")
 }
 
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	s.audit(r, auditLogout, "")
 	sess := s.sessionManager.GetSession(r)
 	sess.Delete("authenticated")
 	sess.Delete("username")
+	s.sessionManager.Remember(sess, false)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -413,6 +509,7 @@ func (s *Server) csrfHandler(w http.ResponseWriter, r *http.Request) {
 	session := s.sessionManager.GetSession(r)
 
 	token, _ := session.Get("csrf_token").(string)
+	session.keep() // the browser sends the token back with this session
 
 	w.Header().Set("Content-Type", "application/json")
 
@@ -508,23 +605,25 @@ func (s *Server) handleSaveConfig(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, auditConfigSave, "")
+	s.reloadSchedules()
 	w.WriteHeader(http.StatusOK)
 }
 
-// handleResetConfig resets all settings and restarts the container.
+// handleResetConfig resets all settings and clears playlist schedules. Runs pick up the reset
+// .env right away, the server's own settings (UI login, SSO, paths) only after a restart,
+// which the response tells the caller.
 func (s *Server) handleResetConfig(w http.ResponseWriter, r *http.Request) {
 	if err := os.WriteFile(s.cfg.WebEnvPath, web.SampleEnv, 0600); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	go func() {
-		time.Sleep(300 * time.Millisecond)
-		if err := syscall.Kill(1, syscall.SIGTERM); err != nil {
-			slog.Warn("failed to kill process", "msg", err.Error())
-		}
-
-	}()
+	s.audit(r, auditConfigReset, "")
+	s.reloadSchedules()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]bool{"restart_required": true}); err != nil {
+		slog.Warn("failed encoding reset response", "err", err.Error())
+	}
 }
 
 // handleSaveSchedule updates a single playlist's schedule in the .env file.
@@ -548,7 +647,7 @@ func (s *Server) handleSaveSchedule(w http.ResponseWriter, r *http.Request) {
 		envPrefix = def.EnvPrefix
 		defaultFlags = def.DefaultFlags
 	} else if customIDRe.MatchString(body.Name) {
-		envPrefix = customEnvPrefix(body.Name)
+		envPrefix = config.CustomEnvPrefix(body.Name)
 		defaultFlags = "--playlist " + body.Name
 	} else {
 		http.Error(w, "unknown playlist name", http.StatusBadRequest)
@@ -580,6 +679,8 @@ func (s *Server) handleSaveSchedule(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, auditScheduleSave, fmt.Sprintf("%s=%s", envPrefix+"_SCHEDULE", updates[envPrefix+"_SCHEDULE"]))
+	s.reloadSchedules()
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -600,6 +701,7 @@ func (s *Server) handleSavePathTemplate(w http.ResponseWriter, r *http.Request)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, auditConfigSave, "PATH_TEMPLATE")
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -624,6 +726,7 @@ func (s *Server) handleSaveEnrichMetadata(w http.ResponseWriter, r *http.Request
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, auditConfigSave, "ENRICH_TRACK_METADATA")
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -739,6 +842,8 @@ func (s *Server) handleWizardStep1(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.reloadSchedules()
+	s.audit(r, auditConfigSave, "setup wizard: playlists")
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -793,6 +898,7 @@ func (s *Server) handleWizardStep2(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, auditConfigSave, "setup wizard: media system")
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -846,6 +952,7 @@ func (s *Server) handleWizardStep3(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, auditConfigSave, "setup wizard: downloaders")
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -883,32 +990,55 @@ func (s *Server) handleBrowse(w http.ResponseWriter, r *http.Request) {
 
 // ── Manual run ─────────────────────────────────────────────────────────────
 
-var errRunAlreadyStarted = errors.New("run already in progress")
-
-// handleRun starts an explo run in the background. Clients follow output via /api/ui/run/events.
+// handleRun queues an explo run, which starts right away if nothing else is running.
+// Clients follow output via /api/ui/run/events.
 func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(1 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
 		http.Error(w, "bad form data", http.StatusBadRequest)
 		return
 	}
 
-	args := buildArgs(r.FormValue("playlist"), r.FormValue("download_mode"),
+	priority := priorityManual
+	if v := r.FormValue("priority"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "priority must be a number", http.StatusBadRequest)
+			return
+		}
+		priority = p
+	}
+
+	playlist := r.FormValue("playlist")
+	args := buildArgs(playlist, r.FormValue("download_mode"),
 		r.FormValue("persist") == "false", r.FormValue("exclude_local") == "true",
 		s.cfg.WebEnvPath)
 
-	if err := s.startRun(args); err != nil {
-		if errors.Is(err, errRunAlreadyStarted) {
-			http.Error(w, "a run is already in progress", http.StatusConflict)
+	profile := r.FormValue("profile")
+	if profile != "" {
+		if !profileExists(s.cfg.WebDataDir, profile) {
+			http.Error(w, "unknown profile", http.StatusBadRequest)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		args = append(args, "--profile", profile)
 	}
 
+	label := playlist
+	if label == "" {
+		label = "default"
+	}
+	resp := s.enqueueRun(&QueuedRun{
+		Label:    label,
+		Source:   runSourceManual,
+		Priority: priority,
+		Profile:  profile,
+		args:     args,
+	})
+	s.audit(r, auditRunStart, strings.Join(args[2:], " "))
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
-	if err := json.NewEncoder(w).Encode(s.currentRunStatus()); err != nil {
-		slog.Warn("failed to encode current run status", "msg", err.Error())
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Warn("failed to encode queued run", "msg", err.Error())
 	}
 }
 
@@ -934,8 +1064,9 @@ func (s *Server) triggerLibraryRefresh() {
 	}()
 }
 
-func (s *Server) startRun(args []string) error {
-	ctx, cancel := context.WithCancel(context.Background())
+// startRun launches the CLI for a run claimed by dispatchRuns. ctx is the run's context,
+// cancelled by handleStopRun.
+func (s *Server) startRun(ctx context.Context, args []string) error {
 	cmd := exec.CommandContext(ctx, s.cfg.ExploPath, args...)
 	// Strip WEB_UI from env so the child process runs normally, not as web server.
 	env := make([]string, 0, len(os.Environ()))
@@ -945,47 +1076,37 @@ func (s *Server) startRun(args []string) error {
 		}
 	}
 	cmd.Env = env
+	// Stopping a run sends SIGTERM first so the child can cancel pending
+	// downloads and remove temp files; it is killed if it doesn't exit in time.
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = 30 * time.Second
 
 	pr, pw, err := os.Pipe()
 	if err != nil {
-		cancel()
+		s.finishRun(1)
 		return fmt.Errorf("failed to create pipe: %w", err)
 	}
 	cmd.Stdout = pw
 	cmd.Stderr = pw
 
-	lf, err := s.openRunLog()
+	// Progress events go over a separate pipe (fd 3 in the child) so they don't mix with log lines
+	ppr, ppw, err := os.Pipe()
 	if err != nil {
-		slog.Warn("failed to open run log", "err", err.Error())
+		slog.Warn("failed to create progress pipe, run will only stream logs", "err", err.Error())
+	} else {
+		cmd.ExtraFiles = []*os.File{ppw}
+		cmd.Env = append(cmd.Env, progress.EnvFD+"=3")
 	}
 
-	s.manualRun.mu.Lock()
-	if s.manualRun.running {
-		s.manualRun.mu.Unlock()
-		cancel()
-		if err := pr.Close(); err != nil {
-			slog.Warn("failed to close file reader", "err", err.Error())
-		}
-
-		if err := pw.Close(); err != nil {
-			slog.Warn("failed to close file writer", "err", err.Error())
-		}
-		if lf != nil {
-			if err := pw.Close(); err != nil {
-				slog.Warn("failed to close file writer", "err", err.Error())
-			}
-		}
-		return errRunAlreadyStarted
+	lf, err := s.openRunLog()
+	if err != nil {
+		slog.Warn("failed to open run log", "err", err.Error())
 	}
-	s.manualRun.running = true
-	s.manualRun.cancel = cancel
-	s.manualRun.exitCode = nil
-	s.manualRun.logs = nil
-	s.manualRun.mu.Unlock()
 
 	if err := cmd.Start(); err != nil {
 		s.finishRun(1)
-		cancel()
 		if err := pr.Close(); err != nil {
 			slog.Warn("failed to close file reader", "err", err.Error())
 		}
@@ -998,6 +1119,9 @@ func (s *Server) startRun(args []string) error {
 				slog.Warn("failed to close run log", "err", err.Error())
 			}
 		}
+		if ppr != nil {
+			closeProgressPipe(ppr, ppw)
+		}
 		return fmt.Errorf("failed to start explo: %w", err)
 	}
 
@@ -1006,11 +1130,25 @@ func (s *Server) startRun(args []string) error {
 		slog.Warn("failed to close file writer", "err", err.Error())
 	}
 
-	go s.collectRunOutput(cmd, pr, lf)
+	progressDone := make(chan struct{})
+	if ppr != nil {
+		if err := ppw.Close(); err != nil {
+			slog.Warn("failed to close progress writer", "err", err.Error())
+		}
+		go s.collectRunProgress(ppr, progressDone)
+	} else {
+		close(progressDone)
+	}
+
+	go s.collectRunOutput(cmd, pr, lf, ppr, progressDone)
 	return nil
 }
 
-func (s *Server) collectRunOutput(cmd *exec.Cmd, pr *os.File, lf *os.File) {
+// progressDrainTimeout is how long a finished run's progress events (the metrics event is
+// sent last) are read before the stream is cut off, in case something else still holds it
+const progressDrainTimeout = 5 * time.Second
+
+func (s *Server) collectRunOutput(cmd *exec.Cmd, pr *os.File, lf *os.File, ppr *os.File, progressDone <-chan struct{}) {
 	defer func() {
 		if cerr := pr.Close(); cerr != nil {
 			slog.Error("failed to close source file", "err", cerr.Error())
@@ -1046,6 +1184,13 @@ func (s *Server) collectRunOutput(cmd *exec.Cmd, pr *os.File, lf *os.File) {
 	if cmd.ProcessState != nil {
 		code = cmd.ProcessState.ExitCode()
 	}
+
+	if ppr != nil {
+		if err := ppr.SetReadDeadline(time.Now().Add(progressDrainTimeout)); err != nil && !errors.Is(err, os.ErrClosed) {
+			slog.Warn("failed to set progress read deadline", "err", err.Error())
+		}
+	}
+	<-progressDone
 	s.finishRun(code)
 }
 
@@ -1073,7 +1218,12 @@ func (s *Server) currentRunStatus() RunStatus {
 		code := *s.manualRun.exitCode
 		exitCode = &code
 	}
-	return RunStatus{Running: s.manualRun.running, ExitCode: exitCode}
+	var active *QueuedRun
+	if s.manualRun.active != nil {
+		run := *s.manualRun.active
+		active = &run
+	}
+	return RunStatus{Running: s.manualRun.running, ExitCode: exitCode, Active: active, Queued: len(s.manualRun.queue)}
 }
 
 func (s *Server) handleRunStatus(w http.ResponseWriter, r *http.Request) {
@@ -1108,8 +1258,13 @@ func (s *Server) finishRun(code int) {
 	done := runEvent{typ: "done", data: fmt.Sprintf("%d", code)}
 
 	s.manualRun.mu.Lock()
+	if s.manualRun.cancel != nil {
+		s.manualRun.cancel()
+	}
+	recordRun(s.manualRun.active, code)
 	s.manualRun.running = false
 	s.manualRun.cancel = nil
+	s.manualRun.active = nil
 	s.manualRun.exitCode = &code
 	subscribers := make([]chan runEvent, 0, len(s.manualRun.subscribers))
 	for ch := range s.manualRun.subscribers {
@@ -1125,10 +1280,14 @@ func (s *Server) finishRun(code int) {
 		}
 		close(ch)
 	}
+
+	// release the run lock to the next queued run
+	s.dispatchRuns()
 }
 
-// handleRunEvents streams the current in-memory run log, then follows new lines
-// until the active run exits. Safe to reconnect after a browser refresh.
+// handleRunEvents streams the current in-memory run log and progress events, then follows
+// new ones until the active run exits. Log lines are untyped SSE messages, progress events
+// use the event types phase, track and progress. Safe to reconnect after a browser refresh.
 func (s *Server) handleRunEvents(w http.ResponseWriter, r *http.Request) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -1156,6 +1315,7 @@ func (s *Server) handleRunEvents(w http.ResponseWriter, r *http.Request) {
 	ch := make(chan runEvent, 256)
 	s.manualRun.mu.Lock()
 	lines := append([]string(nil), s.manualRun.logs...)
+	events := append([]runEvent(nil), s.manualRun.events...)
 	running := s.manualRun.running
 	var exitCode *int
 	if s.manualRun.exitCode != nil {
@@ -1170,6 +1330,9 @@ func (s *Server) handleRunEvents(w http.ResponseWriter, r *http.Request) {
 	for _, line := range lines {
 		sendEvent("", line)
 	}
+	for _, ev := range events {
+		sendEvent(ev.typ, ev.data)
+	}
 	if !running {
 		if exitCode != nil {
 			sendEvent("done", fmt.Sprintf("%d", *exitCode))
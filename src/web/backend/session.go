@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
@@ -13,44 +14,60 @@ import (
 
 type Session struct {
 	createdAt      time.Time
+	lastActivity   time.Time
+	remember       bool // "remember me": outlives the browser and isn't subject to idle expiration
 	mu             sync.Mutex
 	id             string
 	data           map[string]any
+	dirty          bool // changed since it was read, written back at the end of the request
+	stored         bool // in the store, new sessions aren't until they change
 }
 
-/* type SessionStore interface {
+type SessionStore interface {
 	read(id string) (*Session, error)
 	write(session *Session) error
 	destroy(id string) error
-	gc(absoluteExpiration time.Duration) error
-} */
+	gc(expired func(*Session) bool) error
+}
+
+var errSessionNotFound = errors.New("session not found")
+
+// activityResolution limits how often a session's last activity is written back
+const activityResolution = time.Minute
 
 type SessionManager struct {
-	store              *InMemorySessionStore
-	//idleExpiration     time.Duration // Could be used if project grows (session expiry based on user activity)
+	store              SessionStore
+	idleExpiration     time.Duration // 0 disables idle expiration
 	absoluteExpiration time.Duration
+	rememberExpiration time.Duration // absolute expiration of "remember me" sessions
 	cookieName         string
 }
 
 type sessionContextKey struct {}
 
 func newSession() *Session {
+	now := time.Now()
 	return &Session{
 		id:             generateToken(),
 		data:           map[string]any{"csrf_token": generateToken()},
-		createdAt:      time.Now(),
+		createdAt:      now,
+		lastActivity:   now,
 	}
 }
 
 func NewSessionManager(
-	store *InMemorySessionStore,
+	store SessionStore,
 	gcInterval,
-	absoluteExpiration time.Duration,
+	idleExpiration,
+	absoluteExpiration,
+	rememberExpiration time.Duration,
 	cookieName string) *SessionManager {
 
 	m := &SessionManager{
 		store:              store,
+		idleExpiration:     idleExpiration,
 		absoluteExpiration: absoluteExpiration,
+		rememberExpiration: rememberExpiration,
 		cookieName:         cookieName,
 	}
 
@@ -63,19 +80,33 @@ func (m *SessionManager) gc(d time.Duration) {
 	ticker := time.NewTicker(d)
 
 	for range ticker.C {
-		if err := m.store.gc(m.absoluteExpiration); err != nil {
-			panic("garbage collection failed")
+		if err := m.store.gc(m.expired); err != nil {
+			slog.Error("session garbage collection failed", "msg", err.Error())
 		}
 	}
 }
 
+// lifetime is how long a session lasts from its creation
+func (m *SessionManager) lifetime(session *Session) time.Duration {
+	if session.remember {
+		return m.rememberExpiration
+	}
+	return m.absoluteExpiration
+}
+
+func (m *SessionManager) expired(session *Session) bool {
+	if time.Since(session.createdAt) > m.lifetime(session) {
+		return true
+	}
+	return !session.remember && m.idleExpiration > 0 && time.Since(session.lastActivity) > m.idleExpiration
+}
+
 func (m *SessionManager) validate(session *Session) bool {
-	if time.Since(session.createdAt) > m.absoluteExpiration {
-        
+	if m.expired(session) {
+
         // Delete the session from the store
-		err := m.store.destroy(session.id)
-		if err != nil {
-			panic(err)
+		if err := m.store.destroy(session.id); err != nil {
+			slog.Error("Failed to delete expired session", "msg", err.Error())
 		}
 
 		return false
@@ -91,17 +122,21 @@ func (m *SessionManager) start(r *http.Request) (*Session, *http.Request) {
 	cookie, err := r.Cookie(m.cookieName)
 	if err == nil {
 		session, err = m.store.read(cookie.Value)
-		if err != nil {
+		if err != nil && !errors.Is(err, errSessionNotFound) {
 			slog.Error("Failed to read session from store", "msg", err.Error())
 		}
+		if session != nil {
+			session.stored = true
+		}
 	}
 
-    // Generate a new session if no session exists or it expired
+    // Generate a new session if no session exists or it expired. It's only stored once a
+    // handler puts something in it, so scrapers and health checks don't fill the store.
 	if session == nil || !m.validate(session) {
 		session = newSession()
-		if err := m.store.write(session); err != nil {
-			panic("failed writing session")
-		}
+	} else if time.Since(session.lastActivity) > activityResolution {
+		session.lastActivity = time.Now()
+		session.dirty = true
 	}
 
     // Attach session to context
@@ -121,8 +156,40 @@ func (m *SessionManager) Migrate(session *Session) error {
 	}
 
 	session.id = generateToken()
+	session.createdAt = time.Now()
+	session.lastActivity = session.createdAt
 
-	return m.store.write(session)
+	if err := m.store.write(session); err != nil {
+		return err
+	}
+	session.stored = true
+	return nil
+}
+
+// Remember turns "remember me" on or off for a session
+func (m *SessionManager) Remember(session *Session, remember bool) {
+	session.remember = remember
+	session.dirty = true
+}
+
+// save writes the session back if a handler changed it, new sessions are written the first
+// time they change
+func (m *SessionManager) save(session *Session) {
+	if !session.dirty {
+		return
+	}
+	if err := m.store.write(session); err != nil {
+		slog.Error("Failed writing session", "msg", err.Error())
+		return
+	}
+	session.stored = true
+}
+
+// keep stores a new session at the end of the request even if it didn't change
+func (s *Session) keep() {
+	if !s.stored {
+		s.dirty = true
+	}
 }
 
 
@@ -144,10 +211,14 @@ func (s *Session) Get(key string) any {
 
 func (s *Session) Put(key string, value any) {
 	s.data[key] = value
+	s.dirty = true
 }
 
 func (s *Session) Delete(key string) {
-	delete(s.data, key)
+	if _, ok := s.data[key]; ok {
+		delete(s.data, key)
+		s.dirty = true
+	}
 }
 
 func (m *SessionManager) GetSession(r *http.Request) *Session {
@@ -0,0 +1,143 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"explo/src/config"
+)
+
+// newSessionStore returns the store picked with SESSION_STORE, falling back to memory
+// if the session file can't be opened
+func newSessionStore(cfg config.ServerConfig) SessionStore {
+	if cfg.SessionStore == "memory" {
+		return NewInMemorySessionStore()
+	}
+	if cfg.SessionStore != "file" {
+		slog.Warn("unknown SESSION_STORE, using file", "value", cfg.SessionStore)
+	}
+	if err := os.MkdirAll(cfg.WebDataDir, 0755); err != nil {
+		slog.Warn("session store unavailable, logins won't survive restarts", "err", err.Error())
+		return NewInMemorySessionStore()
+	}
+	store, err := NewBoltSessionStore(filepath.Join(cfg.WebDataDir, "sessions.db"))
+	if err != nil {
+		slog.Warn("session store unavailable, logins won't survive restarts", "err", err.Error())
+		return NewInMemorySessionStore()
+	}
+	return store
+}
+
+var sessionsBucket = []byte("sessions")
+
+// BoltSessionStore keeps sessions in a bbolt file, so logins survive restarts.
+// Sessions are keyed by a hash of their ID, the cookie value itself is never stored.
+type BoltSessionStore struct {
+	db *bolt.DB
+}
+
+// storedSession is the on-disk form of a Session
+type storedSession struct {
+	CreatedAt    time.Time      `json:"created_at"`
+	LastActivity time.Time      `json:"last_activity"`
+	Remember     bool           `json:"remember,omitempty"`
+	Data         map[string]any `json:"data"`
+}
+
+func NewBoltSessionStore(path string) (*BoltSessionStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise session store: %w", err)
+	}
+	return &BoltSessionStore{db: db}, nil
+}
+
+func (s *BoltSessionStore) read(id string) (*Session, error) {
+	var stored storedSession
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(hashToken(id)))
+		if data == nil {
+			return errSessionNotFound
+		}
+		return json.Unmarshal(data, &stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stored.Data == nil {
+		stored.Data = map[string]any{}
+	}
+	return &Session{
+		id:           id,
+		createdAt:    stored.CreatedAt,
+		lastActivity: stored.LastActivity,
+		remember:     stored.Remember,
+		data:         stored.Data,
+	}, nil
+}
+
+func (s *BoltSessionStore) write(session *Session) error {
+	data, err := json.Marshal(storedSession{
+		CreatedAt:    session.createdAt,
+		LastActivity: session.lastActivity,
+		Remember:     session.remember,
+		Data:         session.data,
+	})
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(hashToken(session.id)), data)
+	})
+	if err == nil {
+		session.dirty = false
+	}
+	return err
+}
+
+func (s *BoltSessionStore) destroy(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(hashToken(id)))
+	})
+}
+
+func (s *BoltSessionStore) gc(expired func(*Session) bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		var stale [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var stored storedSession
+			if err := json.Unmarshal(v, &stored); err != nil {
+				stale = append(stale, k) // unreadable, drop it
+				return nil
+			}
+			if expired(&Session{createdAt: stored.CreatedAt, lastActivity: stored.LastActivity, remember: stored.Remember}) {
+				stale = append(stale, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,341 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"explo/src/config"
+)
+
+// ── Trusted proxy header ───────────────────────────────────────────────────
+
+// trustedProxies are the reverse proxies whose forwarding headers are believed, from AUTH_TRUSTED_PROXIES
+type trustedProxies []netip.Prefix
+
+func parseTrustedProxies(cidrs string) trustedProxies {
+	var t trustedProxies
+	for _, c := range strings.Split(cidrs, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		if !strings.Contains(c, "/") { // single address
+			if addr, err := netip.ParseAddr(c); err == nil {
+				c = netip.PrefixFrom(addr, addr.BitLen()).String()
+			}
+		}
+		prefix, err := netip.ParsePrefix(c)
+		if err != nil {
+			slog.Warn("ignoring invalid trusted proxy", "value", c, "err", err.Error())
+			continue
+		}
+		t = append(t, prefix.Masked())
+	}
+	return t
+}
+
+func (t trustedProxies) contains(addr netip.Addr) bool {
+	return slices.ContainsFunc(t, func(prefix netip.Prefix) bool { return prefix.Contains(addr) })
+}
+
+// remoteAddr returns the address of the direct peer
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only followed through
+// trusted proxies, so clients can't pick their own address.
+func (t trustedProxies) clientIP(r *http.Request) string {
+	addr, ok := remoteAddr(r)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !t.contains(addr) {
+		return addr.String()
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !t.contains(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+// proxyAuth trusts a username header (e.g. Remote-User from Authelia or Authentik) set by
+// a reverse proxy, but only on requests coming straight from one of the trusted networks.
+type proxyAuth struct {
+	header  string
+	trusted trustedProxies
+}
+
+func newProxyAuth(header string, trusted trustedProxies) *proxyAuth {
+	if header == "" {
+		return nil
+	}
+	if len(trusted) == 0 {
+		slog.Warn("AUTH_PROXY_HEADER is set without AUTH_TRUSTED_PROXIES, proxy header auth is disabled")
+		return nil
+	}
+	return &proxyAuth{header: header, trusted: trusted}
+}
+
+// user returns the username the proxy sent, or "" if the request isn't from a trusted proxy
+func (p *proxyAuth) user(r *http.Request) string {
+	if p == nil {
+		return ""
+	}
+	user := strings.TrimSpace(r.Header.Get(p.header))
+	if user == "" {
+		return ""
+	}
+	addr, ok := remoteAddr(r)
+	if !ok {
+		return ""
+	}
+	if !p.trusted.contains(addr) {
+		slog.Debug("ignoring auth header from untrusted address", "header", p.header, "addr", addr.String())
+		return ""
+	}
+	return user
+}
+
+// ── OIDC ───────────────────────────────────────────────────────────────────
+
+// oidcAuth logs users in with the authorization code flow (with PKCE). The provider is
+// discovered on first use, so explo still starts while the identity provider is down.
+type oidcAuth struct {
+	cfg     config.ServerConfig
+	allowed []string
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func newOIDCAuth(cfg config.ServerConfig) *oidcAuth {
+	if cfg.OIDCIssuer == "" || cfg.OIDCClientID == "" {
+		return nil
+	}
+	o := &oidcAuth{cfg: cfg}
+	for _, u := range strings.Split(cfg.OIDCAllowedUsers, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			o.allowed = append(o.allowed, u)
+		}
+	}
+	return o
+}
+
+func (o *oidcAuth) setup(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.provider != nil {
+		return nil
+	}
+	provider, err := oidc.NewProvider(ctx, o.cfg.OIDCIssuer)
+	if err != nil {
+		return fmt.Errorf("failed to discover OIDC provider %s: %w", o.cfg.OIDCIssuer, err)
+	}
+	o.provider = provider
+	o.oauth = &oauth2.Config{
+		ClientID:     o.cfg.OIDCClientID,
+		ClientSecret: o.cfg.OIDCClientSecret,
+		RedirectURL:  o.cfg.OIDCRedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       strings.Fields(o.cfg.OIDCScopes),
+	}
+	o.verifier = provider.Verifier(&oidc.Config{ClientID: o.cfg.OIDCClientID})
+	return nil
+}
+
+// redirectURL returns the callback URL, derived from the request when OIDC_REDIRECT_URL is unset
+func (o *oidcAuth) redirectURL(r *http.Request) string {
+	if o.cfg.OIDCRedirectURL != "" {
+		return o.cfg.OIDCRedirectURL
+	}
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/api/ui/oidc/callback"
+}
+
+type oidcClaims struct {
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Nonce             string `json:"nonce"`
+}
+
+func (c oidcClaims) username() string {
+	switch {
+	case c.PreferredUsername != "":
+		return c.PreferredUsername
+	case c.Email != "":
+		return c.Email
+	}
+	return c.Subject
+}
+
+// permitted checks OIDC_ALLOWED_USERS against the subject, and the email when the provider
+// verified it. preferred_username isn't checked, users can often pick or change it.
+func (o *oidcAuth) permitted(c oidcClaims) bool {
+	if len(o.allowed) == 0 {
+		return true
+	}
+	for _, id := range o.allowed {
+		if c.Subject != "" && id == c.Subject {
+			return true
+		}
+		if c.EmailVerified && c.Email != "" && strings.EqualFold(id, c.Email) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleOIDCLogin redirects to the identity provider.
+func (s *Server) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	o := s.authStore.oidc
+	if o == nil {
+		http.Error(w, "OIDC is not configured", http.StatusNotFound)
+		return
+	}
+	if err := o.setup(r.Context()); err != nil {
+		slog.Error("oidc login failed", "err", err.Error())
+		http.Redirect(w, r, "/?sso_error=provider", http.StatusFound)
+		return
+	}
+
+	state, nonce, verifier := generateToken(), generateToken(), oauth2.GenerateVerifier()
+	sess := s.sessionManager.GetSession(r)
+	sess.Put("oidc_state", state)
+	sess.Put("oidc_nonce", nonce)
+	sess.Put("oidc_verifier", verifier)
+
+	cfg := *o.oauth
+	cfg.RedirectURL = o.redirectURL(r)
+	http.Redirect(w, r, cfg.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), http.StatusFound)
+}
+
+// handleOIDCCallback finishes the authorization code flow and logs the session in.
+func (s *Server) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	o := s.authStore.oidc
+	if o == nil {
+		http.Error(w, "OIDC is not configured", http.StatusNotFound)
+		return
+	}
+	sess := s.sessionManager.GetSession(r)
+	state, _ := sess.Get("oidc_state").(string)
+	nonce, _ := sess.Get("oidc_nonce").(string)
+	verifier, _ := sess.Get("oidc_verifier").(string)
+	sess.Delete("oidc_state")
+	sess.Delete("oidc_nonce")
+	sess.Delete("oidc_verifier")
+
+	var user string // known once the ID token is verified
+	fail := func(reason string, err error) {
+		slog.Warn("oidc login failed", "reason", reason, "err", err.Error())
+		s.auditLog.Record(AuditEvent{
+			Action: auditLoginFailed,
+			User:   user,
+			IP:     s.authStore.trusted.clientIP(r),
+			Detail: "oidc: " + reason,
+		})
+		http.Redirect(w, r, "/?sso_error="+url.QueryEscape(reason), http.StatusFound)
+	}
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		fail("denied", errors.New(e+": "+q.Get("error_description")))
+		return
+	}
+	if state == "" || q.Get("state") != state {
+		fail("state", errors.New("state mismatch, login expired or was started elsewhere"))
+		return
+	}
+	if err := o.setup(r.Context()); err != nil {
+		fail("provider", err)
+		return
+	}
+
+	cfg := *o.oauth
+	cfg.RedirectURL = o.redirectURL(r)
+	token, err := cfg.Exchange(r.Context(), q.Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		fail("exchange", err)
+		return
+	}
+	rawID, ok := token.Extra("id_token").(string)
+	if !ok {
+		fail("exchange", errors.New("no id_token in token response"))
+		return
+	}
+	idToken, err := o.verifier.Verify(r.Context(), rawID)
+	if err != nil {
+		fail("token", err)
+		return
+	}
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		fail("token", err)
+		return
+	}
+	if claims.Nonce != nonce {
+		fail("token", errors.New("nonce mismatch"))
+		return
+	}
+	user = claims.username()
+	if !o.permitted(claims) {
+		fail("forbidden", fmt.Errorf("user %q is not in OIDC_ALLOWED_USERS", claims.username()))
+		return
+	}
+
+	s.authStore.login(r, sess, claims.username(), "oidc")
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+type authProvidersResponse struct {
+	Password bool `json:"password"`
+	OIDC     bool `json:"oidc"`
+}
+
+// handleAuthProviders tells the login page which sign-in options to show. Public — no auth required.
+func (s *Server) handleAuthProviders(w http.ResponseWriter, r *http.Request) {
+	resp := authProvidersResponse{
+		Password: s.cfg.Username != "" && s.cfg.Password != "",
+		OIDC:     s.authStore.oidc != nil,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Warn("failed encoding auth providers to response", "err", err.Error())
+	}
+}
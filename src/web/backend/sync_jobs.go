@@ -0,0 +1,92 @@
+package backend
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"explo/src/config"
+)
+
+// syncJobTimeout bounds a ListenBrainz sync so a hung media server can't pile up processes
+const syncJobTimeout = 15 * time.Minute
+
+// syncJobFlags maps the schedule keys of the ListenBrainz sync jobs to their CLI flag.
+// They aren't *_SCHEDULE keys on purpose: syncs are short and frequent, so they skip the
+// run queue and PRE_RUN_COMMAND, and keep polling while a long download run is going.
+var syncJobFlags = map[string]string{
+	"FEEDBACK_SYNC_CRON": "--sync-feedback",
+	"LISTEN_SYNC_CRON":   "--sync-listens",
+}
+
+// SyncJob is a scheduled ListenBrainz sync for the main config or a profile
+type SyncJob struct {
+	Name     string // schedule key, prefixed with the profile
+	Schedule string
+	Flags    []string
+}
+
+// loadSyncJobs collects the sync schedules from the .env file, the launch environment and
+// the profiles
+func (s *Server) loadSyncJobs() []SyncJob {
+	values := map[string]string{}
+	if data, err := os.ReadFile(s.cfg.WebEnvPath); err == nil {
+		values = parseEnvText(string(data))
+	}
+	for key := range syncJobFlags {
+		if v := launchEnv[key]; v != "" {
+			values[key] = v
+		}
+	}
+	jobs := collectSyncJobs(values, "")
+	for _, p := range loadProfiles(s.cfg.WebDataDir) {
+		data, err := os.ReadFile(config.ProfilePath(s.cfg.WebDataDir, p.ID))
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, collectSyncJobs(parseEnvText(string(data)), p.ID)...)
+	}
+	return jobs
+}
+
+func collectSyncJobs(values map[string]string, profile string) []SyncJob {
+	var jobs []SyncJob
+	for key, flag := range syncJobFlags {
+		schedule := strings.TrimSpace(values[key])
+		if schedule == "" {
+			continue
+		}
+		job := SyncJob{Name: key, Schedule: schedule, Flags: []string{flag}}
+		if profile != "" {
+			job.Name = profile + "/" + key
+			job.Flags = append([]string{"--profile", profile}, job.Flags...)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// runSyncJob runs a sync in its own CLI process, next to any queued run
+func (s *Server) runSyncJob(job SyncJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncJobTimeout)
+	defer cancel()
+
+	args := append([]string{"--config", s.cfg.WebEnvPath}, job.Flags...)
+	cmd := exec.CommandContext(ctx, s.cfg.ExploPath, args...)
+	env := make([]string, 0, len(os.Environ()))
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, "WEB_UI=") {
+			env = append(env, e)
+		}
+	}
+	cmd.Env = env
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		slog.Warn("listenbrainz sync failed", "job", job.Name, "err", err.Error(), "output", string(out))
+		return
+	}
+	slog.Debug("listenbrainz sync finished", "job", job.Name, "output", string(out))
+}
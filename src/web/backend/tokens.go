@@ -0,0 +1,265 @@
+package backend
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// API token scopes, each includes the ones before it
+const (
+	ScopeRead  = "read"  // GET routes
+	ScopeRun   = "run"   // start, stop and cancel runs
+	ScopeAdmin = "admin" // everything, including config and token management
+)
+
+var scopeLevels = map[string]int{ScopeRead: 1, ScopeRun: 2, ScopeAdmin: 3}
+
+const tokenPrefix = "explo_"
+
+// lastUsedInterval limits how often a token's last use is written to disk
+const lastUsedInterval = time.Minute
+
+// APIToken is a long-lived credential for scripts and automations (Home Assistant etc.),
+// sent as "Authorization: Bearer <token>". Only a SHA-256 hash of the token is stored.
+type APIToken struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Scope     string     `json:"scope"`
+	Hint      string     `json:"hint"` // last characters of the token, to tell tokens apart
+	Hash      string     `json:"hash,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+}
+
+type TokenStore struct {
+	mu     sync.Mutex
+	path   string
+	tokens []*APIToken
+}
+
+// NewTokenStore loads tokens from <dataDir>/api-tokens.json
+func NewTokenStore(dataDir string) *TokenStore {
+	t := &TokenStore{path: filepath.Join(dataDir, "api-tokens.json")}
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("failed to read api tokens", "err", err.Error())
+		}
+		return t
+	}
+	if err := json.Unmarshal(data, &t.tokens); err != nil {
+		slog.Warn("failed to parse api tokens", "err", err.Error())
+	}
+	return t
+}
+
+// save writes the tokens to disk, callers hold t.mu
+func (t *TokenStore) save() error {
+	data, err := json.MarshalIndent(t.tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(t.path, data, 0600)
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create adds a token and returns it in plain text, it can't be recovered afterwards
+func (t *TokenStore) Create(name, scope string) (string, APIToken, error) {
+	if _, ok := scopeLevels[scope]; !ok {
+		return "", APIToken{}, fmt.Errorf("unknown scope %q", scope)
+	}
+	raw := tokenPrefix + generateToken()
+	tok := &APIToken{
+		ID:        generateToken()[:12],
+		Name:      name,
+		Scope:     scope,
+		Hint:      raw[len(raw)-4:],
+		Hash:      hashToken(raw),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens = append(t.tokens, tok)
+	if err := t.save(); err != nil {
+		t.tokens = t.tokens[:len(t.tokens)-1]
+		return "", APIToken{}, err
+	}
+	out := *tok
+	out.Hash = ""
+	return raw, out, nil
+}
+
+// Revoke deletes a token, returns false if it doesn't exist
+func (t *TokenStore) Revoke(id string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := slices.IndexFunc(t.tokens, func(tok *APIToken) bool { return tok.ID == id })
+	if i < 0 {
+		return false, nil
+	}
+	t.tokens = slices.Delete(t.tokens, i, i+1)
+	return true, t.save()
+}
+
+// List returns all tokens without their hashes
+func (t *TokenStore) List() []APIToken {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]APIToken, 0, len(t.tokens))
+	for _, tok := range t.tokens {
+		c := *tok
+		c.Hash = ""
+		out = append(out, c)
+	}
+	return out
+}
+
+// Authenticate returns the token matching raw, or nil
+func (t *TokenStore) Authenticate(raw string) *APIToken {
+	if !strings.HasPrefix(raw, tokenPrefix) {
+		return nil
+	}
+	hash := []byte(hashToken(raw))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tok := range t.tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(tok.Hash)) != 1 {
+			continue
+		}
+		now := time.Now().UTC()
+		if tok.LastUsed == nil || now.Sub(*tok.LastUsed) > lastUsedInterval {
+			tok.LastUsed = &now
+			if err := t.save(); err != nil {
+				slog.Warn("failed to save api token last use", "err", err.Error())
+			}
+		}
+		c := *tok
+		return &c
+	}
+	return nil
+}
+
+// bearerToken returns the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// requiredScope returns the token scope a request needs
+func requiredScope(r *http.Request) string {
+	p := r.URL.Path
+	switch {
+	case strings.HasPrefix(p, "/api/ui/tokens"), strings.HasPrefix(p, "/api/ui/config"), strings.HasPrefix(p, "/api/ui/profiles/"),
+		p == "/api/ui/audit", p == "/api/ui/browse", strings.HasPrefix(p, "/api/ui/logs"):
+		// tokens, the config and profiles hold credentials, the audit log who did what, browse
+		// lists the server's filesystem and logs contain URLs and usernames
+		return ScopeAdmin
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return ScopeRead
+	case p == "/api/ui/run", p == "/api/ui/run/stop", strings.HasPrefix(p, "/api/ui/run/queue/"):
+		return ScopeRun
+	}
+	return ScopeAdmin
+}
+
+func scopeAllows(have, need string) bool {
+	return scopeLevels[have] >= scopeLevels[need]
+}
+
+// ── Handlers ───────────────────────────────────────────────────────────────
+
+type createTokenRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+type createTokenResponse struct {
+	Token    string   `json:"token"` // only returned once
+	APIToken APIToken `json:"api_token"`
+}
+
+// handleTokens lists tokens (GET) or creates one (POST).
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.tokens.List()); err != nil {
+			slog.Warn("failed encoding api tokens to response", "err", err.Error())
+		}
+
+	case http.MethodPost:
+		var req createTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if _, ok := scopeLevels[req.Scope]; !ok {
+			http.Error(w, "scope must be read, run or admin", http.StatusBadRequest)
+			return
+		}
+		raw, tok, err := s.tokens.Create(req.Name, req.Scope)
+		if err != nil {
+			slog.Error("failed to create api token", "err", err.Error())
+			http.Error(w, "failed to create token", http.StatusInternalServerError)
+			return
+		}
+		slog.Info("api token created", "id", tok.ID, "name", tok.Name, "scope", tok.Scope)
+		s.audit(r, auditTokenCreate, tok.Name+" ("+tok.Scope+")")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(createTokenResponse{Token: raw, APIToken: tok}); err != nil {
+			slog.Warn("failed encoding api token to response", "err", err.Error())
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRevokeToken deletes a token.
+func (s *Server) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ok, err := s.tokens.Revoke(id)
+	if err != nil {
+		slog.Error("failed to revoke api token", "id", id, "err", err.Error())
+		http.Error(w, "failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "token not found", http.StatusNotFound)
+		return
+	}
+	slog.Info("api token revoked", "id", id)
+	s.audit(r, auditTokenRevoke, id)
+	w.WriteHeader(http.StatusNoContent)
+}
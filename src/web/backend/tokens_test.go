@@ -0,0 +1,65 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         string
+	}{
+		{http.MethodGet, "/api/ui/status", ScopeRead},
+		{http.MethodHead, "/api/ui/playlists/export", ScopeRead},
+		{http.MethodGet, "/api/ui/run/status", ScopeRead},
+		{http.MethodGet, "/api/ui/reports", ScopeRead},
+		{http.MethodPost, "/api/ui/run", ScopeRun},
+		{http.MethodPost, "/api/ui/run/stop", ScopeRun},
+		{http.MethodDelete, "/api/ui/run/queue/abc", ScopeRun},
+		{http.MethodPost, "/api/ui/custom-playlists", ScopeAdmin}, // writes not listed are admin
+		{http.MethodGet, "/api/ui/tokens", ScopeAdmin},
+		{http.MethodGet, "/api/ui/config", ScopeAdmin},
+		{http.MethodGet, "/api/ui/config/raw", ScopeAdmin},
+		{http.MethodGet, "/api/ui/profiles/alice", ScopeAdmin},
+		{http.MethodGet, "/api/ui/audit", ScopeAdmin},
+		{http.MethodGet, "/api/ui/browse", ScopeAdmin},
+		{http.MethodGet, "/api/ui/logs", ScopeAdmin},
+		{http.MethodGet, "/api/ui/logs/stream", ScopeAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if got := requiredScope(r); got != tt.want {
+				t.Errorf("requiredScope(%s %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		have, need string
+		want       bool
+	}{
+		{ScopeRead, ScopeRead, true},
+		{ScopeRead, ScopeRun, false},
+		{ScopeRead, ScopeAdmin, false},
+		{ScopeRun, ScopeRead, true},
+		{ScopeRun, ScopeRun, true},
+		{ScopeRun, ScopeAdmin, false},
+		{ScopeAdmin, ScopeRead, true},
+		{ScopeAdmin, ScopeRun, true},
+		{ScopeAdmin, ScopeAdmin, true},
+		{"", ScopeRead, false},
+		{"superuser", ScopeRead, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.have+"/"+tt.need, func(t *testing.T) {
+			if got := scopeAllows(tt.have, tt.need); got != tt.want {
+				t.Errorf("scopeAllows(%q, %q) = %v, want %v", tt.have, tt.need, got, tt.want)
+			}
+		})
+	}
+}
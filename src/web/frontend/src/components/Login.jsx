@@ -1,13 +1,32 @@
-import { useState } from 'react'
-import { login } from '../lib/api'
+import { useState, useEffect } from 'react'
+import { login, fetchAuthProviders } from '../lib/api'
+
+// Set by /api/ui/oidc/callback when single sign-on fails
+const SSO_ERRORS = {
+  denied: 'Sign-in was cancelled at the identity provider.',
+  forbidden: 'Your account is not allowed to use explo.',
+  provider: 'The identity provider could not be reached.',
+}
 
 const inputCls = 'w-full bg-surface border border-ui-border text-white rounded-[6px] px-3 py-2.5 text-[15px] outline-none focus:border-accent disabled:opacity-45 disabled:cursor-not-allowed transition-colors'
 
 export default function Login({ isFirstTime, bgUrl, bgLoaded, onBgLoad, onSuccess }) {
   const [username, setUsername] = useState('')
   const [password, setPassword] = useState('')
+  const [remember, setRemember] = useState(false)
   const [loading, setLoading] = useState(false)
   const [error, setError] = useState('')
+  const [providers, setProviders] = useState({ password: true, oidc: false })
+
+  useEffect(() => {
+    fetchAuthProviders().then(setProviders)
+    const params = new URLSearchParams(window.location.search)
+    const ssoError = params.get('sso_error')
+    if (ssoError) {
+      setError(SSO_ERRORS[ssoError] || 'Single sign-on failed, please try again.')
+      window.history.replaceState(null, '', window.location.pathname)
+    }
+  }, [])
 
   async function handleSubmit(e) {
     e.preventDefault()
@@ -15,7 +34,7 @@ export default function Login({ isFirstTime, bgUrl, bgLoaded, onBgLoad, onSucces
     setError('')
 
     try {
-      await login(username, password)
+      await login(username, password, remember)
       onSuccess()
     } catch {
       setError('Invalid credentials')
@@ -57,6 +76,21 @@ export default function Login({ isFirstTime, bgUrl, bgLoaded, onBgLoad, onSucces
           {isFirstTime ? 'Sign in to begin setup.' : 'Sign in to continue.'}
         </p>
 
+        {providers.oidc && (
+          <div className={providers.password ? 'mb-5' : ''}>
+            <a
+              href="/api/ui/oidc/login"
+              className="inline-block rounded-full border border-ui-border text-white px-8 py-3 text-[13px] font-bold tracking-[2px] uppercase no-underline hover:border-accent transition-colors"
+            >
+              Sign in with SSO
+            </a>
+            {!providers.password && error && (
+              <div className="text-danger text-[13px] mt-3">{error}</div>
+            )}
+          </div>
+        )}
+
+        {providers.password && (
         <form onSubmit={handleSubmit} className="flex flex-col gap-3">
           <input
             className={inputCls}
@@ -76,6 +110,11 @@ export default function Login({ isFirstTime, bgUrl, bgLoaded, onBgLoad, onSucces
             autoComplete="current-password"
           />
 
+          <label className="flex items-center gap-2 text-[13px] text-muted cursor-pointer select-none">
+            <input type="checkbox" checked={remember} onChange={(e) => setRemember(e.target.checked)} />
+            Remember me
+          </label>
+
           {error && (
             <div className="text-danger text-[13px]">{error}</div>
           )}
@@ -90,6 +129,7 @@ export default function Login({ isFirstTime, bgUrl, bgLoaded, onBgLoad, onSucces
             </button>
           </div>
         </form>
+        )}
       </div>
     </div>
   )
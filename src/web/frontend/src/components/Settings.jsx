@@ -6,7 +6,7 @@
  *
  * Sections:
  *   HomeSection    — scheduled playlists, manual run, live output
- *   ConfigSection  — raw .env editor, wizard re-run, reset
+ *   ConfigSection  — raw .env editor, API tokens, wizard re-run, reset
  *   LogsSection    — full server log viewer
  */
 
@@ -16,6 +16,8 @@ import {
   saveSchedule, startRun, stopRun, fetchRunStatus, fetchLogs,
   fetchCustomPlaylists, deleteCustomPlaylist, savePathTemplate, saveEnrichMetadata,
   fetchPathTemplatePresets, addPathTemplatePreset, deletePathTemplatePreset,
+  fetchTokens, createToken, revokeToken,
+  fetchProfiles, createProfile, fetchProfileRaw, saveProfile, deleteProfile,
 } from '../lib/api'
 import { parseSlogLine, cronToFields, highlightEnv } from '../lib/utils'
 import { fetchPlaylistTracks } from '../lib/listenbrainz'
@@ -36,7 +38,7 @@ const tabBtnCls = active =>
 // Fetches its own config on mount to initialise schedule state and locked keys.
 
 // Streams live run output from /api/ui/run/events
-function useSSE({ onLine, onDone }) {
+function useSSE({ onLine, onDone, onEvent }) {
   const abortRef = useRef(null)
 
   const connect = useCallback(async () => {
@@ -62,6 +64,7 @@ function useSSE({ onLine, onDone }) {
             if (l.startsWith('data: ')) data = l.slice(6)
           }
           if (ev === 'done') { onDone(parseInt(data)); return }
+          else if (ev) { try { onEvent?.(ev, JSON.parse(data)) } catch { /* malformed event */ } }
           else if (data) onLine(data)
         }
       }
@@ -70,7 +73,7 @@ function useSSE({ onLine, onDone }) {
     } finally {
       if (abortRef.current === controller) abortRef.current = null
     }
-  }, [onLine, onDone])
+  }, [onLine, onDone, onEvent])
 
   const disconnect = useCallback(() => {
     abortRef.current?.abort()
@@ -209,6 +212,8 @@ function HomeSection() {
   const [dlmode, setDlmode] = useState('normal')
   const [noPersist, setNoPersist] = useState(false)
   const [excludeLocal, setExcludeLocal] = useState(false)
+  const [profiles, setProfiles] = useState([])
+  const [profile, setProfile] = useState('')
 
   const [running, setRunning] = useState(false)
   const [status, setStatus] = useState('')
@@ -241,6 +246,7 @@ function HomeSection() {
       }
       setSchedules(s)
     })
+    fetchProfiles().then(setProfiles).catch(() => setProfiles([]))
   }, [])
 
   const onLine = useCallback(data => {
@@ -255,7 +261,12 @@ function HomeSection() {
     setRunning(false)
   }, [])
 
-  const { connect, disconnect } = useSSE({ onLine, onDone })
+  // Typed progress events: show the run's current phase next to the status
+  const onEvent = useCallback((type, ev) => {
+    if (type === 'phase') setStatus(`running… (${ev.phase.replace('_', ' ')})`)
+  }, [])
+
+  const { connect, disconnect } = useSSE({ onLine, onDone, onEvent })
 
   useEffect(() => {
     fetchRunStatus().then(s => {
@@ -326,10 +337,10 @@ function HomeSection() {
     setLogEntries([])
     setStatus('running…')
     try {
-      await startRun(playlist, dlmode, !noPersist, excludeLocal)
+      const { position } = await startRun(playlist, dlmode, !noPersist, excludeLocal, profile)
+      if (position > 0) { setStatus(`queued (#${position})`); setRunning(false); return }
       connect()
     } catch (e) {
-      if (e.conflict) { setStatus('already running'); setRunning(false); return }
       setStatus('error')
       setRunning(false)
     }
@@ -456,6 +467,15 @@ function HomeSection() {
             {customPlaylists.length > 0 && <option disabled>---</option>}
             {customPlaylists.map(cp => <option key={cp.id} value={cp.id}>{cp.name}</option>)}
           </select>
+          {profiles.length > 0 && (
+            <>
+              <label className="text-[12px] text-muted">Profile</label>
+              <select className={selectCls} value={profile} onChange={e => setProfile(e.target.value)}>
+                <option value="">Default</option>
+                {profiles.map(p => <option key={p.id} value={p.id}>{p.name}</option>)}
+              </select>
+            </>
+          )}
           <label className="flex items-center gap-1.5 text-[12px] text-muted cursor-pointer" title="When unchecked (default), previously generated playlists and their tracks are kept and added to over time. When checked, the playlist is wiped and rebuilt from scratch on each run.">
             <input type="checkbox" checked={noPersist} onChange={e => setNoPersist(e.target.checked)} /> don't persist
           </label>
@@ -624,7 +644,7 @@ function DownloadPathSection() {
       <div className="flex items-start justify-between mt-3 mb-1 gap-4">
         <div className="flex flex-col gap-0.5">
           <span className="text-[13px] text-white">Auto-tag songs</span>
-          <span className="text-[11px] text-muted">Looks up track numbers, year, genre & more from MusicBrainz and writes them to downloaded files. Applies to scheduled playlists only — not custom imports.</span>
+          <span className="text-[11px] text-muted">Looks up track numbers, year, genre & more from MusicBrainz and writes them to downloaded files. Applies to every playlist, including custom imports.</span>
         </div>
         <button
           role="switch"
@@ -782,6 +802,248 @@ function DownloadPathSection() {
 // Raw .env file viewer/editor, plus wizard re-run and full reset actions.
 // Fetches its own raw config text from the API.
 
+// Long-lived tokens for scripts and automations, sent as "Authorization: Bearer".
+// The token itself is only shown once, right after it's created.
+function ApiTokensSection() {
+  const [tokens, setTokens] = useState([])
+  const [name, setName] = useState('')
+  const [scope, setScope] = useState('run')
+  const [created, setCreated] = useState(null)
+  const [error, setError] = useState('')
+
+  const load = () => fetchTokens().then(setTokens).catch(() => setTokens([]))
+
+  useEffect(() => { load() }, [])
+
+  const handleCreate = async () => {
+    if (!name.trim()) return
+    try {
+      const res = await createToken(name.trim(), scope)
+      setCreated(res.token)
+      setName('')
+      setError('')
+      load()
+    } catch (e) {
+      setError(e.message)
+    }
+  }
+
+  const handleRevoke = async (tok) => {
+    if (!confirm(`Revoke token "${tok.name}"? Anything using it will stop working.`)) return
+    try {
+      await revokeToken(tok.id)
+      load()
+    } catch (e) {
+      setError(e.message)
+    }
+  }
+
+  const fmtDate = d => d ? new Date(d).toLocaleString() : 'never'
+
+  return (
+    <div className="mt-6">
+      <SectionLabel>API tokens</SectionLabel>
+      <p className="text-[11px] text-muted mb-3">
+        For Home Assistant, scripts and other automations. Send as <code>Authorization: Bearer &lt;token&gt;</code>.
+        Read can view status, playlists and logs; run can also start and stop runs; admin can do everything, including editing the config.
+      </p>
+
+      <div className="flex items-center gap-2.5 flex-wrap">
+        <input
+          type="text"
+          className="bg-surface border border-ui-border text-white rounded-[6px] px-2.5 py-1.5 text-[13px] outline-none focus:border-accent"
+          placeholder="Token name"
+          value={name}
+          onChange={e => setName(e.target.value)}
+        />
+        <select className={selectCls} value={scope} onChange={e => setScope(e.target.value)}>
+          <option value="read">read</option>
+          <option value="run">run</option>
+          <option value="admin">admin</option>
+        </select>
+        <Button onClick={handleCreate} disabled={!name.trim()}>Create</Button>
+        {error && <span className="text-[12px] text-[#c0392b]">{error}</span>}
+      </div>
+
+      {created && (
+        <div className="mt-3 p-3 bg-well border border-accent rounded-[6px]">
+          <div className="text-[11px] text-muted mb-1.5">Copy this token now, it won't be shown again.</div>
+          <div className="flex items-center gap-2.5">
+            <code className="font-mono text-[12px] text-white break-all">{created}</code>
+            <button
+              onClick={() => navigator.clipboard?.writeText(created)}
+              className="bg-transparent border-none text-muted text-[11px] cursor-pointer p-0 hover:text-white transition-colors shrink-0"
+            >
+              Copy
+            </button>
+            <button
+              onClick={() => setCreated(null)}
+              className="bg-transparent border-none text-muted text-[11px] cursor-pointer p-0 hover:text-white transition-colors shrink-0"
+            >
+              Done
+            </button>
+          </div>
+        </div>
+      )}
+
+      {tokens.length > 0 && (
+        <div className="mt-3 flex flex-col">
+          {tokens.map(tok => (
+            <div key={tok.id} className="flex items-center justify-between gap-4 py-2 border-b border-ui-border last:border-b-0">
+              <div className="flex flex-col gap-0.5 min-w-0">
+                <span className="text-[13px] text-white truncate">
+                  {tok.name} <span className="text-muted text-[11px]">· {tok.scope} · …{tok.hint}</span>
+                </span>
+                <span className="text-[11px] text-muted">
+                  Created {fmtDate(tok.created_at)} · last used {fmtDate(tok.last_used)}
+                </span>
+              </div>
+              <button
+                onClick={() => handleRevoke(tok)}
+                className="bg-transparent border-none text-[#c0392b] text-[12px] cursor-pointer p-0 hover:text-[#d65546] transition-colors shrink-0"
+              >
+                Revoke
+              </button>
+            </div>
+          ))}
+        </div>
+      )}
+    </div>
+  )
+}
+
+function ProfilesSection() {
+  const [profiles, setProfiles] = useState([])
+  const [newID, setNewID] = useState('')
+  const [editing, setEditing] = useState(null) // profile id being edited
+  const [rawProfile, setRawProfile] = useState('')
+  const [error, setError] = useState('')
+
+  const load = () => fetchProfiles().then(setProfiles).catch(() => setProfiles([]))
+
+  useEffect(() => { load() }, [])
+
+  const openEditor = async (id) => {
+    try {
+      setRawProfile(await fetchProfileRaw(id))
+      setEditing(id)
+      setError('')
+    } catch (e) {
+      setError(e.message)
+    }
+  }
+
+  const handleCreate = async () => {
+    const id = newID.trim().toLowerCase()
+    if (!id) return
+    try {
+      await createProfile(id)
+      setNewID('')
+      await load()
+      openEditor(id)
+    } catch (e) {
+      setError(e.message)
+    }
+  }
+
+  const handleSave = async () => {
+    try {
+      await saveProfile(editing, rawProfile)
+      setEditing(null)
+      setError('')
+      load()
+    } catch (e) {
+      setError(e.message)
+    }
+  }
+
+  const handleDelete = async (p) => {
+    if (!confirm(`Delete profile "${p.name}"? Its schedules stop, playlists already created are kept.`)) return
+    try {
+      await deleteProfile(p.id)
+      if (editing === p.id) setEditing(null)
+      load()
+    } catch (e) {
+      setError(e.message)
+    }
+  }
+
+  return (
+    <div className="mt-6">
+      <SectionLabel>Profiles</SectionLabel>
+      <p className="text-[11px] text-muted mb-3">
+        Profiles let several people share this instance. Each one overrides keys of the config file for its own runs,
+        usually the ListenBrainz user and the media server account, and can have its own schedules.
+        Tracks are only downloaded once, however many profiles want them.
+      </p>
+
+      <div className="flex items-center gap-2.5 flex-wrap">
+        <input
+          type="text"
+          className="bg-surface border border-ui-border text-white rounded-[6px] px-2.5 py-1.5 text-[13px] outline-none focus:border-accent"
+          placeholder="Profile id, e.g. alex"
+          value={newID}
+          onChange={e => setNewID(e.target.value)}
+        />
+        <Button onClick={handleCreate} disabled={!newID.trim()}>Add</Button>
+        {error && <span className="text-[12px] text-[#c0392b]">{error}</span>}
+      </div>
+
+      {profiles.length > 0 && (
+        <div className="mt-3 flex flex-col">
+          {profiles.map(p => (
+            <div key={p.id} className="flex items-center justify-between gap-4 py-2 border-b border-ui-border last:border-b-0">
+              <div className="flex flex-col gap-0.5 min-w-0">
+                <span className="text-[13px] text-white truncate">
+                  {p.name} <span className="text-muted text-[11px]">· {p.id}</span>
+                </span>
+                <span className="text-[11px] text-muted">
+                  ListenBrainz {p.listenbrainz_user || 'from config'} · media server {p.system_user || 'from config'} · {p.schedules} schedule{p.schedules === 1 ? '' : 's'}
+                </span>
+              </div>
+              <div className="flex items-center gap-3 shrink-0">
+                <button
+                  onClick={() => openEditor(p.id)}
+                  className="bg-transparent border-none text-muted text-[12px] cursor-pointer p-0 hover:text-white transition-colors"
+                >
+                  Edit
+                </button>
+                <button
+                  onClick={() => handleDelete(p)}
+                  className="bg-transparent border-none text-[#c0392b] text-[12px] cursor-pointer p-0 hover:text-[#d65546] transition-colors"
+                >
+                  Delete
+                </button>
+              </div>
+            </div>
+          ))}
+        </div>
+      )}
+
+      {editing && (
+        <div className="mt-3">
+          <div className="flex items-center justify-between mb-2">
+            <span className="text-[12px] text-muted">{editing}.env</span>
+            <div className="flex items-center gap-2.5">
+              <Button onClick={handleSave}>Save</Button>
+              <Button onClick={() => setEditing(null)}>Cancel</Button>
+            </div>
+          </div>
+          <textarea
+            className="bg-well border border-ui-border text-white rounded-[6px] w-full h-[260px] p-3.5 font-mono text-[12px] leading-relaxed resize-y outline-none focus:border-accent"
+            value={rawProfile}
+            onChange={e => setRawProfile(e.target.value)}
+            spellCheck={false}
+            autoComplete="off"
+            autoCorrect="off"
+            autoCapitalize="off"
+          />
+        </div>
+      )}
+    </div>
+  )
+}
+
 function ConfigSection({ onWizard }) {
   const [rawConfig, setRawConfig] = useState('')
   const [editing, setEditing] = useState(false)
@@ -803,14 +1065,13 @@ function ConfigSection({ onWizard }) {
   }
 
   const handleReset = async () => {
-    if (!confirm('Reset all settings? This will restart the container and take you back to setup.')) return
+    if (!confirm('Reset all settings? This takes you back to setup.')) return
     try {
-      await resetConfig()
-      const poll = async () => {
-        try { await fetch('/api/config'); location.reload() }
-        catch { setTimeout(poll, 1500) }
+      const { restart_required } = await resetConfig()
+      if (restart_required) {
+        alert('Settings reset. Restart explo for login and single sign-on changes to take effect.')
       }
-      setTimeout(poll, 3000)
+      location.reload()
     } catch (e) {
       alert('Reset failed: ' + e.message)
     }
@@ -852,6 +1113,10 @@ function ConfigSection({ onWizard }) {
 
       <DownloadPathSection />
 
+      <ProfilesSection />
+
+      <ApiTokensSection />
+
       <div className="mt-6">
         <SectionLabel>Setup</SectionLabel>
         <div className="flex flex-col items-start gap-2.5">
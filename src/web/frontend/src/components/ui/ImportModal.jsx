@@ -1,9 +1,13 @@
 import { useState, useEffect, useCallback } from 'react'
 import { motion, AnimatePresence } from 'motion/react'
-import { importCustomPlaylist } from '../../lib/api'
+import { importCustomPlaylist, uploadCustomPlaylist } from '../../lib/api'
 import listenbrainzIcon from '../../assets/listenbrainz.svg'
 import appleMusicIcon from '../../assets/apple-music.svg'
 import spotifyIcon from '../../assets/spotify.svg'
+import deezerIcon from '../../assets/deezer.svg'
+import tidalIcon from '../../assets/tidal.svg'
+import youtubeMusicIcon from '../../assets/youtube-music.svg'
+import fileIcon from '../../assets/file.svg'
 
 const REFRESH_OPTIONS = [
   { value: 0,  label: 'Never' },
@@ -14,11 +18,18 @@ const REFRESH_OPTIONS = [
 ]
 
 const SOURCES = [
-  { key: 'listenbrainz', label: 'ListenBrainz', icon: listenbrainzIcon, color: '#EB743B', placeholder: 'https://listenbrainz.org/playlist/\u2026' },
-  { key: 'apple_music',  label: 'Apple Music',  icon: appleMusicIcon,  color: '#FA243C', placeholder: 'https://music.apple.com/us/playlist/\u2026' },
-  { key: 'spotify',      label: 'Spotify',      icon: spotifyIcon,     color: '#1ed760', placeholder: 'https://open.spotify.com/playlist/\u2026' },
+  { key: 'listenbrainz',  label: 'ListenBrainz',  icon: listenbrainzIcon, color: '#EB743B', placeholder: 'https://listenbrainz.org/playlist/\u2026' },
+  { key: 'apple_music',   label: 'Apple Music',   icon: appleMusicIcon,   color: '#FA243C', placeholder: 'https://music.apple.com/us/playlist/\u2026' },
+  { key: 'spotify',       label: 'Spotify',       icon: spotifyIcon,      color: '#1ed760', placeholder: 'https://open.spotify.com/playlist/\u2026' },
+  { key: 'deezer',        label: 'Deezer',        icon: deezerIcon,       color: '#A238FF', placeholder: 'https://www.deezer.com/playlist/\u2026' },
+  { key: 'tidal',         label: 'Tidal',         icon: tidalIcon,        color: '#FFFFFF', placeholder: 'https://tidal.com/browse/playlist/\u2026' },
+  { key: 'youtube_music', label: 'YouTube Music', icon: youtubeMusicIcon, color: '#FF0000', placeholder: 'https://music.youtube.com/playlist?list=\u2026' },
+  { key: 'file',          label: 'File',          icon: fileIcon,         color: '#B0B0B0', upload: true },
 ]
 
+const UPLOAD_ACCEPT = '.m3u,.m3u8,.xspf,.jspf,.json,.csv,.tsv'
+const CSV_FIELDS = ['title', 'artist', 'album', 'isrc']
+
 function CoverThumb({ src, index, onLoaded }) {
   const [loaded, setLoaded] = useState(false)
   const done = () => { setLoaded(true); onLoaded?.() }
@@ -118,8 +129,10 @@ function SuccessPanel({ result, onImported, onSync, onClose, onError }) {
 }
 
 export function ImportModal({ onClose, onImported, onSync }) {
-  const [source, setSource] = useState(null)   // 'listenbrainz' | 'apple_music'
+  const [source, setSource] = useState(null)   // a SOURCES key
   const [url, setUrl] = useState('')
+  const [file, setFile] = useState(null)
+  const [columns, setColumns] = useState({})   // CSV column mapping, empty fields are guessed
   const [refreshDays, setRefreshDays] = useState(0)
   const [phase, setPhase] = useState('source') // 'source' | 'form' | 'success' | 'error'
   const [loading, setLoading] = useState(false)
@@ -128,11 +141,16 @@ export function ImportModal({ onClose, onImported, onSync }) {
 
   const sourceCfg = SOURCES.find(s => s.key === source)
 
+  const isUpload = !!sourceCfg?.upload
+  const isCSV = isUpload && /\.(csv|tsv)$/i.test(file?.name ?? '')
+
   const handleImport = async () => {
-    if (!url.trim() || loading) return
+    if (!canSubmit) return
     setLoading(true)
     try {
-      const data = await importCustomPlaylist(url.trim(), source, refreshDays)
+      const data = isUpload
+        ? await uploadCustomPlaylist(file, { columns: isCSV ? columns : {} })
+        : await importCustomPlaylist(url.trim(), source, refreshDays)
       setResult(data)
       setPhase('success')
     } catch (e) {
@@ -143,7 +161,7 @@ export function ImportModal({ onClose, onImported, onSync }) {
     }
   }
 
-  const canSubmit = url.trim() && !loading
+  const canSubmit = (isUpload ? file : url.trim()) && !loading
 
   return (
     <motion.div
@@ -260,6 +278,43 @@ export function ImportModal({ onClose, onImported, onSync }) {
 
               {/* Body */}
               <div className="px-5 pt-5 pb-5 flex flex-col gap-4">
+                {isUpload ? (
+                <>
+                <div className="flex flex-col gap-1.5">
+                  <label className="text-[12px] font-medium text-muted">
+                    Playlist file (M3U, XSPF, JSPF or CSV)
+                  </label>
+                  <input
+                    type="file"
+                    accept={UPLOAD_ACCEPT}
+                    onChange={e => setFile(e.target.files?.[0] ?? null)}
+                    disabled={loading}
+                    className="w-full bg-well border border-ui-border text-white rounded-lg px-3 py-2 text-[13px] outline-none file:mr-3 file:bg-[#2a2a2a] file:text-white file:border-none file:rounded-full file:px-3 file:py-1 file:cursor-pointer disabled:opacity-50"
+                  />
+                </div>
+                {isCSV && (
+                  <div className="flex flex-col gap-1.5">
+                    <label className="text-[12px] font-medium text-muted">
+                      CSV columns (header name or number, blank to detect)
+                    </label>
+                    <div className="grid grid-cols-2 gap-2">
+                      {CSV_FIELDS.map(field => (
+                        <input
+                          key={field}
+                          type="text"
+                          value={columns[field] ?? ''}
+                          onChange={e => setColumns(c => ({ ...c, [field]: e.target.value }))}
+                          placeholder={field === 'isrc' ? 'ISRC' : field[0].toUpperCase() + field.slice(1)}
+                          disabled={loading}
+                          className="w-full bg-well border border-ui-border text-white rounded-lg px-3 py-2 text-[13px] outline-none placeholder:text-[#444] focus:border-[var(--brand)] transition-colors disabled:opacity-50"
+                        />
+                      ))}
+                    </div>
+                  </div>
+                )}
+                </>
+                ) : (
+                <>
                 <div className="flex flex-col gap-1.5">
                   <label className="text-[12px] font-medium text-muted">
                     {sourceCfg?.label ?? 'Playlist'} URL
@@ -291,12 +346,14 @@ export function ImportModal({ onClose, onImported, onSync }) {
                     ))}
                   </select>
                 </div>
+                </>
+                )}
               </div>
 
               {/* Footer */}
               <div className="flex justify-end gap-2 px-5 pb-5">
                 <button
-                  onClick={() => { setPhase('source'); setSource(null); setUrl('') }}
+                  onClick={() => { setPhase('source'); setSource(null); setUrl(''); setFile(null); setColumns({}) }}
                   disabled={loading}
                   className="bg-transparent border border-ui-border text-muted rounded-full px-4 py-1.5 text-[13px] cursor-pointer hover:text-white hover:border-[#444] transition-colors disabled:opacity-40"
                 >
@@ -3,7 +3,9 @@ import { motion, AnimatePresence } from 'motion/react'
 import { Toggle } from './Toggle'
 import { Button } from './common'
 import { fetchPlaylistTracks } from '../../lib/listenbrainz'
-import { prefetchPlaylists } from '../../lib/api'
+import { prefetchPlaylists, playlistExportUrl } from '../../lib/api'
+
+const EXPORT_FORMATS = ['m3u8', 'xspf', 'jspf']
 
 // ── TrackRow ──────────────────────────────────────────────────────────────────
 
@@ -172,6 +174,8 @@ export function TracklistDropdown({ playlist, lbUser, onRun }) {
   }
 
   const genDate = generatedAt ? new Date(generatedAt) : null
+  // Exports are written by runs, which also record whether each track made it into the playlist
+  const exported = !loading && tracks.some(t => t.inLibrary !== undefined)
 
   return (
     <div style={{ marginTop: 16 }}>
@@ -185,9 +189,22 @@ export function TracklistDropdown({ playlist, lbUser, onRun }) {
             Generated {genDate.toLocaleDateString([], { month: 'short', day: 'numeric' })}
           </span>
         )}
-        {onRun && (
-          <span style={{ marginLeft: 'auto', display: 'flex', alignItems: 'center', gap: 10 }}>
-            {runStatus && <span style={{ fontSize: 10, color: '#565656' }}>{runStatus}</span>}
+        <span style={{ marginLeft: 'auto', display: 'flex', alignItems: 'center', gap: 10 }}>
+          {exported && EXPORT_FORMATS.map(f => (
+            <a
+              key={f}
+              href={playlistExportUrl(playlist, f)}
+              download
+              title={`Download as ${f.toUpperCase()}`}
+              style={{ fontSize: 10, letterSpacing: '0.06em', textTransform: 'uppercase', color: '#565656', textDecoration: 'none' }}
+              onMouseEnter={e => { e.currentTarget.style.color = 'white' }}
+              onMouseLeave={e => { e.currentTarget.style.color = '#565656' }}
+            >
+              ↓ {f}
+            </a>
+          ))}
+          {onRun && runStatus && <span style={{ fontSize: 10, color: '#565656' }}>{runStatus}</span>}
+          {onRun && (
             <button
               onClick={handleRun}
               disabled={running}
@@ -202,8 +219,8 @@ export function TracklistDropdown({ playlist, lbUser, onRun }) {
             >
               {running ? 'Starting…' : '▶ Run'}
             </button>
-          </span>
-        )}
+          )}
+        </span>
       </div>
 
       {/* Track list */}
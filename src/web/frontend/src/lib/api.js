@@ -31,10 +31,22 @@ export async function checkAuth() {
   return res.ok
 }
 
-export async function login(username, password) {
+// Which sign-in options the server has enabled: { password, oidc }
+export async function fetchAuthProviders() {
+  try {
+    const res = await fetch('/api/ui/auth/providers')
+    if (!res.ok) return { password: true, oidc: false }
+    return res.json()
+  } catch {
+    return { password: true, oidc: false }
+  }
+}
+
+export async function login(username, password, remember = false) {
   const form = new URLSearchParams()
   form.append('username', username)
   form.append('password', password)
+  if (remember) form.append('remember', 'true')
 
   const res = await apiFetch('/api/ui/login', {
     method: 'POST',
@@ -68,9 +80,11 @@ export async function saveConfig(text) {
   if (!res.ok) throw new Error(await res.text())
 }
 
+// Resolves to { restart_required }, the server keeps its login and SSO settings until restarted
 export async function resetConfig() {
   const res = await apiFetch('/api/ui/config/reset', { method: 'POST' })
   if (!res.ok) throw new Error(await res.text())
+  return res.json()
 }
 
 export async function saveSchedule(name, enabled, day, hour, minute) {
@@ -114,18 +128,28 @@ export async function fetchBrowse(path) {
   return res.json()
 }
 
-export async function startRun(playlist, download_mode, persist, exclude_local) {
+export async function startRun(playlist, download_mode, persist, exclude_local, profile = '') {
   const form = new FormData()
   form.set('playlist', playlist)
+  if (profile) form.set('profile', profile)
   form.set('download_mode', download_mode)
   form.set('persist', persist ? 'true' : 'false')
   form.set('exclude_local', exclude_local ? 'true' : 'false')
   const res = await apiFetch('/api/ui/run', { method: 'POST', body: form })
-  if (res.status === 409) throw Object.assign(new Error('already running'), { conflict: true })
   if (!res.ok) throw new Error(await res.text())
   return res.json()
 }
 
+export async function fetchRunQueue() {
+  const res = await apiFetch('/api/ui/run/queue')
+  return res.json()
+}
+
+export async function cancelQueuedRun(id) {
+  const res = await apiFetch('/api/ui/run/queue/' + encodeURIComponent(id), { method: 'DELETE' })
+  if (!res.ok) throw new Error(await res.text())
+}
+
 export async function stopRun() {
   const res = await apiFetch('/api/ui/run/stop', { method: 'POST' })
   if (!res.ok) throw new Error(await res.text())
@@ -149,6 +173,13 @@ export async function prefetchPlaylists(user, playlists, options = {}) {
   })
 }
 
+// Download URL of a playlist's last run, format is one of EXPORT_FORMATS. Pass a profile
+// for that profile's run instead of the main config's
+export function playlistExportUrl(playlist, format, profile = '') {
+  const q = profile ? `&profile=${encodeURIComponent(profile)}` : ''
+  return `/api/ui/playlists/export?type=${encodeURIComponent(playlist)}&format=${format}${q}`
+}
+
 export async function logout() {
   await apiFetch('/api/ui/logout', { method: 'POST' })
 }
@@ -180,6 +211,20 @@ export async function importCustomPlaylist(url, source, refresh_days) {
   return res.json()
 }
 
+// Imports an M3U/M3U8, XSPF, JSPF or CSV file. columns maps CSV fields
+// ({ title, artist, album, isrc }) to header names or 1-based column numbers.
+export async function uploadCustomPlaylist(file, { name = '', columns = {} } = {}) {
+  const form = new FormData()
+  form.append('file', file)
+  if (name) form.append('name', name)
+  for (const [field, column] of Object.entries(columns)) {
+    if (column) form.append(`${field}_column`, column)
+  }
+  const res = await apiFetch('/api/ui/custom-playlists/upload', { method: 'POST', body: form })
+  if (!res.ok) throw new Error(await res.text())
+  return res.json()
+}
+
 export async function deleteCustomPlaylist(id, { deleteTracks = false } = {}) {
   const qs = deleteTracks ? '?delete_tracks=true' : ''
   const res = await apiFetch(`/api/ui/custom-playlists/${encodeURIComponent(id)}${qs}`, { method: 'DELETE' })
@@ -241,3 +286,59 @@ export async function fetchBackgroundArt() {
     return null
   }
 }
+
+export async function fetchTokens() {
+  const res = await apiFetch('/api/ui/tokens')
+  if (!res.ok) throw new Error(await res.text())
+  return res.json()
+}
+
+export async function createToken(name, scope) {
+  const res = await apiFetch('/api/ui/tokens', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ name, scope }),
+  })
+  if (!res.ok) throw new Error(await res.text())
+  return res.json()
+}
+
+export async function revokeToken(id) {
+  const res = await apiFetch(`/api/ui/tokens/${encodeURIComponent(id)}`, { method: 'DELETE' })
+  if (!res.ok) throw new Error(await res.text())
+}
+
+export async function fetchProfiles() {
+  const res = await apiFetch('/api/ui/profiles')
+  if (!res.ok) throw new Error(await res.text())
+  return res.json()
+}
+
+export async function createProfile(id) {
+  const res = await apiFetch('/api/ui/profiles', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ id }),
+  })
+  if (!res.ok) throw new Error(await res.text())
+}
+
+export async function fetchProfileRaw(id) {
+  const res = await apiFetch(`/api/ui/profiles/${encodeURIComponent(id)}`)
+  if (!res.ok) throw new Error(await res.text())
+  return res.text()
+}
+
+export async function saveProfile(id, text) {
+  const res = await apiFetch(`/api/ui/profiles/${encodeURIComponent(id)}`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'text/plain' },
+    body: text,
+  })
+  if (!res.ok) throw new Error(await res.text())
+}
+
+export async function deleteProfile(id) {
+  const res = await apiFetch(`/api/ui/profiles/${encodeURIComponent(id)}`, { method: 'DELETE' })
+  if (!res.ok) throw new Error(await res.text())
+}
@@ -1,11 +1,14 @@
 // Session-level cache — avoids repeat fetches on open/close within the same page load.
 const memCache = new Map()
 
+// options.profile reads the tracklist of that profile's last run instead of the main config's
 export async function fetchPlaylistTracks(playlistType, options = {}) {
-  const key = playlistType
+  const profile = options.profile ?? ''
+  const key = profile ? `${profile}.${playlistType}` : playlistType
   if (!options.force && memCache.has(key)) return memCache.get(key)
 
-  const res = await fetch(`/api/ui/playlists?type=${encodeURIComponent(playlistType)}`, {
+  const q = profile ? `&profile=${encodeURIComponent(profile)}` : ''
+  const res = await fetch(`/api/ui/playlists?type=${encodeURIComponent(playlistType)}${q}`, {
     credentials: 'include',
   })
   if (res.status === 404) {